@@ -5,19 +5,26 @@ import (
 	"encoding/json"
 	"f1champshotlapsbot/pkg/apps"
 	"f1champshotlapsbot/pkg/apps/mainapp"
+	"f1champshotlapsbot/pkg/circuits"
+	"f1champshotlapsbot/pkg/config"
+	"f1champshotlapsbot/pkg/lapprogress"
+	"f1champshotlapsbot/pkg/livemap"
+	"f1champshotlapsbot/pkg/locale"
+	"f1champshotlapsbot/pkg/results"
+	"f1champshotlapsbot/pkg/serverset"
+	"f1champshotlapsbot/pkg/stats"
+	"f1champshotlapsbot/pkg/store"
+	"f1champshotlapsbot/pkg/tracks"
 	"flag"
-	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"github.com/oscar-martin/rfactor2telegrambot/pkg/apps/live"
 	"github.com/oscar-martin/rfactor2telegrambot/pkg/notification"
-	"github.com/oscar-martin/rfactor2telegrambot/pkg/servers"
 	"github.com/oscar-martin/rfactor2telegrambot/pkg/settings"
 	"github.com/oscar-martin/rfactor2telegrambot/pkg/webserver"
 	"golang.org/x/text/language"
@@ -27,28 +34,20 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-const (
-	EnvTelegramToken = "TELEGRAM_TOKEN"
-	EnvHotlapsDomain = "API_DOMAIN"
-	// format: <server_id>,<server_url>;<server_id>,<server_url>;...
-	// format example: "ServerID1,http://localhost:10001;ServerID2,http://localhost:10002;ServerID3,http://localhost:10003"
-	EnvServers          = "RF2_SERVERS"
-	EnvLiveMapDomain    = "LIVEMAP_DOMAIN"
-	EnvWebServerAddress = "WEBSERVER_ADDRESS"
-)
-
 var (
 	domain        = ""
 	liveMapDomain = ""
 	bot           *tgbotapi.BotAPI
 	app           apps.Accepter
+	usage         = stats.NewStats()
 )
 
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
 var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
+var configFile = flag.String("config", "", "read the configuration from the YAML `file` (or "+config.EnvConfigFile+")")
 
 func main() {
-	// flag.Parse()
+	flag.Parse()
 	// if *cpuprofile != "" {
 	// 	f, err := os.Create(*cpuprofile)
 	// 	if err != nil {
@@ -66,34 +65,18 @@ func main() {
 	// }()
 
 	var err error
-	// get token from env
-	token := os.Getenv(EnvTelegramToken)
-	if token == "" {
-		log.Fatalf("%s is not set", EnvTelegramToken)
-	}
-	domain = os.Getenv(EnvHotlapsDomain)
-	if domain == "" {
-		log.Fatalf("%s is not set", EnvHotlapsDomain)
-	}
-	domain = strings.TrimRight(domain, "/")
-
-	liveMapDomain = os.Getenv(EnvLiveMapDomain)
-	if liveMapDomain == "" {
-		log.Fatalf("%s is not set", EnvLiveMapDomain)
-	}
-	liveMapDomain = strings.TrimRight(liveMapDomain, "/")
-
-	rf2Servers := os.Getenv(EnvServers)
-	if rf2Servers == "" {
-		log.Fatalf("%s is not set", EnvServers)
+	// the config file is optional, environment variables override it
+	if *configFile == "" {
+		*configFile = os.Getenv(config.EnvConfigFile)
 	}
-
-	var webServerAddr = ":8080"
-	if os.Getenv(EnvWebServerAddress) != "" {
-		webServerAddr = os.Getenv(EnvWebServerAddress)
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %s", err.Error())
 	}
+	domain = cfg.APIDomain
+	liveMapDomain = cfg.LiveMapDomain
 
-	bot, err = tgbotapi.NewBotAPI(token)
+	bot, err = tgbotapi.NewBotAPI(cfg.TelegramToken)
 	if err != nil {
 		// Abort if something is wrong
 		log.Panic(err)
@@ -118,40 +101,83 @@ func main() {
 	bundle := i18n.NewBundle(language.English)
 	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
 	bundle.MustLoadMessageFile("active.es.json")
+	bundle.MustLoadMessageFile("active.en.json")
 	loc := i18n.NewLocalizer(bundle, "es")
 
 	exitChan := make(chan bool)
-	refreshHotlapsTicker := time.NewTicker(60 * time.Minute)
-	refreshServersTicker := time.NewTicker(10 * time.Second)
+	refreshHotlapsTicker := time.NewTicker(tracks.RefreshInterval)
 
 	settings, err := settings.NewManager()
 	if err != nil {
 		log.Fatalf("Error creating settings manager: %s", err.Error())
 	}
 
+	hotlapsStore, err := store.NewManager(store.DbName)
+	if err != nil {
+		log.Fatalf("Error creating hotlaps store: %s", err.Error())
+	}
+
 	nm := notification.NewManager(ctx, bot, settings, loc)
 	go nm.Start(exitChan)
 
 	// build the main app
-	ss, err := createServers(rf2Servers, liveMapDomain)
-	if err != nil {
-		log.Fatalf("Error creating servers: %s", err.Error())
-	}
 	ws := webserver.NewManager()
-	sm, err := servers.NewManager(ctx, bot, ss, ws, loc)
+	srvs := serverset.NewManager(ctx, bot, ws, liveMapDomain, loc)
+	_, err = srvs.Update(cfg.Servers)
 	if err != nil {
-		log.Fatalf("Error creating servers manager: %s", err.Error())
+		log.Fatalf("Error creating servers: %s", err.Error())
 	}
 	// ws.Debug()
 
-	app, err = mainapp.NewMainApp(ctx, bot, domain, ss, exitChan, refreshHotlapsTicker, settings, loc)
+	lm := locale.NewManager(bundle, "es", hotlapsStore)
+	apiCfg := tracks.DefaultClientConfig()
+	apiCfg.Timeout = cfg.APITimeout
+	apiCfg.Retries = *cfg.APIRetries
+	api := tracks.NewClient(domain, apiCfg)
+	cm := circuits.NewManager(bot, srvs, lm)
+	// serve the live map of the sessions with every car
+	liveMap := livemap.NewServer(cm)
+	liveMap.Register(ws.GetRouter(livemap.Path, livemap.Path))
+	// record the sessions to watch them again in the live map
+	replays, err := livemap.NewReplays(bot, livemap.ReplaysDir, liveMapDomain, cm, lm)
+	if err != nil {
+		log.Fatalf("Error creating replays: %s", err.Error())
+	}
+	replays.Register(ws.GetRouter(livemap.ReplayPath, livemap.ReplayPath))
+	// show the position of the cars in the lap for the users without the live map
+	pm := lapprogress.NewManager(bot, srvs, lm)
+	mainApp, err := mainapp.NewMainApp(ctx, bot, domain, api, srvs.Servers(), exitChan, refreshHotlapsTicker, settings, hotlapsStore, lm, loc, srvs, cm, replays, pm, cfg.Admins, usage)
 	if err != nil {
 		log.Fatalf("Error creating main app: %s", err.Error())
 	}
+	app = mainApp
+
+	// archive the sessions played in the servers
+	recorder := results.NewRecorder(hotlapsStore)
+	for _, srv := range srvs.Servers() {
+		recorder.Watch(srv.ID)
+		// learn the sectors of the tracks to mark them in the maps
+		cm.Watch(srv.ID)
+		liveMap.Watch(srv.ID)
+		replays.Watch(srv.ID)
+		pm.Watch(srv.ID)
+	}
+	recorderExitChan := make(chan bool)
+	recorderTicker := time.NewTicker(results.SnapshotInterval)
+	go recorder.Run(recorderTicker, recorderExitChan)
 
 	// start syncing once the apps are created
-	go sm.Sync(refreshServersTicker, exitChan)
-	go ws.Serve(webServerAddr)
+	srvs.Start()
+	go ws.Serve(cfg.WebServerAddress)
+
+	// reload the configuration on SIGHUP
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	go func() {
+		for range reloads {
+			cfg = reloadConfiguration(cfg, srvs, mainApp, recorder, cm, liveMap, replays, pm)
+		}
+	}()
 
 	// Tell the user the bot is online
 	log.Println("Start listening for updates. Press Ctrl-C to stop it")
@@ -163,10 +189,15 @@ func main() {
 	<-sigs
 
 	refreshHotlapsTicker.Stop()
-	refreshServersTicker.Stop()
+	srvs.Stop()
 	exitChan <- true
+	recorderTicker.Stop()
+	recorderExitChan <- true
+	recorder.Stop()
+	replays.Stop()
 
 	settings.Close()
+	hotlapsStore.Close()
 
 	cancel()
 
@@ -183,18 +214,40 @@ func main() {
 	// }
 }
 
-func createServers(rf2Servers, domain string) ([]servers.Server, error) {
-	serversStr := strings.Split(rf2Servers, ";")
-	ss := []servers.Server{}
-	for _, serverStr := range serversStr {
-		serverData := strings.Split(serverStr, ",")
-		if len(serverData) != 2 {
-			return nil, fmt.Errorf("Invalid server data: %s", serverStr)
+// reloadConfiguration reads the configuration again and applies the changes
+// in the servers. The rest of the values are only read at startup. The
+// current configuration is kept if the new one is not valid.
+func reloadConfiguration(cfg *config.Config, srvs *serverset.Manager, mainApp *mainapp.MainApp, recorder *results.Recorder, cm *circuits.Manager, liveMap *livemap.Server, replays *livemap.Replays, pm *lapprogress.Manager) *config.Config {
+	log.Println("Reloading configuration")
+	newCfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Printf("Error reloading configuration, keeping the current one: %s", err.Error())
+		return cfg
+	}
+	mainApp.SetAdmins(newCfg.Admins)
+	if newCfg.TelegramToken != cfg.TelegramToken || newCfg.APIDomain != cfg.APIDomain ||
+		newCfg.APITimeout != cfg.APITimeout || *newCfg.APIRetries != *cfg.APIRetries ||
+		newCfg.LiveMapDomain != cfg.LiveMapDomain || newCfg.WebServerAddress != cfg.WebServerAddress {
+		log.Println("Changes in the token, domains, API timeout and retries or web server address need a restart to be applied")
+	}
+
+	diff, err := srvs.Update(newCfg.Servers)
+	if err != nil {
+		log.Printf("Error updating servers: %s", err.Error())
+	}
+	log.Printf("Servers reloaded: %s", diff)
+	if !diff.Empty() {
+		mainApp.UpdateServers(srvs.Servers())
+		for _, srv := range srvs.Servers() {
+			recorder.Watch(srv.ID)
+			cm.Watch(srv.ID)
+			liveMap.Watch(srv.ID)
+			replays.Watch(srv.ID)
+			pm.Watch(srv.ID)
 		}
-		server := servers.NewServer(serverData[0], serverData[1], domain)
-		ss = append(ss, server)
+		srvs.Start()
 	}
-	return ss, nil
+	return newCfg
 }
 
 func receiveUpdates(ctx context.Context, updates tgbotapi.UpdatesChannel) {
@@ -268,6 +321,7 @@ func MessageHandler(ctx context.Context, message *tgbotapi.Message) {
 // When we get a button clicked, we react accordingly
 func handleButton(ctx context.Context, chatId int64, button string) error {
 	if accept, handler := app.AcceptButton(button); accept {
+		recordUsage(ctx, stats.KindButton, button, chatId)
 		return handler(ctx, chatId)
 	}
 	return nil
@@ -276,6 +330,7 @@ func handleButton(ctx context.Context, chatId int64, button string) error {
 // When we get a command, we react accordingly
 func handleCommand(ctx context.Context, chatId int64, command string) error {
 	if accept, handler := app.AcceptCommand(command); accept {
+		recordUsage(ctx, stats.KindCommand, command, chatId)
 		return handler(ctx, chatId)
 	}
 	return nil
@@ -283,7 +338,14 @@ func handleCommand(ctx context.Context, chatId int64, command string) error {
 
 func CallbackQueryHandler(ctx context.Context, query *tgbotapi.CallbackQuery) error {
 	if accept, handler := app.AcceptCallback(query); accept {
+		recordUsage(ctx, stats.KindCallback, query.Data, query.Message.Chat.ID)
 		return handler(ctx, query)
 	}
 	return nil
 }
+
+func recordUsage(ctx context.Context, kind, name string, chatId int64) {
+	if user, ok := ctx.Value(live.UserContextKey).(*tgbotapi.User); ok && user != nil {
+		usage.Record(kind, name, user.ID, chatId)
+	}
+}
@@ -0,0 +1,333 @@
+package admin
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/locale"
+	"f1champshotlapsbot/pkg/serverset"
+	"f1champshotlapsbot/pkg/stats"
+	"f1champshotlapsbot/pkg/store"
+	"f1champshotlapsbot/pkg/tracks"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/apps/live"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/settings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	commandRefresh   = "/admin_refresh"
+	commandServers   = "/admin_servers"
+	commandBroadcast = "/admin_broadcast"
+	commandStats     = "/admin_stats"
+
+	symbolDisabled = "⚪"
+	statsTop       = 10
+
+	// broadcastInterval spaces the messages of a broadcast so they stay below
+	// the limit of Telegram of about 30 messages per second
+	broadcastInterval = time.Second / 25
+)
+
+var (
+	commandBroadcastText = regexp.MustCompile(`^\/admin_broadcast(?:\s+((?s).*))?$`)
+)
+
+var (
+	msgNotAllowed       = &i18n.Message{ID: "admin.notAllowed", Other: "This command is only available for the admins of the bot"}
+	msgRefreshStarted   = &i18n.Message{ID: "admin.refreshStarted", Other: "Syncing tracks and sessions..."}
+	msgRefreshDone      = &i18n.Message{ID: "admin.refreshDone", Other: "Tracks and sessions synced in %s"}
+	msgRefreshFailed    = &i18n.Message{ID: "admin.refreshFailed", Other: "Error syncing tracks and sessions: %s"}
+	msgNoServers        = &i18n.Message{ID: "admin.noServers", Other: "There are no servers configured"}
+	msgServers          = &i18n.Message{ID: "admin.servers", Other: "Configured servers:"}
+	msgServerPolling    = &i18n.Message{ID: "admin.serverPolling", Other: "polled every %s"}
+	msgServerNotPolling = &i18n.Message{ID: "admin.serverNotPolling", Other: "not polled yet"}
+	msgServerDisabled   = &i18n.Message{ID: "admin.serverDisabled", Other: "disabled"}
+	msgBroadcastUsage   = &i18n.Message{ID: "admin.broadcastUsage", Other: "Write the message to send, for example: /admin_broadcast The server restarts in 5 minutes"}
+	msgBroadcastNoChats = &i18n.Message{ID: "admin.broadcastNoChats", Other: "There are no subscribed chats"}
+	msgBroadcastSending = &i18n.Message{ID: "admin.broadcastSending", Other: "Sending the message to %d chats..."}
+	msgBroadcastSent    = &i18n.Message{ID: "admin.broadcastSent", Other: "Message sent to %d of %d chats"}
+	msgStats            = &i18n.Message{ID: "admin.stats", Other: "Uptime: %s\nUsers: %d\nChats: %d"}
+	msgStatsCommands    = &i18n.Message{ID: "admin.statsCommands", Other: "Commands (%d):"}
+	msgStatsButtons     = &i18n.Message{ID: "admin.statsButtons", Other: "Buttons (%d):"}
+	msgStatsCallbacks   = &i18n.Message{ID: "admin.statsCallbacks", Other: "Callbacks (%d):"}
+	msgStatsPrefetch    = &i18n.Message{ID: "admin.statsPrefetch", Other: "Tracks prefetched: %d of %d, %d failed"}
+	msgStatsPrefetching = &i18n.Message{ID: "admin.statsPrefetching", Other: "Prefetching tracks: %d of %d, %d failed"}
+)
+
+// AdminApp provides the commands to operate the bot. They are only available
+// for the Telegram users configured as admins.
+type AdminApp struct {
+	bot      *tgbotapi.BotAPI
+	admins   map[int64]bool
+	tm       *tracks.Manager
+	srvs     *serverset.Manager
+	store    *store.Manager
+	settings *settings.Manager
+	usage    *stats.Stats
+	locale   *locale.Manager
+	mu       sync.Mutex
+}
+
+func NewAdminApp(bot *tgbotapi.BotAPI, admins []int64, tm *tracks.Manager, srvs *serverset.Manager, store *store.Manager, sm *settings.Manager, usage *stats.Stats, lm *locale.Manager) *AdminApp {
+	aa := &AdminApp{
+		bot:      bot,
+		tm:       tm,
+		srvs:     srvs,
+		store:    store,
+		settings: sm,
+		usage:    usage,
+		locale:   lm,
+	}
+	aa.SetAdmins(admins)
+	return aa
+}
+
+// SetAdmins replaces the Telegram user IDs allowed to use the admin commands.
+func (aa *AdminApp) SetAdmins(admins []int64) {
+	aa.mu.Lock()
+	defer aa.mu.Unlock()
+
+	aa.admins = make(map[int64]bool)
+	for _, admin := range admins {
+		aa.admins[admin] = true
+	}
+}
+
+func (aa *AdminApp) AcceptCommand(command string) (bool, func(ctx context.Context, chatId int64) error) {
+	if command == commandRefresh {
+		return true, aa.onlyAdmins(aa.renderRefresh())
+	} else if command == commandServers {
+		return true, aa.onlyAdmins(aa.renderServers())
+	} else if commandBroadcastText.MatchString(command) {
+		text := commandBroadcastText.FindStringSubmatch(command)[1]
+		return true, aa.onlyAdmins(aa.renderBroadcast(text))
+	} else if command == commandStats {
+		return true, aa.onlyAdmins(aa.renderStats())
+	}
+	return false, nil
+}
+
+func (aa *AdminApp) AcceptCallback(query *tgbotapi.CallbackQuery) (bool, func(ctx context.Context, query *tgbotapi.CallbackQuery) error) {
+	return false, nil
+}
+
+func (aa *AdminApp) AcceptButton(button string) (bool, func(ctx context.Context, chatId int64) error) {
+	return false, nil
+}
+
+func (aa *AdminApp) isAdmin(ctx context.Context) bool {
+	user, ok := ctx.Value(live.UserContextKey).(*tgbotapi.User)
+	if !ok || user == nil {
+		return false
+	}
+
+	aa.mu.Lock()
+	defer aa.mu.Unlock()
+	return aa.admins[user.ID]
+}
+
+func (aa *AdminApp) onlyAdmins(handler func(ctx context.Context, chatId int64) error) func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		if !aa.isAdmin(ctx) {
+			return aa.send(chatId, locale.Localize(aa.locale.Localizer(ctx), msgNotAllowed))
+		}
+		return handler(ctx, chatId)
+	}
+}
+
+func (aa *AdminApp) renderRefresh() func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := aa.locale.Localizer(ctx)
+		err := aa.send(chatId, locale.Localize(loc, msgRefreshStarted))
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			start := time.Now()
+			err := aa.tm.Refresh(ctx)
+			message := fmt.Sprintf(locale.Localize(loc, msgRefreshDone), time.Since(start).Round(time.Second))
+			if err != nil {
+				message = fmt.Sprintf(locale.Localize(loc, msgRefreshFailed), err.Error())
+			}
+			err = aa.send(chatId, message)
+			if err != nil {
+				log.Printf("An error occured: %s", err.Error())
+			}
+		}()
+		return nil
+	}
+}
+
+func (aa *AdminApp) renderServers() func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := aa.locale.Localizer(ctx)
+		statuses := aa.srvs.Status()
+		if len(statuses) == 0 {
+			return aa.send(chatId, locale.Localize(loc, msgNoServers))
+		}
+
+		message := locale.Localize(loc, msgServers) + "\n\n"
+		for _, status := range statuses {
+			symbol := status.Status
+			state := fmt.Sprintf(locale.Localize(loc, msgServerPolling), status.Config.PollInterval)
+			if !status.Config.IsEnabled() {
+				symbol = symbolDisabled
+				state = locale.Localize(loc, msgServerDisabled)
+			} else if !status.Polling {
+				state = locale.Localize(loc, msgServerNotPolling)
+			}
+			message += fmt.Sprintf("%s %s (%s)\n     %s, %s\n", symbol, status.Config.Name, status.Config.ID, status.Config.URL, state)
+		}
+		return aa.send(chatId, message)
+	}
+}
+
+func (aa *AdminApp) renderBroadcast(text string) func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := aa.locale.Localizer(ctx)
+		if strings.TrimSpace(text) == "" {
+			return aa.send(chatId, locale.Localize(loc, msgBroadcastUsage))
+		}
+
+		chatIds, err := aa.subscribedChats()
+		if err != nil {
+			return err
+		}
+		if len(chatIds) == 0 {
+			return aa.send(chatId, locale.Localize(loc, msgBroadcastNoChats))
+		}
+
+		err = aa.send(chatId, fmt.Sprintf(locale.Localize(loc, msgBroadcastSending), len(chatIds)))
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			sent := aa.broadcast(chatIds, text)
+			err := aa.send(chatId, fmt.Sprintf(locale.Localize(loc, msgBroadcastSent), sent, len(chatIds)))
+			if err != nil {
+				log.Printf("An error occured: %s", err.Error())
+			}
+		}()
+		return nil
+	}
+}
+
+// broadcast sends the text to the chats, one every broadcastInterval, and
+// returns how many were sent.
+func (aa *AdminApp) broadcast(chatIds []int64, text string) int {
+	ticker := time.NewTicker(broadcastInterval)
+	defer ticker.Stop()
+
+	sent := 0
+	for i, id := range chatIds {
+		if i > 0 {
+			<-ticker.C
+		}
+		err := aa.send(id, text)
+		if err != nil {
+			log.Printf("Error broadcasting to chat %d: %s", id, err.Error())
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// subscribedChats returns the chats subscribed to hotlaps leaderboards or to
+// any live timing notification.
+func (aa *AdminApp) subscribedChats() ([]int64, error) {
+	chats := map[int64]bool{}
+	hotlapsChats, err := aa.store.ListAllSubscribedChats()
+	if err != nil {
+		return nil, err
+	}
+	for _, chatId := range hotlapsChats {
+		chats[chatId] = true
+	}
+
+	for _, sessionType := range []string{settings.TestDay, settings.Practice, settings.Qual, settings.Warmup, settings.Race} {
+		users, err := aa.settings.ListUsersForSessionStarted(sessionType)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			chatId, err := strconv.ParseInt(user.ChatID, 10, 64)
+			if err != nil {
+				log.Printf("Invalid chat ID %q for user %s", user.ChatID, user.ID)
+				continue
+			}
+			chats[chatId] = true
+		}
+	}
+
+	chatIds := []int64{}
+	for chatId := range chats {
+		chatIds = append(chatIds, chatId)
+	}
+	sort.Slice(chatIds, func(i, j int) bool { return chatIds[i] < chatIds[j] })
+	return chatIds, nil
+}
+
+func (aa *AdminApp) renderStats() func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := aa.locale.Localizer(ctx)
+		snapshot := aa.usage.Snapshot()
+
+		message := fmt.Sprintf(locale.Localize(loc, msgStats), snapshot.Uptime.Round(time.Second), snapshot.Users, snapshot.Chats)
+		kinds := []struct {
+			kind string
+			msg  *i18n.Message
+		}{
+			{stats.KindCommand, msgStatsCommands},
+			{stats.KindButton, msgStatsButtons},
+			{stats.KindCallback, msgStatsCallbacks},
+		}
+		for _, k := range kinds {
+			message += "\n\n" + fmt.Sprintf(locale.Localize(loc, k.msg), snapshot.Totals[k.kind])
+			for i, count := range snapshot.Counts[k.kind] {
+				if i == statsTop {
+					break
+				}
+				message += fmt.Sprintf("\n ▸ %s: %d", count.Name, count.Count)
+			}
+		}
+
+		prefetch := aa.tm.PrefetchStatus()
+		if prefetch.Total > 0 {
+			msg := msgStatsPrefetch
+			if prefetch.Running {
+				msg = msgStatsPrefetching
+			}
+			message += "\n\n" + fmt.Sprintf(locale.Localize(loc, msg), prefetch.Done, prefetch.Total, len(prefetch.Errors))
+			failed := make([]string, 0, len(prefetch.Errors))
+			for name := range prefetch.Errors {
+				failed = append(failed, name)
+			}
+			sort.Strings(failed)
+			for i, name := range failed {
+				if i == statsTop {
+					break
+				}
+				message += fmt.Sprintf("\n ▸ %s: %s", name, prefetch.Errors[name].Error())
+			}
+		}
+		return aa.send(chatId, message)
+	}
+}
+
+func (aa *AdminApp) send(chatId int64, message string) error {
+	msg := tgbotapi.NewMessage(chatId, message)
+	_, err := aa.bot.Send(msg)
+	return err
+}
@@ -2,12 +2,34 @@ package apps
 
 import (
 	"context"
+	"f1champshotlapsbot/pkg/locale"
+	"strings"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/menus"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
+// msgButtonBackTo is the message of the back button of the application menus
+var msgButtonBackTo = &i18n.Message{ID: "menus.backTo", Other: "Back to"}
+
 type Accepter interface {
 	AcceptCommand(command string) (bool, func(ctx context.Context, chatId int64) error)
 	AcceptButton(button string) (bool, func(ctx context.Context, chatId int64) error)
 	AcceptCallback(query *tgbotapi.CallbackQuery) (bool, func(ctx context.Context, query *tgbotapi.CallbackQuery) error)
 }
+
+// ButtonBackTo returns the button going back from the application menu to the
+// menu it was opened from, in the language of the localizer. The one of the
+// menu is always in the language of the bot.
+func ButtonBackTo(am menus.ApplicationMenu, loc *i18n.Localizer) string {
+	return locale.Localize(loc, msgButtonBackTo) + " " + am.From
+}
+
+// IsButtonBackTo reports whether the button goes back from the application
+// menu in any supported language.
+func IsButtonBackTo(lm *locale.Manager, am menus.ApplicationMenu, button string) bool {
+	label, found := strings.CutSuffix(button, " "+am.From)
+	return found && lm.Is(label, msgButtonBackTo)
+}
@@ -2,6 +2,8 @@ package hotlaps
 
 import (
 	"context"
+	"f1champshotlapsbot/pkg/apps"
+	"f1champshotlapsbot/pkg/locale"
 	"f1champshotlapsbot/pkg/tracks"
 	"fmt"
 	"regexp"
@@ -12,47 +14,60 @@ import (
 	"github.com/oscar-martin/rfactor2telegrambot/pkg/menus"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
-const (
-	buttonTracks = "Circuitos"
-	buttonActual = "Actual"
+var (
+	commandTrackId        = regexp.MustCompile(`^\/(\d+)$`)
+	commandTrackSessionId = regexp.MustCompile(`^\/(\d+)_(.+)$`)
+	commandDriver         = regexp.MustCompile(`^\/driver(?:\s+(.*))?$`)
+)
+
+var (
+	msgButtonTracks = &i18n.Message{ID: "hotlaps.buttonTracks", Other: "Tracks"}
+	msgButtonActual = &i18n.Message{ID: "hotlaps.buttonActual", Other: "Current"}
+	msgButtonRecent = &i18n.Message{ID: "hotlaps.buttonRecent", Other: "Recent"}
+	msgApplication  = &i18n.Message{ID: "hotlaps.application", Other: "%s application"}
 )
 
 type HotlapsApp struct {
-	bot          *tgbotapi.BotAPI
-	apiDomain    string
-	appMenu      menus.ApplicationMenu
-	tm           *tracks.Manager
-	menuKeyboard tgbotapi.ReplyKeyboardMarkup
+	bot     *tgbotapi.BotAPI
+	appMenu menus.ApplicationMenu
+	tm      *tracks.Manager
+	locale  *locale.Manager
+}
+
+func NewHotlapsApp(ctx context.Context, bot *tgbotapi.BotAPI, api *tracks.Client, appMenu menus.ApplicationMenu, exitChan chan bool, refreshTicker *time.Ticker, store tracks.Storer, lm *locale.Manager) *HotlapsApp {
+	tm := tracks.NewTrackManager(bot, api, store, lm)
+	tm.Sync(ctx, refreshTicker, exitChan)
+
+	return &HotlapsApp{
+		bot:     bot,
+		appMenu: appMenu,
+		tm:      tm,
+		locale:  lm,
+	}
 }
 
-func NewHotlapsApp(ctx context.Context, bot *tgbotapi.BotAPI, domain string, appMenu menus.ApplicationMenu, exitChan chan bool, refreshTicker *time.Ticker) *HotlapsApp {
-	menuKeyboard := tgbotapi.NewReplyKeyboard(
+// Tracks returns the manager of the hotlaps tracks.
+func (hl *HotlapsApp) Tracks() *tracks.Manager {
+	return hl.tm
+}
+
+func (hl *HotlapsApp) menuKeyboard(loc *i18n.Localizer) tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton(buttonTracks),
-			tgbotapi.NewKeyboardButton(buttonActual),
+			tgbotapi.NewKeyboardButton(locale.Localize(loc, msgButtonTracks)),
+			tgbotapi.NewKeyboardButton(locale.Localize(loc, msgButtonActual)),
+			tgbotapi.NewKeyboardButton(locale.Localize(loc, msgButtonRecent)),
 		),
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton(appMenu.ButtonBackTo()),
+			tgbotapi.NewKeyboardButton(apps.ButtonBackTo(hl.appMenu, loc)),
 		),
 	)
-
-	tm := tracks.NewTrackManager(bot, domain)
-	tm.Sync(ctx, refreshTicker, exitChan)
-
-	return &HotlapsApp{
-		apiDomain:    domain,
-		bot:          bot,
-		appMenu:      appMenu,
-		tm:           tm,
-		menuKeyboard: menuKeyboard,
-	}
 }
 
 func (hl *HotlapsApp) AcceptCommand(command string) (bool, func(ctx context.Context, chatId int64) error) {
-	commandTrackId := regexp.MustCompile(`^\/(\d+)$`)
-	commandTrackSessionId := regexp.MustCompile(`^\/(\d+)_(.+)$`)
 	if commandTrackId.MatchString(command) {
 		// show categories for track id
 		trackId, _ := strconv.Atoi(commandTrackId.FindStringSubmatch(command)[1])
@@ -62,6 +77,10 @@ func (hl *HotlapsApp) AcceptCommand(command string) (bool, func(ctx context.Cont
 		trackId := commandTrackSessionId.FindStringSubmatch(command)[1]
 		categoryId := commandTrackSessionId.FindStringSubmatch(command)[2]
 		return true, hl.tm.RenderSessionForCategoryAndTrack(trackId, categoryId)
+	} else if commandDriver.MatchString(command) {
+		// show best laps of a driver across all tracks
+		driver := commandDriver.FindStringSubmatch(command)[1]
+		return true, hl.tm.RenderDriver(driver)
 	}
 	return false, nil
 }
@@ -72,6 +91,12 @@ func (hl *HotlapsApp) AcceptCallback(query *tgbotapi.CallbackQuery) (bool, func(
 		return true, hl.tm.RenderShowTracksCallback(data)
 	} else if data[0] == tracks.SubcommandShowSessionData {
 		return true, hl.tm.RenderSessionsCallback(data)
+	} else if data[0] == tracks.SubcommandSubscribe {
+		return true, hl.tm.RenderSubscribeCallback(data)
+	} else if data[0] == tracks.SubcommandShowDriver {
+		return true, hl.tm.RenderShowDriverCallback(data)
+	} else if data[0] == tracks.SubcommandShowRecent {
+		return true, hl.tm.RenderShowRecentCallback(data)
 	}
 	return false, nil
 }
@@ -80,23 +105,26 @@ func (hl *HotlapsApp) AcceptButton(button string) (bool, func(ctx context.Contex
 	// fmt.Printf("HOTLAP: button: %s. appName: %s\n", button, hl.appMenu.Name)
 	if button == hl.appMenu.Name {
 		return true, func(ctx context.Context, chatId int64) error {
-			message := fmt.Sprintf("%s application\n\n", hl.appMenu.Name)
+			loc := hl.locale.Localizer(ctx)
+			message := fmt.Sprintf(locale.Localize(loc, msgApplication)+"\n\n", hl.appMenu.Name)
 			msg := tgbotapi.NewMessage(chatId, message)
-			msg.ReplyMarkup = hl.menuKeyboard
+			msg.ReplyMarkup = hl.menuKeyboard(loc)
 			_, err := hl.bot.Send(msg)
 			return err
 		}
-	} else if button == hl.appMenu.ButtonBackTo() {
+	} else if apps.IsButtonBackTo(hl.locale, hl.appMenu, button) {
 		return true, func(ctx context.Context, chatId int64) error {
 			msg := tgbotapi.NewMessage(chatId, "OK")
 			msg.ReplyMarkup = hl.appMenu.PrevMenu()
 			_, err := hl.bot.Send(msg)
 			return err
 		}
-	} else if button == buttonTracks {
+	} else if hl.locale.Is(button, msgButtonTracks) {
 		return true, hl.tm.RenderTracks()
-	} else if button == buttonActual {
+	} else if hl.locale.Is(button, msgButtonActual) {
 		return true, hl.tm.RenderCurrentSession()
+	} else if hl.locale.Is(button, msgButtonRecent) {
+		return true, hl.tm.RenderRecentActivity()
 	}
 	// fmt.Print("HOTLAP: FALSE\n")
 	return false, nil
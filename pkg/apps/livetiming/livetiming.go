@@ -0,0 +1,209 @@
+package livetiming
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/apps"
+	"fmt"
+	"sync"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/apps/live"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/menus"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/model"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/pubsub"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/servers"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/settings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	liveAppName = "LiveTiming"
+)
+
+var (
+	msgButtonSettings = &i18n.Message{ID: "live.buttonSettings", Other: "Settings"}
+)
+
+// LiveApp shows the live timing of the servers, like the live app of
+// rfactor2telegrambot, but its servers can be changed while the bot is
+// running. The pubsub has no way to unsubscribe, so the data of every server
+// is subscribed to only once and its app is kept while its URL is the same.
+type LiveApp struct {
+	bot          *tgbotapi.BotAPI
+	appMenu      menus.ApplicationMenu
+	menuKeyboard tgbotapi.ReplyKeyboardMarkup
+	settingsApp  *live.SettingsApp
+	servers      []servers.Server
+	serverApps   map[string]*serverApp
+	watched      map[string]bool
+	loc          *i18n.Localizer
+	mu           sync.Mutex
+}
+
+type serverApp struct {
+	url string
+	app *live.ServerApp
+}
+
+func NewLiveApp(bot *tgbotapi.BotAPI, ss []servers.Server, appMenu menus.ApplicationMenu, sm *settings.Manager, loc *i18n.Localizer) *LiveApp {
+	la := &LiveApp{
+		bot:        bot,
+		appMenu:    appMenu,
+		serverApps: make(map[string]*serverApp),
+		watched:    make(map[string]bool),
+		loc:        loc,
+	}
+	la.settingsApp = live.NewSettingsApp(bot, appMenu, sm, la.getButtonSettingsTitle(), loc)
+	la.UpdateServers(ss)
+	return la
+}
+
+// UpdateServers replaces the servers shown. The servers already shown keep
+// their status and their app, unless their URL changed.
+func (la *LiveApp) UpdateServers(ss []servers.Server) {
+	la.mu.Lock()
+	defer la.mu.Unlock()
+
+	previous := map[string]servers.Server{}
+	for _, server := range la.servers {
+		previous[server.ID] = server
+	}
+	la.servers = make([]servers.Server, 0, len(ss))
+	for _, server := range ss {
+		if old, found := previous[server.ID]; found {
+			server.WebSocketRunning = old.WebSocketRunning
+			server.ReceivingData = old.ReceivingData
+		}
+		la.servers = append(la.servers, server)
+
+		sa, found := la.serverApps[server.ID]
+		if !found || sa.url != server.URL {
+			serverAppMenu := menus.NewApplicationMenu(server.StatusAndName(), liveAppName, la, la.loc)
+			la.serverApps[server.ID] = &serverApp{
+				url: server.URL,
+				app: live.NewServerApp(la.bot, serverAppMenu, server.ID, server.URL, la.loc),
+			}
+		}
+		la.watch(server.ID)
+	}
+	la.updateKeyboard()
+}
+
+// watch follows the status of the server, once.
+func (la *LiveApp) watch(serverId string) {
+	if la.watched[serverId] {
+		return
+	}
+	la.watched[serverId] = true
+	go la.updater(pubsub.LiveSessionInfoDataPubSub.Subscribe(pubsub.PubSubSessionInfoPreffix + serverId))
+}
+
+func (la *LiveApp) updater(c <-chan model.LiveSessionInfoData) {
+	for lsid := range c {
+		la.update(lsid)
+	}
+}
+
+func (la *LiveApp) update(lsid model.LiveSessionInfoData) {
+	la.mu.Lock()
+	defer la.mu.Unlock()
+	for idx := range la.servers {
+		if la.servers[idx].ID == lsid.ServerID {
+			if lsid.SessionInfo.ServerName != "" {
+				la.servers[idx].Name = lsid.SessionInfo.ServerName
+			}
+			la.servers[idx].WebSocketRunning = lsid.SessionInfo.WebSocketRunning
+			la.servers[idx].ReceivingData = lsid.SessionInfo.ReceivingData
+		}
+	}
+	la.updateKeyboard()
+}
+
+func (la *LiveApp) updateKeyboard() {
+	buttons := [][]tgbotapi.KeyboardButton{}
+	for idx := range la.servers {
+		if idx%2 == 0 {
+			buttons = append(buttons, []tgbotapi.KeyboardButton{})
+		}
+		buttons[len(buttons)-1] = append(buttons[len(buttons)-1], tgbotapi.NewKeyboardButton(la.servers[idx].StatusAndName()))
+	}
+	backButtonRow := tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton(la.appMenu.ButtonBackTo()),
+		tgbotapi.NewKeyboardButton(la.getButtonSettingsTitle()),
+	)
+	buttons = append(buttons, backButtonRow)
+
+	menuKeyboard := tgbotapi.NewReplyKeyboard()
+	menuKeyboard.Keyboard = buttons
+	la.menuKeyboard = menuKeyboard
+}
+
+func (la *LiveApp) getButtonSettingsTitle() string {
+	return la.loc.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: msgButtonSettings})
+}
+
+// getAccepters returns the apps of the servers shown, in order, and the
+// settings app.
+func (la *LiveApp) getAccepters() []apps.Accepter {
+	la.mu.Lock()
+	defer la.mu.Unlock()
+
+	accepters := []apps.Accepter{}
+	for _, server := range la.servers {
+		accepters = append(accepters, la.serverApps[server.ID].app)
+	}
+	return append(accepters, la.settingsApp)
+}
+
+func (la *LiveApp) Menu() tgbotapi.ReplyKeyboardMarkup {
+	la.mu.Lock()
+	defer la.mu.Unlock()
+
+	return la.menuKeyboard
+}
+
+func (la *LiveApp) AcceptCommand(command string) (bool, func(ctx context.Context, chatId int64) error) {
+	for _, accepter := range la.getAccepters() {
+		accept, handler := accepter.AcceptCommand(command)
+		if accept {
+			return true, handler
+		}
+	}
+	return false, nil
+}
+
+func (la *LiveApp) AcceptCallback(query *tgbotapi.CallbackQuery) (bool, func(ctx context.Context, query *tgbotapi.CallbackQuery) error) {
+	for _, accepter := range la.getAccepters() {
+		accept, handler := accepter.AcceptCallback(query)
+		if accept {
+			return true, handler
+		}
+	}
+	return false, nil
+}
+
+func (la *LiveApp) AcceptButton(button string) (bool, func(ctx context.Context, chatId int64) error) {
+	if button == la.appMenu.Name {
+		return true, func(ctx context.Context, chatId int64) error {
+			msg := tgbotapi.NewMessage(chatId, fmt.Sprintf("%s\n", la.appMenu.Name))
+			msg.ReplyMarkup = la.Menu()
+			_, err := la.bot.Send(msg)
+			return err
+		}
+	} else if button == la.appMenu.ButtonBackTo() {
+		return true, func(ctx context.Context, chatId int64) error {
+			msg := tgbotapi.NewMessage(chatId, "OK")
+			msg.ReplyMarkup = la.appMenu.PrevMenu()
+			_, err := la.bot.Send(msg)
+			return err
+		}
+	}
+	for _, accepter := range la.getAccepters() {
+		accept, handler := accepter.AcceptButton(button)
+		if accept {
+			return true, handler
+		}
+	}
+	return false, nil
+}
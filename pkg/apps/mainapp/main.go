@@ -3,13 +3,27 @@ package mainapp
 import (
 	"context"
 	"f1champshotlapsbot/pkg/apps"
+	"f1champshotlapsbot/pkg/apps/admin"
 	"f1champshotlapsbot/pkg/apps/hotlaps"
+	"f1champshotlapsbot/pkg/apps/livetiming"
+	"f1champshotlapsbot/pkg/apps/maps"
+	"f1champshotlapsbot/pkg/apps/progress"
+	"f1champshotlapsbot/pkg/apps/replays"
 	"f1champshotlapsbot/pkg/apps/sessions"
+	"f1champshotlapsbot/pkg/circuits"
+	"f1champshotlapsbot/pkg/lapprogress"
+	"f1champshotlapsbot/pkg/livemap"
+	"f1champshotlapsbot/pkg/locale"
+	"f1champshotlapsbot/pkg/serverset"
+	"f1champshotlapsbot/pkg/stats"
+	"f1champshotlapsbot/pkg/store"
+	"f1champshotlapsbot/pkg/tracks"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/nicksnyder/go-i18n/v2/i18n"
-	"github.com/oscar-martin/rfactor2telegrambot/pkg/apps/live"
 
 	"github.com/oscar-martin/rfactor2telegrambot/pkg/menus"
 	"github.com/oscar-martin/rfactor2telegrambot/pkg/servers"
@@ -21,17 +35,38 @@ import (
 const (
 	menuStart      = "/start"
 	menuMenu       = "/menu"
+	menuLang       = "/lang"
 	buttonHotlaps  = "Hotlaps"
 	buttonLive     = "LiveTiming"
 	buttonSessions = "Sessions"
 	appName        = "menu"
 )
 
+var (
+	commandLang = regexp.MustCompile(`^\/lang(?:\s+(\S+))?$`)
+)
+
+var (
+	msgHello          = &i18n.Message{ID: "mainapp.helloHotlaps", Other: "Hello, I am the F1Champs bot that shows the recorded Hotlaps and the ongoing sessions."}
+	msgHelloCommands  = &i18n.Message{ID: "mainapp.helloBot2", Other: "You can use the following command:"}
+	msgStartMenu      = &i18n.Message{ID: "mainapp.startMenu", Other: "Show the bot menu"}
+	msgStartLang      = &i18n.Message{ID: "mainapp.startLang", Other: "Change the language of the bot"}
+	msgStartMap       = &i18n.Message{ID: "mainapp.startMap", Other: "Show the map and the elevation profile of the track of a server"}
+	msgStartReplay    = &i18n.Message{ID: "mainapp.startReplay", Other: "Send the links to watch the replays of the sessions"}
+	msgStartPositions = &i18n.Message{ID: "mainapp.startPositions", Other: "Follow how much of the lap every driver has completed"}
+	msgMenu           = &i18n.Message{ID: "mainapp.menuMenu", Other: "Bot menu."}
+	msgLangCurrent    = &i18n.Message{ID: "mainapp.langCurrent", Other: "Current language: %s. Available languages: %s"}
+	msgLangAuto       = &i18n.Message{ID: "mainapp.langAuto", Other: "the one of your Telegram app"}
+	msgLangUsage      = &i18n.Message{ID: "mainapp.langUsage", Other: "Use %s <language> to change it, for example: %s en"}
+	msgLangChanged    = &i18n.Message{ID: "mainapp.langChanged", Other: "Language changed to %s"}
+	msgLangNotSupport = &i18n.Message{ID: "mainapp.langNotSupported", Other: "Language %q is not supported. Available languages: %s"}
+)
+
 var (
 	menuKeyboard = tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
 			tgbotapi.NewKeyboardButton(buttonHotlaps),
-			// tgbotapi.NewKeyboardButton(buttonSessions),
+			tgbotapi.NewKeyboardButton(buttonSessions),
 			tgbotapi.NewKeyboardButton(buttonLive),
 		),
 	)
@@ -46,34 +81,59 @@ func (m menuer) Menu() tgbotapi.ReplyKeyboardMarkup {
 type MainApp struct {
 	bot       *tgbotapi.BotAPI
 	accepters []apps.Accepter
+	adminApp  *admin.AdminApp
+	liveApp   *livetiming.LiveApp
+	locale    *locale.Manager
 }
 
-func NewMainApp(ctx context.Context, bot *tgbotapi.BotAPI, domain string, ss []servers.Server, exitChan chan bool, refreshHotlapsTicker *time.Ticker, sm *settings.Manager, loc *i18n.Localizer) (*MainApp, error) {
+func NewMainApp(ctx context.Context, bot *tgbotapi.BotAPI, domain string, api *tracks.Client, ss []servers.Server, exitChan chan bool, refreshHotlapsTicker *time.Ticker, sm *settings.Manager, store *store.Manager, lm *locale.Manager, loc *i18n.Localizer, srvs *serverset.Manager, cm *circuits.Manager, rs *livemap.Replays, pm *lapprogress.Manager, admins []int64, usage *stats.Stats) (*MainApp, error) {
 	hotlapsAppMenu := menus.NewApplicationMenu(buttonHotlaps, appName, menuer{}, loc)
-	hotlapApp := hotlaps.NewHotlapsApp(ctx, bot, domain, hotlapsAppMenu, exitChan, refreshHotlapsTicker)
+	hotlapApp := hotlaps.NewHotlapsApp(ctx, bot, api, hotlapsAppMenu, exitChan, refreshHotlapsTicker, store, lm)
 
 	sessionsAppMenu := menus.NewApplicationMenu(buttonSessions, appName, menuer{}, loc)
-	sessionsApp := sessions.NewSessionsApp(ctx, bot, domain, sessionsAppMenu)
+	sessionsApp := sessions.NewSessionsApp(ctx, bot, domain, sessionsAppMenu, store, lm)
 
 	liveAppMenu := menus.NewApplicationMenu(buttonLive, appName, menuer{}, loc)
-	liveApp, err := live.NewLiveApp(ctx, bot, ss, liveAppMenu, sm, loc)
-	if err != nil {
-		return nil, err
-	}
+	liveApp := livetiming.NewLiveApp(bot, ss, liveAppMenu, sm, loc)
+
+	adminApp := admin.NewAdminApp(bot, admins, hotlapApp.Tracks(), srvs, store, sm, usage, lm)
+
+	mapsApp := maps.NewMapsApp(cm)
 
-	accepters := []apps.Accepter{hotlapApp, sessionsApp, liveApp}
+	replaysApp := replays.NewReplaysApp(rs)
+
+	progressApp := progress.NewProgressApp(pm)
+
+	accepters := []apps.Accepter{hotlapApp, sessionsApp, liveApp, mapsApp, replaysApp, progressApp, adminApp}
 
 	return &MainApp{
 		bot:       bot,
 		accepters: accepters,
+		adminApp:  adminApp,
+		liveApp:   liveApp,
+		locale:    lm,
 	}, nil
 }
 
+// UpdateServers shows a new set of servers in the live timing app. The rest of
+// the apps are kept as they are.
+func (m *MainApp) UpdateServers(ss []servers.Server) {
+	m.liveApp.UpdateServers(ss)
+}
+
+// SetAdmins replaces the Telegram user IDs allowed to use the admin commands.
+func (m *MainApp) SetAdmins(admins []int64) {
+	m.adminApp.SetAdmins(admins)
+}
+
 func (m *MainApp) AcceptCommand(command string) (bool, func(ctx context.Context, chatId int64) error) {
 	if command == menuStart {
 		return true, m.renderStart()
 	} else if command == menuMenu {
 		return true, m.renderMenu()
+	} else if commandLang.MatchString(command) {
+		lang := commandLang.FindStringSubmatch(command)[1]
+		return true, m.renderLang(lang)
 	}
 	for _, accepter := range m.accepters {
 		accept, handler := accepter.AcceptCommand(command)
@@ -108,9 +168,14 @@ func (m *MainApp) AcceptButton(button string) (bool, func(ctx context.Context, c
 
 func (m *MainApp) renderStart() func(ctx context.Context, chatId int64) error {
 	return func(ctx context.Context, chatId int64) error {
-		message := "Hola, soy el bot de F1Champs que permite ver las Hotlaps registradas y sesiones en curso.\n\n"
-		message += "Puedes usar el siguiente comando:\n\n"
-		message += fmt.Sprintf("%s - Muestra el menú del bot\n", menuMenu)
+		loc := m.locale.Localizer(ctx)
+		message := locale.Localize(loc, msgHello) + "\n\n"
+		message += locale.Localize(loc, msgHelloCommands) + "\n\n"
+		message += fmt.Sprintf("%s - %s\n", menuMenu, locale.Localize(loc, msgStartMenu))
+		message += fmt.Sprintf("%s - %s\n", circuits.CommandMap, locale.Localize(loc, msgStartMap))
+		message += fmt.Sprintf("%s - %s\n", livemap.CommandReplay, locale.Localize(loc, msgStartReplay))
+		message += fmt.Sprintf("%s - %s\n", lapprogress.CommandPositions, locale.Localize(loc, msgStartPositions))
+		message += fmt.Sprintf("%s - %s\n", menuLang, locale.Localize(loc, msgStartLang))
 		msg := tgbotapi.NewMessage(chatId, message)
 		msg.ReplyMarkup = menuKeyboard
 		_, err := m.bot.Send(msg)
@@ -120,10 +185,35 @@ func (m *MainApp) renderStart() func(ctx context.Context, chatId int64) error {
 
 func (m *MainApp) renderMenu() func(ctx context.Context, chatId int64) error {
 	return func(ctx context.Context, chatId int64) error {
-		message := "Menú del bot.\n\n"
+		loc := m.locale.Localizer(ctx)
+		message := locale.Localize(loc, msgMenu) + "\n\n"
 		msg := tgbotapi.NewMessage(chatId, message)
 		msg.ReplyMarkup = menuKeyboard
 		_, err := m.bot.Send(msg)
 		return err
 	}
 }
+
+func (m *MainApp) renderLang(lang string) func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := m.locale.Localizer(ctx)
+		available := strings.Join(m.locale.Languages(), ", ")
+
+		var message string
+		if lang == "" {
+			current := m.locale.ChatLanguage(chatId)
+			if current == "" {
+				current = locale.Localize(loc, msgLangAuto)
+			}
+			message = fmt.Sprintf(locale.Localize(loc, msgLangCurrent), current, available) + "\n\n"
+			message += fmt.Sprintf(locale.Localize(loc, msgLangUsage), menuLang, menuLang)
+		} else if err := m.locale.SetLanguage(chatId, lang); err != nil {
+			message = fmt.Sprintf(locale.Localize(loc, msgLangNotSupport), lang, available)
+		} else {
+			message = fmt.Sprintf(locale.Localize(m.locale.ForChat(chatId), msgLangChanged), lang)
+		}
+		msg := tgbotapi.NewMessage(chatId, message)
+		_, err := m.bot.Send(msg)
+		return err
+	}
+}
@@ -0,0 +1,40 @@
+package maps
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/circuits"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MapsApp shows the map and the elevation profile of the track selected in
+// every server.
+type MapsApp struct {
+	cm *circuits.Manager
+}
+
+func NewMapsApp(cm *circuits.Manager) *MapsApp {
+	return &MapsApp{
+		cm: cm,
+	}
+}
+
+func (ma *MapsApp) AcceptCommand(command string) (bool, func(ctx context.Context, chatId int64) error) {
+	if command == circuits.CommandMap {
+		return true, ma.cm.RenderServers()
+	}
+	return false, nil
+}
+
+func (ma *MapsApp) AcceptCallback(query *tgbotapi.CallbackQuery) (bool, func(ctx context.Context, query *tgbotapi.CallbackQuery) error) {
+	data := strings.Split(query.Data, ":")
+	if data[0] == circuits.SubcommandShowCircuit {
+		return true, ma.cm.RenderCircuitCallback(data)
+	}
+	return false, nil
+}
+
+func (ma *MapsApp) AcceptButton(button string) (bool, func(ctx context.Context, chatId int64) error) {
+	return false, nil
+}
@@ -0,0 +1,43 @@
+package progress
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/lapprogress"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ProgressApp shows how much of the current lap every driver has completed in
+// the session running in every server.
+type ProgressApp struct {
+	pm *lapprogress.Manager
+}
+
+func NewProgressApp(pm *lapprogress.Manager) *ProgressApp {
+	return &ProgressApp{
+		pm: pm,
+	}
+}
+
+func (pa *ProgressApp) AcceptCommand(command string) (bool, func(ctx context.Context, chatId int64) error) {
+	if command == lapprogress.CommandPositions {
+		return true, pa.pm.RenderServers()
+	}
+	return false, nil
+}
+
+func (pa *ProgressApp) AcceptCallback(query *tgbotapi.CallbackQuery) (bool, func(ctx context.Context, query *tgbotapi.CallbackQuery) error) {
+	data := strings.Split(query.Data, ":")
+	switch data[0] {
+	case lapprogress.SubcommandFollowProgress:
+		return true, pa.pm.RenderFollowCallback(data)
+	case lapprogress.SubcommandStopProgress:
+		return true, pa.pm.RenderStopCallback(data)
+	}
+	return false, nil
+}
+
+func (pa *ProgressApp) AcceptButton(button string) (bool, func(ctx context.Context, chatId int64) error) {
+	return false, nil
+}
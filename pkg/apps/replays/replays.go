@@ -0,0 +1,35 @@
+package replays
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/livemap"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ReplaysApp sends the links of the replays of the sessions played in the
+// servers.
+type ReplaysApp struct {
+	rs *livemap.Replays
+}
+
+func NewReplaysApp(rs *livemap.Replays) *ReplaysApp {
+	return &ReplaysApp{
+		rs: rs,
+	}
+}
+
+func (ra *ReplaysApp) AcceptCommand(command string) (bool, func(ctx context.Context, chatId int64) error) {
+	if command == livemap.CommandReplay {
+		return true, ra.rs.RenderReplays()
+	}
+	return false, nil
+}
+
+func (ra *ReplaysApp) AcceptCallback(query *tgbotapi.CallbackQuery) (bool, func(ctx context.Context, query *tgbotapi.CallbackQuery) error) {
+	return false, nil
+}
+
+func (ra *ReplaysApp) AcceptButton(button string) (bool, func(ctx context.Context, chatId int64) error) {
+	return false, nil
+}
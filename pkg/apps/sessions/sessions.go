@@ -2,40 +2,78 @@ package sessions
 
 import (
 	"context"
+	"f1champshotlapsbot/pkg/apps"
+	"f1champshotlapsbot/pkg/locale"
+	"f1champshotlapsbot/pkg/results"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/oscar-martin/rfactor2telegrambot/pkg/menus"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+var (
+	commandResultId = regexp.MustCompile(`^` + results.CommandResult + `_(\d+)$`)
+)
+
+var (
+	msgButtonPractice   = &i18n.Message{ID: "sessions.buttonPractice", Other: "Practice"}
+	msgButtonQualifying = &i18n.Message{ID: "sessions.buttonQualifying", Other: "Qualifying"}
+	msgButtonRace       = &i18n.Message{ID: "sessions.buttonRace", Other: "Race"}
+	msgApplication      = &i18n.Message{ID: "sessions.application", Other: "%s application"}
 )
 
 type SessionsApp struct {
-	bot          *tgbotapi.BotAPI
-	apiDomain    string
-	appMenu      menus.ApplicationMenu
-	menuKeyboard tgbotapi.ReplyKeyboardMarkup
+	bot       *tgbotapi.BotAPI
+	apiDomain string
+	appMenu   menus.ApplicationMenu
+	rm        *results.Manager
+	locale    *locale.Manager
+}
+
+func NewSessionsApp(ctx context.Context, bot *tgbotapi.BotAPI, domain string, appMenu menus.ApplicationMenu, store results.Storer, lm *locale.Manager) *SessionsApp {
+	return &SessionsApp{
+		apiDomain: domain,
+		bot:       bot,
+		appMenu:   appMenu,
+		rm:        results.NewManager(bot, store, lm),
+		locale:    lm,
+	}
 }
 
-func NewSessionsApp(ctx context.Context, bot *tgbotapi.BotAPI, domain string, appMenu menus.ApplicationMenu) *SessionsApp {
-	menuKeyboard := tgbotapi.NewReplyKeyboard(
+func (sa *SessionsApp) menuKeyboard(loc *i18n.Localizer) tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton(appMenu.ButtonBackTo()),
+			tgbotapi.NewKeyboardButton(locale.Localize(loc, msgButtonPractice)),
+			tgbotapi.NewKeyboardButton(locale.Localize(loc, msgButtonQualifying)),
+			tgbotapi.NewKeyboardButton(locale.Localize(loc, msgButtonRace)),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(apps.ButtonBackTo(sa.appMenu, loc)),
 		),
 	)
-
-	return &SessionsApp{
-		apiDomain:    domain,
-		bot:          bot,
-		appMenu:      appMenu,
-		menuKeyboard: menuKeyboard,
-	}
 }
 
 func (sa *SessionsApp) AcceptCommand(command string) (bool, func(ctx context.Context, chatId int64) error) {
+	if commandResultId.MatchString(command) {
+		// show the classification of an archived session
+		sessionId, _ := strconv.ParseInt(commandResultId.FindStringSubmatch(command)[1], 10, 64)
+		return true, sa.rm.RenderResult(sessionId)
+	}
 	return false, nil
 }
 
 func (sa *SessionsApp) AcceptCallback(query *tgbotapi.CallbackQuery) (bool, func(ctx context.Context, query *tgbotapi.CallbackQuery) error) {
+	data := strings.Split(query.Data, ":")
+	if data[0] == results.SubcommandShowSessions {
+		return true, sa.rm.RenderShowSessionsCallback(data)
+	} else if data[0] == results.SubcommandShowResultData {
+		return true, sa.rm.RenderResultDataCallback(data)
+	}
 	return false, nil
 }
 
@@ -43,19 +81,26 @@ func (sa *SessionsApp) AcceptButton(button string) (bool, func(ctx context.Conte
 	// fmt.Printf("SESSIONS: button: %s. appName: %s\n", button, sa.appMenu.Name)
 	if button == sa.appMenu.Name {
 		return true, func(ctx context.Context, chatId int64) error {
-			message := fmt.Sprintf("%s application\n\n", sa.appMenu.Name)
+			loc := sa.locale.Localizer(ctx)
+			message := fmt.Sprintf(locale.Localize(loc, msgApplication)+"\n\n", sa.appMenu.Name)
 			msg := tgbotapi.NewMessage(chatId, message)
-			msg.ReplyMarkup = sa.menuKeyboard
+			msg.ReplyMarkup = sa.menuKeyboard(loc)
 			_, err := sa.bot.Send(msg)
 			return err
 		}
-	} else if button == sa.appMenu.ButtonBackTo() {
+	} else if apps.IsButtonBackTo(sa.locale, sa.appMenu, button) {
 		return true, func(ctx context.Context, chatId int64) error {
 			msg := tgbotapi.NewMessage(chatId, "OK")
 			msg.ReplyMarkup = sa.appMenu.PrevMenu()
 			_, err := sa.bot.Send(msg)
 			return err
 		}
+	} else if sa.locale.Is(button, msgButtonPractice) {
+		return true, sa.rm.RenderSessions(results.KindPractice)
+	} else if sa.locale.Is(button, msgButtonQualifying) {
+		return true, sa.rm.RenderSessions(results.KindQualifying)
+	} else if sa.locale.Is(button, msgButtonRace) {
+		return true, sa.rm.RenderSessions(results.KindRace)
 	}
 	// fmt.Print("SESSIONS: FALSE\n")
 	return false, nil
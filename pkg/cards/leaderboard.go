@@ -0,0 +1,298 @@
+package cards
+
+import (
+	"bytes"
+	"f1champshotlapsbot/pkg/fonts"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dkit"
+)
+
+const (
+	SectorNone SectorColor = iota
+	// SectorPurple is the fastest sector of the leaderboard
+	SectorPurple
+	// SectorGreen is the personal best sector of the driver
+	SectorGreen
+	// SectorYellow is a sector slower than the personal best
+	SectorYellow
+
+	brand = "F1CHAMPS"
+
+	width        = 960.0
+	padding      = 24.0
+	bannerHeight = 96.0
+	headerHeight = 36.0
+	rowHeight    = 40.0
+	footerHeight = 36.0
+	sectorsWidth = 150.0
+	iconRadius   = 12.0
+)
+
+var (
+	colorBackground = color.RGBA{0x15, 0x15, 0x1e, 0xff}
+	colorBanner     = color.RGBA{0xe1, 0x06, 0x00, 0xff}
+	colorRowOdd     = color.RGBA{0x1f, 0x1f, 0x2b, 0xff}
+	colorText       = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colorMuted      = color.RGBA{0x9a, 0x9a, 0xad, 0xff}
+	colorPurple     = color.RGBA{0xa8, 0x3a, 0xe8, 0xff}
+	colorGreen      = color.RGBA{0x2e, 0xcc, 0x40, 0xff}
+	colorYellow     = color.RGBA{0xf5, 0xc5, 0x18, 0xff}
+	colorNone       = color.RGBA{0x44, 0x44, 0x55, 0xff}
+
+	sectorColors = map[SectorColor]color.Color{
+		SectorNone:   colorNone,
+		SectorPurple: colorPurple,
+		SectorGreen:  colorGreen,
+		SectorYellow: colorYellow,
+	}
+
+	// compounds maps the start of the compound name to its icon letter and colour
+	compounds = []struct {
+		prefix string
+		letter string
+		color  color.Color
+	}{
+		{"soft", "S", color.RGBA{0xe8, 0x00, 0x2d, 0xff}},
+		{"medium", "M", color.RGBA{0xff, 0xd1, 0x2e, 0xff}},
+		{"hard", "H", color.RGBA{0xf0, 0xf0, 0xf0, 0xff}},
+		{"inter", "I", color.RGBA{0x43, 0xb0, 0x2a, 0xff}},
+		{"wet", "W", color.RGBA{0x00, 0x67, 0xad, 0xff}},
+	}
+
+	// column positions, from the left border of the card
+	columnPosition = padding
+	columnDriver   = padding + 56
+	columnClass    = padding + 360
+	columnTime     = padding + 500
+	columnGap      = padding + 620
+	columnSectors  = padding + 730
+	columnCompound = width - padding - iconRadius
+)
+
+type SectorColor int
+
+// Sector is a sector time of a row and how it compares with the rest.
+type Sector struct {
+	Time  float64
+	Color SectorColor
+}
+
+// Header holds the labels of the columns, already localized.
+type Header struct {
+	Position string
+	Driver   string
+	Class    string
+	Time     string
+	Gap      string
+	Sectors  string
+	Compound string
+}
+
+type Row struct {
+	Position int
+	Driver   string
+	CarClass string
+	Time     string
+	Gap      string
+	Sectors  [3]Sector
+	// Compound is the compound name as given by the game, e.g. "Soft"
+	Compound string
+}
+
+// Leaderboard is the content of a leaderboard card.
+type Leaderboard struct {
+	Title    string
+	Subtitle string
+	Header   Header
+	Rows     []Row
+	// Footer is shown at the bottom of the card, e.g. to tell rows are missing
+	Footer string
+}
+
+// RenderLeaderboard draws the leaderboard card and encodes it as PNG.
+func RenderLeaderboard(lb Leaderboard) ([]byte, error) {
+	cache, err := fonts.Cache()
+	if err != nil {
+		return nil, err
+	}
+	if len(lb.Rows) == 0 {
+		return nil, fmt.Errorf("leaderboard %q has no rows", lb.Title)
+	}
+
+	height := bannerHeight + headerHeight + rowHeight*float64(len(lb.Rows)) + footerHeight
+	dest := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	gc := draw2dimg.NewGraphicContext(dest)
+	gc.FontCache = cache
+	// font sizes are given in pixels
+	gc.SetDPI(72)
+
+	fillRect(gc, 0, 0, width, height, colorBackground)
+	drawBanner(gc, lb)
+
+	y := bannerHeight
+	drawHeader(gc, lb.Header, y)
+	y += headerHeight
+	for i, row := range lb.Rows {
+		if i%2 == 0 {
+			fillRect(gc, 0, y, width, y+rowHeight, colorRowOdd)
+		}
+		drawRow(gc, row, y)
+		y += rowHeight
+	}
+
+	gc.SetFontData(fonts.Regular)
+	gc.SetFontSize(14)
+	gc.SetFillColor(colorMuted)
+	gc.FillStringAt(lb.Footer, padding, y+footerHeight/2+5)
+
+	var b bytes.Buffer
+	err = png.Encode(&b, dest)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func drawBanner(gc *draw2dimg.GraphicContext, lb Leaderboard) {
+	fillRect(gc, 0, 0, width, 6, colorBanner)
+
+	gc.SetFontData(fonts.Bold)
+	gc.SetFontSize(30)
+	gc.SetFillColor(colorText)
+	gc.FillStringAt(fit(gc, lb.Title, width-3*padding-120), padding, 46)
+
+	gc.SetFontData(fonts.Regular)
+	gc.SetFontSize(18)
+	gc.SetFillColor(colorMuted)
+	gc.FillStringAt(fit(gc, lb.Subtitle, width-2*padding), padding, 78)
+
+	gc.SetFontData(fonts.Bold)
+	gc.SetFontSize(20)
+	gc.SetFillColor(colorBanner)
+	left, _, right, _ := gc.GetStringBounds(brand)
+	gc.FillStringAt(brand, width-padding-(right-left), 44)
+}
+
+func drawHeader(gc *draw2dimg.GraphicContext, header Header, y float64) {
+	gc.SetFontData(fonts.Bold)
+	gc.SetFontSize(14)
+	gc.SetFillColor(colorMuted)
+	baseline := y + headerHeight/2 + 5
+	gc.FillStringAt(header.Position, columnPosition, baseline)
+	gc.FillStringAt(header.Driver, columnDriver, baseline)
+	gc.FillStringAt(header.Class, columnClass, baseline)
+	gc.FillStringAt(header.Time, columnTime, baseline)
+	gc.FillStringAt(header.Gap, columnGap, baseline)
+	gc.FillStringAt(header.Sectors, columnSectors, baseline)
+	left, _, right, _ := gc.GetStringBounds(header.Compound)
+	gc.FillStringAt(header.Compound, columnCompound-(right-left)/2, baseline)
+}
+
+func drawRow(gc *draw2dimg.GraphicContext, row Row, y float64) {
+	baseline := y + rowHeight/2 + 6
+
+	gc.SetFontData(fonts.Bold)
+	gc.SetFontSize(18)
+	gc.SetFillColor(colorText)
+	gc.FillStringAt(fmt.Sprint(row.Position), columnPosition, baseline)
+	gc.FillStringAt(fit(gc, row.Driver, columnClass-columnDriver-12), columnDriver, baseline)
+
+	gc.SetFontData(fonts.Regular)
+	gc.SetFontSize(15)
+	gc.SetFillColor(colorMuted)
+	gc.FillStringAt(fit(gc, row.CarClass, columnTime-columnClass-12), columnClass, baseline)
+
+	gc.SetFontData(fonts.Monospace)
+	gc.SetFontSize(17)
+	gc.SetFillColor(colorText)
+	gc.FillStringAt(row.Time, columnTime, baseline)
+	gc.SetFillColor(colorMuted)
+	gc.FillStringAt(row.Gap, columnGap, baseline)
+
+	drawSectors(gc, row.Sectors, y+rowHeight/2)
+	drawCompound(gc, row.Compound, columnCompound, y+rowHeight/2)
+}
+
+// drawSectors draws a bar for every sector, as long as its share of the lap.
+// The bars are equally long when any sector time is missing.
+func drawSectors(gc *draw2dimg.GraphicContext, sectors [3]Sector, centerY float64) {
+	const gap = 4.0
+	total := 0.0
+	for _, s := range sectors {
+		if s.Time <= 0 {
+			total = 0
+			break
+		}
+		total += s.Time
+	}
+	x := columnSectors
+	for _, s := range sectors {
+		w := (sectorsWidth - 2*gap) / 3
+		if total > 0 {
+			w = (sectorsWidth - 2*gap) * s.Time / total
+		}
+		gc.SetFillColor(sectorColors[s.Color])
+		gc.BeginPath()
+		draw2dkit.RoundedRectangle(gc, x, centerY-5, x+w, centerY+5, 6, 6)
+		gc.Fill()
+		x += w + gap
+	}
+}
+
+func drawCompound(gc *draw2dimg.GraphicContext, compound string, centerX, centerY float64) {
+	letter, c := compoundIcon(compound)
+	gc.SetStrokeColor(c)
+	gc.SetFillColor(colorBackground)
+	gc.SetLineWidth(4)
+	gc.BeginPath()
+	draw2dkit.Circle(gc, centerX, centerY, iconRadius)
+	gc.FillStroke()
+
+	gc.SetFontData(fonts.Bold)
+	gc.SetFontSize(13)
+	gc.SetFillColor(colorText)
+	left, top, right, bottom := gc.GetStringBounds(letter)
+	gc.FillStringAt(letter, centerX-(right-left)/2-left, centerY+(bottom-top)/2-bottom)
+}
+
+func compoundIcon(compound string) (string, color.Color) {
+	name := strings.ToLower(strings.TrimSpace(compound))
+	for _, c := range compounds {
+		if strings.HasPrefix(name, c.prefix) {
+			return c.letter, c.color
+		}
+	}
+	if name == "" {
+		return "?", colorMuted
+	}
+	return strings.ToUpper(name[:1]), colorMuted
+}
+
+// fit shortens the text with an ellipsis until it is narrower than maxWidth
+// with the current font.
+func fit(gc *draw2dimg.GraphicContext, text string, maxWidth float64) string {
+	runes := []rune(text)
+	for len(runes) > 0 {
+		left, _, right, _ := gc.GetStringBounds(text)
+		if right-left <= maxWidth {
+			break
+		}
+		runes = runes[:len(runes)-1]
+		text = string(runes) + "…"
+	}
+	return text
+}
+
+func fillRect(gc draw2d.GraphicContext, x1, y1, x2, y2 float64, c color.Color) {
+	gc.SetFillColor(c)
+	gc.BeginPath()
+	draw2dkit.Rectangle(gc, x1, y1, x2, y2)
+	gc.Fill()
+}
@@ -0,0 +1,162 @@
+package circuits
+
+import (
+	"context"
+	"encoding/json"
+	"f1champshotlapsbot/pkg/locale"
+	"f1champshotlapsbot/pkg/serverset"
+	"f1champshotlapsbot/pkg/trackmap"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/model"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/pubsub"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/servers"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// fetchTimeout bounds the requests to the rFactor2 servers, which are
+	// made while the user waits for the answer
+	fetchTimeout = 10 * time.Second
+)
+
+// Circuit is the track selected in a server.
+type Circuit struct {
+	ID   string
+	Name string
+	AIW  trackmap.AIW
+	// SectorBoundaries are the distances along the racing line of the AIW data
+	// where the sectors 2 and 3 start. It is empty until they are learned from
+	// the live timing of the server.
+	SectorBoundaries []float64
+}
+
+// Manager draws the map and the elevation profile of the track selected in
+// every rFactor2 server. The AIW data of the tracks is downloaded from the
+// servers when it is first needed and kept in memory.
+type Manager struct {
+	bot     *tgbotapi.BotAPI
+	srvs    *serverset.Manager
+	locale  *locale.Manager
+	watched map[string]bool
+	// aiws holds the AIW data by track ID
+	aiws    map[string]trackmap.AIW
+	sectors map[string]*sectorLearner
+	mu      sync.Mutex
+}
+
+func NewManager(bot *tgbotapi.BotAPI, srvs *serverset.Manager, lm *locale.Manager) *Manager {
+	return &Manager{
+		bot:     bot,
+		srvs:    srvs,
+		locale:  lm,
+		watched: make(map[string]bool),
+		aiws:    make(map[string]trackmap.AIW),
+		sectors: make(map[string]*sectorLearner),
+	}
+}
+
+// Watch starts learning the sector boundaries of the tracks raced in a
+// server from its live timing. Watching the same server again does nothing,
+// so it is safe to call it for every server after reloading the
+// configuration.
+func (cm *Manager) Watch(serverId string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.watched[serverId] {
+		return
+	}
+	cm.watched[serverId] = true
+	sl := &sectorLearner{}
+	cm.sectors[serverId] = sl
+
+	// the pubsub blocks the publisher until every subscriber reads the data, so
+	// the handlers only update the memory
+	sessionInfoChan := pubsub.LiveSessionInfoDataPubSub.Subscribe(pubsub.PubSubSessionInfoPreffix + serverId)
+	standingsChan := pubsub.LiveStandingDataPubSub.Subscribe(pubsub.PubSubDriversSessionPreffix + serverId)
+	go func() {
+		for sessionInfo := range sessionInfoChan {
+			sl.updateSession(sessionInfo.SessionInfo)
+		}
+	}()
+	go func() {
+		for standings := range standingsChan {
+			sl.updateStandings(standings.Drivers)
+		}
+	}()
+}
+
+// GetServer returns the running server with the ID.
+func (cm *Manager) GetServer(serverId string) (servers.Server, bool) {
+	for _, srv := range cm.srvs.Servers() {
+		if srv.ID == serverId {
+			return srv, true
+		}
+	}
+	return servers.Server{}, false
+}
+
+// GetCircuit returns the track selected in the server. The sector boundaries
+// are set when they are known for that track.
+func (cm *Manager) GetCircuit(ctx context.Context, srv servers.Server) (Circuit, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	var selection model.SelectedSessionData
+	err := getJSON(ctx, fmt.Sprintf("%s/rest/race/selection", srv.URL), &selection)
+	if err != nil {
+		return Circuit{}, fmt.Errorf("error getting the selected track of %s: %w", srv.ID, err)
+	}
+	track := selection.Track
+	if track.ID == "" {
+		return Circuit{}, fmt.Errorf("there is no track selected in %s", srv.ID)
+	}
+
+	cm.mu.Lock()
+	aiw, found := cm.aiws[track.ID]
+	sl := cm.sectors[srv.ID]
+	cm.mu.Unlock()
+	if !found {
+		var waypoints []trackmap.Waypoint
+		err = getJSON(ctx, fmt.Sprintf("%s/rest/race/track/%s/trackmap", srv.URL, track.ID), &waypoints)
+		if err != nil {
+			return Circuit{}, fmt.Errorf("error getting the AIW data of %s: %w", track.ID, err)
+		}
+		aiw = trackmap.AIW(waypoints)
+		cm.mu.Lock()
+		cm.aiws[track.ID] = aiw
+		cm.mu.Unlock()
+	}
+
+	c := Circuit{
+		ID:   track.ID,
+		Name: track.Name,
+		AIW:  aiw,
+	}
+	if sl != nil {
+		c.SectorBoundaries = sl.boundaries(trackmap.NewPath(aiw.Filter(trackmap.TypeRacingLine)).Length())
+	}
+	return c, nil
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
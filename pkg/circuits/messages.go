@@ -0,0 +1,16 @@
+package circuits
+
+import "github.com/nicksnyder/go-i18n/v2/i18n"
+
+var (
+	msgNoServers       = &i18n.Message{ID: "circuits.noServers", Other: "There are no servers running"}
+	msgChooseServer    = &i18n.Message{ID: "circuits.chooseServer", Other: "Choose the server:"}
+	msgServerNotFound  = &i18n.Message{ID: "circuits.serverNotFound", Other: "The selected server was not found. Go back and try again"}
+	msgTrackNotFetched = &i18n.Message{ID: "circuits.trackNotFetched", Other: "The track of %s could not be fetched. The server may be offline"}
+	msgCaption         = &i18n.Message{ID: "circuits.caption", Other: "%s · %s"}
+	msgElevation       = &i18n.Message{ID: "circuits.elevation", Other: "Height difference: %.0f m"}
+	msgNoSectors       = &i18n.Message{ID: "circuits.noSectors", Other: "The sectors are marked once the cars have run a lap in the server"}
+
+	msgKeyboardMap     = &i18n.Message{ID: "circuits.keyboardMap", Other: "Map"}
+	msgKeyboardProfile = &i18n.Message{ID: "circuits.keyboardProfile", Other: "Profile"}
+)
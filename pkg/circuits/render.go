@@ -0,0 +1,161 @@
+package circuits
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/locale"
+	"f1champshotlapsbot/pkg/trackmap"
+	"fmt"
+	"image/color"
+	"log"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	SubcommandShowCircuit = "show_circuit"
+	CommandMap            = "/map"
+
+	inlineKeyboardMap     = "map"
+	inlineKeyboardProfile = "profile"
+	symbolMap             = "🗺"
+	symbolProfile         = "⛰"
+)
+
+var (
+	inlineKeyboardLabels = map[string]*i18n.Message{
+		inlineKeyboardMap:     msgKeyboardMap,
+		inlineKeyboardProfile: msgKeyboardProfile,
+	}
+)
+
+// RenderServers shows the map of the track selected in the server, or lets
+// the user choose the server when there are several.
+func (cm *Manager) RenderServers() func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := cm.locale.Localizer(ctx)
+		ss := cm.srvs.Servers()
+		if len(ss) == 0 {
+			message := locale.Localize(loc, msgNoServers)
+			msg := tgbotapi.NewMessage(chatId, message)
+			_, err := cm.bot.Send(msg)
+			return err
+		}
+		if len(ss) == 1 {
+			return SendCircuitData(ctx, chatId, nil, ss[0].ID, inlineKeyboardMap, cm, loc)
+		}
+
+		rows := [][]tgbotapi.InlineKeyboardButton{}
+		for _, srv := range ss {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(srv.Name, fmt.Sprintf("%s:%s:%s", SubcommandShowCircuit, inlineKeyboardMap, srv.ID)),
+			))
+		}
+		msg := tgbotapi.NewMessage(chatId, locale.Localize(loc, msgChooseServer))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		_, err := cm.bot.Send(msg)
+		return err
+	}
+}
+
+// RenderCircuitCallback shows the view of the track in the callback data. The
+// image is replaced when the callback comes from the buttons below it.
+func (cm *Manager) RenderCircuitCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+		loc := cm.locale.Localizer(ctx)
+		if len(data) < 3 {
+			return nil
+		}
+		var messageId *int
+		if len(query.Message.Photo) > 0 {
+			messageId = &query.Message.MessageID
+		}
+		return SendCircuitData(ctx, query.Message.Chat.ID, messageId, data[2], data[1], cm, loc)
+	}
+}
+
+// SendCircuitData sends the map or the elevation profile of the track
+// selected in the server, with the buttons to switch between them. The photo
+// of messageId is replaced when it is given.
+func SendCircuitData(ctx context.Context, chatId int64, messageId *int, serverId, infoType string, cm *Manager, loc *i18n.Localizer) error {
+	srv, found := cm.GetServer(serverId)
+	if !found {
+		message := locale.Localize(loc, msgServerNotFound)
+		msg := tgbotapi.NewMessage(chatId, message)
+		_, err := cm.bot.Send(msg)
+		return err
+	}
+	c, err := cm.GetCircuit(ctx, srv)
+	if err != nil {
+		log.Printf("Error getting the circuit of %s: %s", serverId, err.Error())
+		message := fmt.Sprintf(locale.Localize(loc, msgTrackNotFetched), srv.Name)
+		msg := tgbotapi.NewMessage(chatId, message)
+		_, err = cm.bot.Send(msg)
+		return err
+	}
+
+	var data []byte
+	caption := fmt.Sprintf(locale.Localize(loc, msgCaption), c.Name, srv.Name)
+	if infoType == inlineKeyboardProfile {
+		opts := trackmap.DefaultProfileOptions()
+		opts.SectorBoundaries = c.SectorBoundaries
+		data, err = trackmap.RenderProfilePNG(c.AIW, opts)
+		if b, berr := c.AIW.Filter(trackmap.TypeRacingLine).Bounds(); berr == nil {
+			caption += "\n" + fmt.Sprintf(locale.Localize(loc, msgElevation), b.MaxY-b.MinY)
+		}
+	} else {
+		infoType = inlineKeyboardMap
+		opts := trackmap.DefaultOptions()
+		opts.Background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+		opts.SectorBoundaries = c.SectorBoundaries
+		data, err = trackmap.RenderPNG(c.AIW, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("error drawing the %s of %s: %w", infoType, c.ID, err)
+	}
+	if len(c.SectorBoundaries) == 0 {
+		caption += "\n" + locale.Localize(loc, msgNoSectors)
+	}
+
+	file := tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("%s_%s.png", helper.ToID(c.Name), infoType),
+		Bytes: data,
+	}
+	keyboard := getInlineKeyboardForCircuit(serverId, loc)
+
+	var cfg tgbotapi.Chattable
+	if messageId == nil {
+		photo := tgbotapi.NewPhoto(chatId, file)
+		photo.Caption = caption
+		photo.ReplyMarkup = keyboard
+		cfg = photo
+	} else {
+		media := tgbotapi.NewInputMediaPhoto(file)
+		media.Caption = caption
+		cfg = tgbotapi.EditMessageMediaConfig{
+			BaseEdit: tgbotapi.BaseEdit{
+				ChatID:      chatId,
+				MessageID:   *messageId,
+				ReplyMarkup: &keyboard,
+			},
+			Media: media,
+		}
+	}
+	_, err = cm.bot.Send(cfg)
+	return err
+}
+
+func inlineKeyboardButton(loc *i18n.Localizer, infoType, symbol, serverId string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(locale.Localize(loc, inlineKeyboardLabels[infoType])+" "+symbol, fmt.Sprintf("%s:%s:%s", SubcommandShowCircuit, infoType, serverId))
+}
+
+func getInlineKeyboardForCircuit(serverId string, loc *i18n.Localizer) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			inlineKeyboardButton(loc, inlineKeyboardMap, symbolMap, serverId),
+			inlineKeyboardButton(loc, inlineKeyboardProfile, symbolProfile, serverId),
+		),
+	)
+}
@@ -0,0 +1,119 @@
+package circuits
+
+import (
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/model"
+)
+
+const (
+	// maxLengthMismatch is how much the length of the racing line of the AIW
+	// data can differ from the length of the track given by the server for
+	// the sectors learned to be drawn on it
+	maxLengthMismatch = 0.1
+)
+
+// sectorLearner finds where the sectors of a track start from the distance
+// of the cars when they enter a new sector. rFactor2 does not publish the
+// sector boundaries, but they lie between the last position of a car in a
+// sector and its first position in the next one.
+type sectorLearner struct {
+	trackName   string
+	trackLength float64
+	// before and after hold, for the sectors 2 and 3, the furthest distance
+	// seen in the previous sector and the closest one seen in the sector
+	before [2]float64
+	after  [2]float64
+	// last holds the sector and distance of every car by slot
+	last map[int]carSector
+	mu   sync.Mutex
+}
+
+type carSector struct {
+	sector   int
+	distance float64
+}
+
+func (sl *sectorLearner) updateSession(si model.SessionInfo) {
+	if si.TrackName == "" {
+		// sent when the server goes offline
+		return
+	}
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if si.TrackName != sl.trackName {
+		sl.trackName = si.TrackName
+		sl.before = [2]float64{}
+		sl.after = [2]float64{math.Inf(1), math.Inf(1)}
+		sl.last = make(map[int]carSector)
+	}
+	sl.trackLength = si.LapDistance
+}
+
+func (sl *sectorLearner) updateStandings(drivers []model.StandingDriverData) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if sl.last == nil {
+		// the track is not known yet
+		return
+	}
+	for _, d := range drivers {
+		sector := sectorNumber(d.Sector)
+		if sector == 0 || d.Pitting {
+			delete(sl.last, d.SlotID)
+			continue
+		}
+		prev, found := sl.last[d.SlotID]
+		sl.last[d.SlotID] = carSector{sector: sector, distance: d.LapDistance}
+		if !found || sector != prev.sector+1 || d.LapDistance <= prev.distance {
+			continue
+		}
+		// the car entered the sector 2 or 3 since the last update
+		i := sector - 2
+		sl.before[i] = math.Max(sl.before[i], prev.distance)
+		sl.after[i] = math.Min(sl.after[i], d.LapDistance)
+	}
+}
+
+// boundaries returns the distances where the sectors 2 and 3 start along a
+// racing line of the given length, or nil if they are not known yet.
+func (sl *sectorLearner) boundaries(length float64) []float64 {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if sl.trackLength <= 0 || math.Abs(length/sl.trackLength-1) > maxLengthMismatch {
+		return nil
+	}
+	scale := length / sl.trackLength
+	boundaries := []float64{}
+	for i := range sl.before {
+		if math.IsInf(sl.after[i], 1) {
+			return nil
+		}
+		b := (sl.before[i] + sl.after[i]) / 2 * scale
+		if b <= 0 {
+			return nil
+		}
+		boundaries = append(boundaries, b)
+	}
+	return boundaries
+}
+
+// sectorNumber returns the number of the sector reported by rFactor2, like
+// SECTOR2, or 0 if it is not known.
+func sectorNumber(sector string) int {
+	switch strings.ToUpper(sector) {
+	case "SECTOR1":
+		return 1
+	case "SECTOR2":
+		return 2
+	case "SECTOR3":
+		return 3
+	}
+	return 0
+}
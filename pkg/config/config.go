@@ -0,0 +1,272 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvConfigFile       = "CONFIG_FILE"
+	EnvTelegramToken    = "TELEGRAM_TOKEN"
+	EnvHotlapsDomain    = "API_DOMAIN"
+	EnvLiveMapDomain    = "LIVEMAP_DOMAIN"
+	EnvWebServerAddress = "WEBSERVER_ADDRESS"
+	EnvAPITimeout       = "API_TIMEOUT"
+	EnvAPIRetries       = "API_RETRIES"
+	// format: <telegram_user_id>,<telegram_user_id>,...
+	EnvAdmins = "ADMIN_IDS"
+	// format: <server_id>,<server_url>;<server_id>,<server_url>;...
+	// format example: "ServerID1,http://localhost:10001;ServerID2,http://localhost:10002;ServerID3,http://localhost:10003"
+	EnvServers = "RF2_SERVERS"
+
+	DefaultWebServerAddress = ":8080"
+	DefaultPollInterval     = 10 * time.Second
+	DefaultAPITimeout       = 15 * time.Second
+	DefaultAPIRetries       = 3
+	maxAPIRetries           = 10
+)
+
+type Config struct {
+	TelegramToken    string   `yaml:"telegramToken"`
+	APIDomain        string   `yaml:"apiDomain"`
+	LiveMapDomain    string   `yaml:"liveMapDomain"`
+	WebServerAddress string   `yaml:"webServerAddress"`
+	Servers          []Server `yaml:"servers"`
+	Admins           []int64  `yaml:"admins"`
+	// APITimeout bounds every request to the F1Champs API
+	APITimeout time.Duration `yaml:"apiTimeout"`
+	// APIRetries is the number of times a failed request to the F1Champs API
+	// is repeated, 0 disables the retries
+	APIRetries *int `yaml:"apiRetries"`
+}
+
+type Server struct {
+	ID           string        `yaml:"id"`
+	URL          string        `yaml:"url"`
+	Name         string        `yaml:"name"`
+	PollInterval time.Duration `yaml:"pollInterval"`
+	Enabled      *bool         `yaml:"enabled"`
+}
+
+// Load reads the configuration file, if any, applies the environment variables
+// on top of it and validates the result.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		decoder := yaml.NewDecoder(f)
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+		// an empty file is an empty configuration, left to the environment
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	}
+
+	err := cfg.applyEnv()
+	if err != nil {
+		return nil, err
+	}
+	cfg.applyDefaults()
+
+	err = cfg.Validate()
+	if err != nil {
+		if path != "" {
+			return nil, fmt.Errorf("invalid configuration in %s:\n%w", path, err)
+		}
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the whole configuration and reports every problem found.
+func (c *Config) Validate() error {
+	errs := []error{}
+	if c.TelegramToken == "" {
+		errs = append(errs, fmt.Errorf("telegramToken is not set (or %s)", EnvTelegramToken))
+	}
+	if err := validateURL(c.APIDomain); err != nil {
+		errs = append(errs, fmt.Errorf("apiDomain (or %s): %w", EnvHotlapsDomain, err))
+	}
+	if err := validateURL(c.LiveMapDomain); err != nil {
+		errs = append(errs, fmt.Errorf("liveMapDomain (or %s): %w", EnvLiveMapDomain, err))
+	}
+	if c.APITimeout < time.Second {
+		errs = append(errs, fmt.Errorf("apiTimeout (or %s) %s must be at least 1s", EnvAPITimeout, c.APITimeout))
+	}
+	if c.APIRetries != nil && (*c.APIRetries < 0 || *c.APIRetries > maxAPIRetries) {
+		errs = append(errs, fmt.Errorf("apiRetries (or %s) %d must be between 0 and %d", EnvAPIRetries, *c.APIRetries, maxAPIRetries))
+	}
+	if len(c.Servers) == 0 {
+		errs = append(errs, fmt.Errorf("servers is empty (or %s is not set)", EnvServers))
+	}
+
+	ids := map[string]int{}
+	enabled := 0
+	for i, s := range c.Servers {
+		if s.ID == "" {
+			errs = append(errs, fmt.Errorf("servers[%d].id is not set", i))
+		} else if strings.ContainsAny(s.ID, ":/ ") {
+			errs = append(errs, fmt.Errorf("servers[%d].id %q must not contain ':', '/' or spaces", i, s.ID))
+		} else if j, found := ids[s.ID]; found {
+			errs = append(errs, fmt.Errorf("servers[%d].id %q is already used by servers[%d]", i, s.ID, j))
+		} else {
+			ids[s.ID] = i
+		}
+		if err := validateURL(s.URL); err != nil {
+			errs = append(errs, fmt.Errorf("servers[%d].url: %w", i, err))
+		}
+		if s.PollInterval < time.Second {
+			errs = append(errs, fmt.Errorf("servers[%d].pollInterval %s must be at least 1s", i, s.PollInterval))
+		}
+		if s.IsEnabled() {
+			enabled++
+		}
+	}
+	if len(c.Servers) > 0 && enabled == 0 {
+		errs = append(errs, errors.New("all servers are disabled"))
+	}
+	for i, admin := range c.Admins {
+		if admin <= 0 {
+			errs = append(errs, fmt.Errorf("admins[%d] %d is not a Telegram user ID", i, admin))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsEnabled reports whether the server is enabled, which is the default.
+func (s Server) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+func (c *Config) applyEnv() error {
+	if token := os.Getenv(EnvTelegramToken); token != "" {
+		c.TelegramToken = token
+	}
+	if domain := os.Getenv(EnvHotlapsDomain); domain != "" {
+		c.APIDomain = domain
+	}
+	if domain := os.Getenv(EnvLiveMapDomain); domain != "" {
+		c.LiveMapDomain = domain
+	}
+	if addr := os.Getenv(EnvWebServerAddress); addr != "" {
+		c.WebServerAddress = addr
+	}
+	if timeout := os.Getenv(EnvAPITimeout); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", EnvAPITimeout, err)
+		}
+		c.APITimeout = d
+	}
+	if retries := os.Getenv(EnvAPIRetries); retries != "" {
+		n, err := strconv.Atoi(retries)
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", EnvAPIRetries, err)
+		}
+		c.APIRetries = &n
+	}
+	if rf2Servers := os.Getenv(EnvServers); rf2Servers != "" {
+		ss, err := parseServers(rf2Servers)
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", EnvServers, err)
+		}
+		c.Servers = ss
+	}
+	if adminIds := os.Getenv(EnvAdmins); adminIds != "" {
+		admins, err := parseAdmins(adminIds)
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", EnvAdmins, err)
+		}
+		c.Admins = admins
+	}
+	return nil
+}
+
+func (c *Config) applyDefaults() {
+	c.APIDomain = strings.TrimRight(c.APIDomain, "/")
+	c.LiveMapDomain = strings.TrimRight(c.LiveMapDomain, "/")
+	if c.WebServerAddress == "" {
+		c.WebServerAddress = DefaultWebServerAddress
+	}
+	if c.APITimeout == 0 {
+		c.APITimeout = DefaultAPITimeout
+	}
+	if c.APIRetries == nil {
+		retries := DefaultAPIRetries
+		c.APIRetries = &retries
+	}
+	for i := range c.Servers {
+		if c.Servers[i].Name == "" {
+			c.Servers[i].Name = c.Servers[i].ID
+		}
+		if c.Servers[i].PollInterval == 0 {
+			c.Servers[i].PollInterval = DefaultPollInterval
+		}
+	}
+}
+
+// parseServers reads the legacy RF2_SERVERS format. Only the first comma of
+// every entry separates the ID from the URL so URLs may contain commas.
+func parseServers(rf2Servers string) ([]Server, error) {
+	ss := []Server{}
+	for i, serverStr := range strings.Split(rf2Servers, ";") {
+		if strings.TrimSpace(serverStr) == "" {
+			continue
+		}
+		serverData := strings.SplitN(serverStr, ",", 2)
+		if len(serverData) != 2 {
+			return nil, fmt.Errorf("entry %d %q is not <server_id>,<server_url>", i, serverStr)
+		}
+		ss = append(ss, Server{
+			ID:  strings.TrimSpace(serverData[0]),
+			URL: strings.TrimSpace(serverData[1]),
+		})
+	}
+	return ss, nil
+}
+
+func parseAdmins(adminIds string) ([]int64, error) {
+	admins := []int64{}
+	for _, adminId := range strings.Split(adminIds, ",") {
+		if strings.TrimSpace(adminId) == "" {
+			continue
+		}
+		admin, err := strconv.ParseInt(strings.TrimSpace(adminId), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a Telegram user ID", adminId)
+		}
+		admins = append(admins, admin)
+	}
+	return admins, nil
+}
+
+func validateURL(rawURL string) error {
+	if rawURL == "" {
+		return errors.New("is not set")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: host is not set", rawURL)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets the environment variables read by Load for the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{EnvConfigFile, EnvTelegramToken, EnvHotlapsDomain, EnvLiveMapDomain, EnvWebServerAddress,
+		EnvAPITimeout, EnvAPIRetries, EnvAdmins, EnvServers} {
+		t.Setenv(env, "")
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func validConfig() *Config {
+	retries := DefaultAPIRetries
+	return &Config{
+		TelegramToken: "token",
+		APIDomain:     "https://api.example.com",
+		LiveMapDomain: "https://livemap.example.com",
+		APITimeout:    DefaultAPITimeout,
+		APIRetries:    &retries,
+		Servers: []Server{
+			{ID: "server1", URL: "http://localhost:10001", PollInterval: DefaultPollInterval},
+			{ID: "server2", URL: "http://localhost:10002", PollInterval: DefaultPollInterval},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{name: "valid", change: func(c *Config) {}},
+		{
+			name:   "missing token",
+			change: func(c *Config) { c.TelegramToken = "" },
+			want:   []string{"telegramToken is not set"},
+		},
+		{
+			name:   "duplicate IDs",
+			change: func(c *Config) { c.Servers[1].ID = "server1" },
+			want:   []string{`servers[1].id "server1" is already used by servers[0]`},
+		},
+		{
+			name:   "bad server URL",
+			change: func(c *Config) { c.Servers[0].URL = "localhost:10001" },
+			want:   []string{"servers[0].url", "scheme must be http or https"},
+		},
+		{
+			name:   "bad API domain",
+			change: func(c *Config) { c.APIDomain = "https://" },
+			want:   []string{"apiDomain", "host is not set"},
+		},
+		{
+			name:   "zero poll interval",
+			change: func(c *Config) { c.Servers[0].PollInterval = 0 },
+			want:   []string{"servers[0].pollInterval 0s must be at least 1s"},
+		},
+		{
+			name:   "negative poll interval",
+			change: func(c *Config) { c.Servers[1].PollInterval = -time.Second },
+			want:   []string{"servers[1].pollInterval -1s must be at least 1s"},
+		},
+		{
+			name:   "no servers",
+			change: func(c *Config) { c.Servers = nil },
+			want:   []string{"servers is empty"},
+		},
+		{
+			name: "every problem is reported",
+			change: func(c *Config) {
+				c.TelegramToken = ""
+				c.Servers[1].ID = "server1"
+				c.Servers[1].URL = ""
+			},
+			want: []string{"telegramToken is not set", "is already used by servers[0]", "servers[1].url: is not set"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.change(c)
+			err := c.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("expected a valid configuration, got %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error containing %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected the error to contain %q, got %q", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadEmptyFile(t *testing.T) {
+	for name, content := range map[string]string{"empty": "", "comments only": "# nothing configured yet\n"} {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			_, err := Load(writeConfig(t, content))
+			if err == nil {
+				t.Fatal("expected the empty configuration to be invalid")
+			}
+			if strings.Contains(err.Error(), "EOF") {
+				t.Errorf("expected the missing settings reported, got %q", err)
+			}
+			for _, want := range []string{"telegramToken is not set", "servers is empty"} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected the error to contain %q, got %q", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, `
+telegramToken: file-token
+apiDomain: https://file.example.com/
+liveMapDomain: https://livemap.example.com
+servers:
+  - id: file
+    url: http://localhost:9999
+`)
+	t.Setenv(EnvTelegramToken, "env-token")
+	t.Setenv(EnvHotlapsDomain, "https://env.example.com/")
+	t.Setenv(EnvAPITimeout, "5s")
+	t.Setenv(EnvAPIRetries, "0")
+	t.Setenv(EnvAdmins, "1, 2,")
+	t.Setenv(EnvServers, "server1,http://localhost:10001;server2,http://localhost:10002")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("error loading the configuration: %s", err)
+	}
+	if cfg.TelegramToken != "env-token" {
+		t.Errorf("expected the token of the environment, got %q", cfg.TelegramToken)
+	}
+	if cfg.APIDomain != "https://env.example.com" {
+		t.Errorf("expected the API domain of the environment without the trailing slash, got %q", cfg.APIDomain)
+	}
+	if cfg.LiveMapDomain != "https://livemap.example.com" {
+		t.Errorf("expected the live map domain of the file, got %q", cfg.LiveMapDomain)
+	}
+	if cfg.APITimeout != 5*time.Second || *cfg.APIRetries != 0 {
+		t.Errorf("expected a timeout of 5s and no retries, got %s and %d", cfg.APITimeout, *cfg.APIRetries)
+	}
+	if len(cfg.Admins) != 2 || cfg.Admins[0] != 1 || cfg.Admins[1] != 2 {
+		t.Errorf("expected the admins 1 and 2, got %v", cfg.Admins)
+	}
+	if len(cfg.Servers) != 2 || cfg.Servers[0].ID != "server1" || cfg.Servers[1].URL != "http://localhost:10002" {
+		t.Fatalf("expected the servers of the environment, got %+v", cfg.Servers)
+	}
+	if cfg.Servers[0].Name != "server1" || cfg.Servers[0].PollInterval != DefaultPollInterval {
+		t.Errorf("expected the defaults of the server, got %+v", cfg.Servers[0])
+	}
+	if cfg.WebServerAddress != DefaultWebServerAddress {
+		t.Errorf("expected the default web server address, got %q", cfg.WebServerAddress)
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	for env, value := range map[string]string{EnvAPITimeout: "soon", EnvAPIRetries: "many", EnvAdmins: "admin", EnvServers: "server1"} {
+		t.Run(env, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv(EnvTelegramToken, "token")
+			t.Setenv(env, value)
+			_, err := Load("")
+			if err == nil || !strings.Contains(err.Error(), "error parsing "+env) {
+				t.Errorf("expected an error parsing %s, got %v", env, err)
+			}
+		})
+	}
+}
+
+func TestParseServers(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Server
+		wantErr bool
+	}{
+		{
+			name:  "single",
+			input: "server1,http://localhost:10001",
+			want:  []Server{{ID: "server1", URL: "http://localhost:10001"}},
+		},
+		{
+			name:  "several with spaces and a trailing separator",
+			input: " server1 , http://localhost:10001 ;server2,http://localhost:10002;",
+			want:  []Server{{ID: "server1", URL: "http://localhost:10001"}, {ID: "server2", URL: "http://localhost:10002"}},
+		},
+		{
+			name:  "URL with commas",
+			input: "server1,http://localhost:10001/?ids=1,2,3;server2,http://localhost:10002",
+			want:  []Server{{ID: "server1", URL: "http://localhost:10001/?ids=1,2,3"}, {ID: "server2", URL: "http://localhost:10002"}},
+		},
+		{name: "empty", input: ";", want: []Server{}},
+		{name: "missing URL", input: "server1;server2,http://localhost:10002", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseServers(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+			for i := range tt.want {
+				if got[i].ID != tt.want[i].ID || got[i].URL != tt.want[i].URL {
+					t.Errorf("expected %+v, got %+v", tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
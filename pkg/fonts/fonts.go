@@ -0,0 +1,59 @@
+package fonts
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/llgcode/draw2d"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+var (
+	Regular   = draw2d.FontData{Name: "goregular"}
+	Bold      = draw2d.FontData{Name: "gobold"}
+	Monospace = draw2d.FontData{Name: "gomono"}
+
+	once     sync.Once
+	cacheErr error
+	cache    = fontCache{}
+)
+
+// fontCache holds the fonts by name. draw2d looks the fonts up in a cache,
+// which by default reads them from a folder.
+type fontCache map[string]*truetype.Font
+
+func (fc fontCache) Load(fd draw2d.FontData) (*truetype.Font, error) {
+	font, found := fc[fd.Name]
+	if !found {
+		return nil, fmt.Errorf("font %q not found", fd.Name)
+	}
+	return font, nil
+}
+
+func (fc fontCache) Store(fd draw2d.FontData, font *truetype.Font) {
+	fc[fd.Name] = font
+}
+
+// Cache returns the cache with the Go fonts bundled in the binary, so the
+// images do not depend on the fonts installed in the host. It is meant to be
+// set as the FontCache of the graphic contexts.
+func Cache() (draw2d.FontCache, error) {
+	once.Do(func() {
+		for fd, ttf := range map[draw2d.FontData][]byte{
+			Regular:   goregular.TTF,
+			Bold:      gobold.TTF,
+			Monospace: gomono.TTF,
+		} {
+			font, err := truetype.Parse(ttf)
+			if err != nil {
+				cacheErr = err
+				return
+			}
+			cache.Store(fd, font)
+		}
+	})
+	return cache, cacheErr
+}
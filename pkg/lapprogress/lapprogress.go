@@ -0,0 +1,138 @@
+package lapprogress
+
+import (
+	"f1champshotlapsbot/pkg/locale"
+	"f1champshotlapsbot/pkg/serverset"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/model"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/pubsub"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/servers"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	// barLength is the number of characters of the progress bars, short
+	// enough to fit in the screen of a phone
+	barLength = 20
+
+	barLeft       = "|"
+	barRight      = "🏁"
+	barFinished   = "-"
+	barUnfinished = " "
+)
+
+// Manager shows how much of the current lap every driver has completed, for
+// the users that cannot open the live map. The messages are edited with the
+// live timing of the servers while the chats follow them.
+type Manager struct {
+	bot     *tgbotapi.BotAPI
+	srvs    *serverset.Manager
+	locale  *locale.Manager
+	watched map[string]bool
+	// sessions and standings hold the last live timing of every server, keyed
+	// by server ID
+	sessions  map[string]model.LiveSessionInfoData
+	standings map[string][]model.StandingDriverData
+	// followers holds the message being updated in every chat
+	followers map[int64]*follower
+	mu        sync.Mutex
+}
+
+func NewManager(bot *tgbotapi.BotAPI, srvs *serverset.Manager, lm *locale.Manager) *Manager {
+	return &Manager{
+		bot:       bot,
+		srvs:      srvs,
+		locale:    lm,
+		watched:   make(map[string]bool),
+		sessions:  make(map[string]model.LiveSessionInfoData),
+		standings: make(map[string][]model.StandingDriverData),
+		followers: make(map[int64]*follower),
+	}
+}
+
+// Watch starts following the live timing of a server. Watching the same
+// server again does nothing, so it is safe to call it for every server after
+// reloading the configuration.
+func (pm *Manager) Watch(serverId string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if pm.watched[serverId] {
+		return
+	}
+	pm.watched[serverId] = true
+
+	// the pubsub blocks the publisher until every subscriber reads the data, so
+	// the handlers only update the memory
+	sessionInfoChan := pubsub.LiveSessionInfoDataPubSub.Subscribe(pubsub.PubSubSessionInfoPreffix + serverId)
+	standingsChan := pubsub.LiveStandingDataPubSub.Subscribe(pubsub.PubSubDriversSessionPreffix + serverId)
+	go func() {
+		for sessionInfo := range sessionInfoChan {
+			pm.mu.Lock()
+			pm.sessions[serverId] = sessionInfo
+			pm.mu.Unlock()
+		}
+	}()
+	go func() {
+		for standings := range standingsChan {
+			pm.mu.Lock()
+			pm.standings[serverId] = standings.Drivers
+			pm.mu.Unlock()
+		}
+	}()
+}
+
+func (pm *Manager) getServer(serverId string) (servers.Server, bool) {
+	for _, srv := range pm.srvs.Servers() {
+		if srv.ID == serverId {
+			return srv, true
+		}
+	}
+	return servers.Server{}, false
+}
+
+// progressText returns the progress of the drivers in the current lap of the
+// session running in the server, ordered by position, as a monospace block.
+// It is false when there is no session running.
+func (pm *Manager) progressText(serverId string, loc *i18n.Localizer) (string, bool) {
+	pm.mu.Lock()
+	data, found := pm.sessions[serverId]
+	drivers := append([]model.StandingDriverData{}, pm.standings[serverId]...)
+	pm.mu.Unlock()
+
+	si := data.SessionInfo
+	if !found || si.Session == "" || si.LapDistance <= 0 || len(drivers) == 0 {
+		return "", false
+	}
+	sort.Slice(drivers, func(i, j int) bool {
+		return drivers[i].Position < drivers[j].Position
+	})
+
+	var b strings.Builder
+	for _, d := range drivers {
+		fraction := math.Max(0, math.Min(1, d.LapDistance/si.LapDistance))
+		line := fmt.Sprintf("%02d %-3s %s %3.0f%%", d.Position, helper.GetDriverCodeName(d.DriverName), progressBar(fraction), fraction*100)
+		if d.Pitting || d.InGarageStall {
+			line += " " + locale.Localize(loc, msgPit)
+		}
+		b.WriteString(line + "\n")
+	}
+	remainingTime := helper.SecondsToHoursAndMinutes(si.EndEventTime - si.CurrentEventTime)
+	title := fmt.Sprintf(locale.Localize(loc, msgTitle), si.TrackName, si.Session, data.ServerName, remainingTime)
+	return fmt.Sprintf("%s\n\n%s", title, b.String()), true
+}
+
+// progressBar draws the part of the lap completed, with the same characters
+// as the trackers of go-pretty.
+func progressBar(fraction float64) string {
+	finished := int(math.Round(fraction * barLength))
+	return barLeft + strings.Repeat(barFinished, finished) + strings.Repeat(barUnfinished, barLength-finished) + barRight
+}
@@ -0,0 +1,16 @@
+package lapprogress
+
+import "github.com/nicksnyder/go-i18n/v2/i18n"
+
+var (
+	msgNoServers      = &i18n.Message{ID: "lapprogress.noServers", Other: "There are no servers running"}
+	msgChooseServer   = &i18n.Message{ID: "lapprogress.chooseServer", Other: "Choose the server:"}
+	msgServerNotFound = &i18n.Message{ID: "lapprogress.serverNotFound", Other: "The selected server was not found. Go back and try again"}
+	msgNoSession      = &i18n.Message{ID: "lapprogress.noSession", Other: "There is no session running in %s"}
+	msgTitle          = &i18n.Message{ID: "lapprogress.title", Other: "%s · %s\n%s · Time left: %s"}
+	msgPit            = &i18n.Message{ID: "lapprogress.pit", Other: "PIT"}
+	msgStopped        = &i18n.Message{ID: "lapprogress.stopped", Other: "Not updated anymore"}
+
+	msgKeyboardStop   = &i18n.Message{ID: "lapprogress.keyboardStop", Other: "Stop"}
+	msgKeyboardFollow = &i18n.Message{ID: "lapprogress.keyboardFollow", Other: "Follow"}
+)
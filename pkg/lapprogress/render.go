@@ -0,0 +1,234 @@
+package lapprogress
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	SubcommandFollowProgress = "follow_progress"
+	SubcommandStopProgress   = "stop_progress"
+	CommandPositions         = "/positions"
+
+	// refreshInterval is the time between the edits of a message, slow
+	// enough for the limits of Telegram in groups
+	refreshInterval = 5 * time.Second
+	// followTime is how long a message is updated after it is requested
+	followTime = 10 * time.Minute
+
+	symbolStop   = "⏹"
+	symbolFollow = "▶"
+)
+
+// follower is the message of a chat being updated.
+type follower struct {
+	messageId int
+	stop      context.CancelFunc
+}
+
+// RenderServers shows the progress of the drivers in the server, or lets the
+// user choose the server when there are several.
+func (pm *Manager) RenderServers() func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := pm.locale.Localizer(ctx)
+		ss := pm.srvs.Servers()
+		if len(ss) == 0 {
+			message := locale.Localize(loc, msgNoServers)
+			msg := tgbotapi.NewMessage(chatId, message)
+			_, err := pm.bot.Send(msg)
+			return err
+		}
+		if len(ss) == 1 {
+			return pm.followProgress(ctx, chatId, nil, ss[0].ID, loc)
+		}
+
+		rows := [][]tgbotapi.InlineKeyboardButton{}
+		for _, srv := range ss {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(srv.Name, fmt.Sprintf("%s:%s", SubcommandFollowProgress, srv.ID)),
+			))
+		}
+		msg := tgbotapi.NewMessage(chatId, locale.Localize(loc, msgChooseServer))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		_, err := pm.bot.Send(msg)
+		return err
+	}
+}
+
+// RenderFollowCallback shows the progress of the drivers in the message of
+// the callback and keeps it updated.
+func (pm *Manager) RenderFollowCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+		loc := pm.locale.Localizer(ctx)
+		if len(data) < 2 {
+			return nil
+		}
+		return pm.followProgress(ctx, query.Message.Chat.ID, &query.Message.MessageID, data[1], loc)
+	}
+}
+
+// RenderStopCallback stops updating the message of the callback.
+func (pm *Manager) RenderStopCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+		loc := pm.locale.Localizer(ctx)
+		if len(data) < 2 {
+			return nil
+		}
+		chatId := query.Message.Chat.ID
+		pm.mu.Lock()
+		f, found := pm.followers[chatId]
+		pm.mu.Unlock()
+		if found && f.messageId == query.Message.MessageID {
+			// the follower shows it is stopped
+			f.stop()
+			return nil
+		}
+		text, running := pm.progressText(data[1], loc)
+		if !running {
+			return pm.sendNoSession(chatId, &query.Message.MessageID, data[1], loc)
+		}
+		_, err := pm.sendProgress(chatId, &query.Message.MessageID, stoppedText(text, loc), getInlineKeyboardFollow(data[1], loc))
+		return err
+	}
+}
+
+// followProgress sends the progress of the drivers, or replaces the message
+// given, and updates it every refreshInterval for followTime. A chat only
+// follows one message, the previous one is stopped.
+func (pm *Manager) followProgress(ctx context.Context, chatId int64, messageId *int, serverId string, loc *i18n.Localizer) error {
+	text, running := pm.progressText(serverId, loc)
+	if !running {
+		return pm.sendNoSession(chatId, messageId, serverId, loc)
+	}
+	msg, err := pm.sendProgress(chatId, messageId, text, getInlineKeyboardStop(serverId, loc))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, followTime)
+	f := &follower{messageId: msg.MessageID, stop: cancel}
+	pm.mu.Lock()
+	previous, found := pm.followers[chatId]
+	pm.followers[chatId] = f
+	pm.mu.Unlock()
+	if found {
+		previous.stop()
+	}
+	go pm.follow(ctx, chatId, f, serverId, text, loc)
+	return nil
+}
+
+func (pm *Manager) follow(ctx context.Context, chatId int64, f *follower, serverId, last string, loc *i18n.Localizer) {
+	defer func() {
+		f.stop()
+		pm.mu.Lock()
+		if pm.followers[chatId] == f {
+			delete(pm.followers, chatId)
+		}
+		pm.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			pm.mu.Lock()
+			current := pm.followers[chatId]
+			pm.mu.Unlock()
+			if current != nil && current != f && current.messageId == f.messageId {
+				// the message is followed again
+				return
+			}
+			_, err := pm.sendProgress(chatId, &f.messageId, stoppedText(last, loc), getInlineKeyboardFollow(serverId, loc))
+			if err != nil {
+				log.Printf("Error stopping the progress of chat %d: %s", chatId, err.Error())
+			}
+			return
+		case <-ticker.C:
+			text, running := pm.progressText(serverId, loc)
+			if !running {
+				err := pm.sendNoSession(chatId, &f.messageId, serverId, loc)
+				if err != nil {
+					log.Printf("Error updating the progress of chat %d: %s", chatId, err.Error())
+				}
+				return
+			}
+			// Telegram rejects the edits that do not change the message
+			if text == last {
+				continue
+			}
+			_, err := pm.sendProgress(chatId, &f.messageId, text, getInlineKeyboardStop(serverId, loc))
+			if err != nil {
+				// the message may have been deleted
+				log.Printf("Error updating the progress of chat %d: %s", chatId, err.Error())
+				return
+			}
+			last = text
+		}
+	}
+}
+
+func (pm *Manager) sendNoSession(chatId int64, messageId *int, serverId string, loc *i18n.Localizer) error {
+	srv, found := pm.getServer(serverId)
+	if !found {
+		message := locale.Localize(loc, msgServerNotFound)
+		_, err := pm.sendOrEdit(chatId, messageId, message, "", nil)
+		return err
+	}
+	message := fmt.Sprintf(locale.Localize(loc, msgNoSession), srv.Name)
+	keyboard := getInlineKeyboardFollow(serverId, loc)
+	_, err := pm.sendOrEdit(chatId, messageId, message, "", &keyboard)
+	return err
+}
+
+// sendProgress sends the progress of the drivers, or replaces the message
+// given.
+func (pm *Manager) sendProgress(chatId int64, messageId *int, text string, keyboard tgbotapi.InlineKeyboardMarkup) (tgbotapi.Message, error) {
+	return pm.sendOrEdit(chatId, messageId, fmt.Sprintf("```\n%s```", text), tgbotapi.ModeMarkdownV2, &keyboard)
+}
+
+// sendOrEdit sends a new message or edits the given one with the text and keyboard.
+func (pm *Manager) sendOrEdit(chatId int64, messageId *int, text, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) (tgbotapi.Message, error) {
+	var cfg tgbotapi.Chattable
+	if messageId == nil {
+		msg := tgbotapi.NewMessage(chatId, text)
+		msg.ParseMode = parseMode
+		if keyboard != nil {
+			msg.ReplyMarkup = *keyboard
+		}
+		cfg = msg
+	} else {
+		msg := tgbotapi.NewEditMessageText(chatId, *messageId, text)
+		msg.ParseMode = parseMode
+		msg.ReplyMarkup = keyboard
+		cfg = msg
+	}
+	return pm.bot.Send(cfg)
+}
+
+func stoppedText(text string, loc *i18n.Localizer) string {
+	return text + "\n" + locale.Localize(loc, msgStopped)
+}
+
+func getInlineKeyboardStop(serverId string, loc *i18n.Localizer) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(locale.Localize(loc, msgKeyboardStop)+" "+symbolStop, fmt.Sprintf("%s:%s", SubcommandStopProgress, serverId)),
+		),
+	)
+}
+
+func getInlineKeyboardFollow(serverId string, loc *i18n.Localizer) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(locale.Localize(loc, msgKeyboardFollow)+" "+symbolFollow, fmt.Sprintf("%s:%s", SubcommandFollowProgress, serverId)),
+		),
+	)
+}
@@ -0,0 +1,153 @@
+package livemap
+
+import (
+	"encoding/json"
+	"f1champshotlapsbot/pkg/trackmap"
+	"log"
+	"math"
+	"sync"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/model"
+)
+
+var (
+	// classColors are given to the car classes in the order they show up in
+	// the session
+	classColors = []string{"#e10600", "#00a19c", "#f5c518", "#2b4562", "#ff8000", "#9b59b6", "#27ae60", "#ffffff"}
+)
+
+// Frame is the message sent to the viewers on every update of the standings.
+// It holds every car of the session, so the viewers remove the cars that are
+// not in it anymore.
+type Frame struct {
+	Session string `json:"session"`
+	Cars    []Car  `json:"cars"`
+	// Time and Duration are only sent in the replays, in milliseconds
+	Time     int64 `json:"time,omitempty"`
+	Duration int64 `json:"duration,omitempty"`
+}
+
+// Car is the position of a car in the map, in pixels.
+type Car struct {
+	SlotID   int     `json:"slotId"`
+	Code     string  `json:"code"`
+	Class    string  `json:"class"`
+	Color    string  `json:"color"`
+	Position int     `json:"position"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	InPit    bool    `json:"inPit"`
+}
+
+// hub broadcasts the frames of a session to all its viewers, so the frame is
+// built once for every update no matter how many viewers there are.
+type hub struct {
+	session    string
+	projection trackmap.Projection
+	svg        []byte
+	colors     map[string]string
+	viewers    map[chan []byte]bool
+	last       []byte
+	closed     bool
+	mu         sync.Mutex
+}
+
+func newHub(session string, projection trackmap.Projection, svg []byte) *hub {
+	return &hub{
+		session:    session,
+		projection: projection,
+		svg:        svg,
+		colors:     make(map[string]string),
+		viewers:    make(map[chan []byte]bool),
+	}
+}
+
+// subscribe returns the channel receiving the frames of the session, which is
+// closed when the session is over. The last frame is sent right away.
+func (h *hub) subscribe() chan []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// a slow viewer only gets the newest frame
+	ch := make(chan []byte, 1)
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	if h.last != nil {
+		ch <- h.last
+	}
+	h.viewers[ch] = true
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.viewers[ch] {
+		delete(h.viewers, ch)
+		close(ch)
+	}
+}
+
+// broadcast sends the positions of the drivers to the viewers. It does not
+// block, the frames not read yet by a viewer are replaced by the new one.
+func (h *hub) broadcast(drivers []model.StandingDriverData) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	frame := Frame{
+		Session: h.session,
+		Cars:    make([]Car, 0, len(drivers)),
+	}
+	for _, d := range drivers {
+		color, found := h.colors[d.CarClass]
+		if !found {
+			color = classColors[len(h.colors)%len(classColors)]
+			h.colors[d.CarClass] = color
+		}
+		x, y := h.projection.Point(d.CarPosition.X, d.CarPosition.Z)
+		// a tenth of a pixel is enough and keeps the frames short
+		x, y = math.Round(x*10)/10, math.Round(y*10)/10
+		frame.Cars = append(frame.Cars, Car{
+			SlotID:   d.SlotID,
+			Code:     helper.GetDriverCodeName(d.DriverName),
+			Class:    d.CarClass,
+			Color:    color,
+			Position: d.Position,
+			X:        x,
+			Y:        y,
+			InPit:    d.Pitting || d.InGarageStall,
+		})
+	}
+	data, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("Error encoding livemap frame: %s", err.Error())
+		return
+	}
+	h.last = data
+	for ch := range h.viewers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- data
+	}
+}
+
+// close disconnects the viewers.
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for ch := range h.viewers {
+		close(ch)
+	}
+	h.viewers = make(map[chan []byte]bool)
+}
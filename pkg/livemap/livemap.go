@@ -0,0 +1,269 @@
+package livemap
+
+import (
+	"context"
+	"errors"
+	"f1champshotlapsbot/pkg/circuits"
+	"f1champshotlapsbot/pkg/trackmap"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/model"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/pubsub"
+)
+
+const (
+	// Path is where the live maps of the servers are served
+	Path = "/livemap"
+
+	// writeWait is how long a frame can take to be sent to a viewer before
+	// it is disconnected
+	writeWait = 10 * time.Second
+)
+
+var (
+	upgrader = websocket.Upgrader{}
+
+	errNoSession = errors.New("there is no session running in the server")
+)
+
+// URL returns the public address of the live map of a server.
+func URL(domain, serverId string) string {
+	return fmt.Sprintf("%s%s/%s", domain, Path, serverId)
+}
+
+// Server serves a live map of the session running in every rFactor2 server.
+// The cars are streamed to the viewers over a WebSocket. There is a hub for
+// every session, created by its first viewer, which takes the positions of
+// the cars from the live timing of the server and sends them to all its
+// viewers.
+type Server struct {
+	cm      *circuits.Manager
+	watched map[string]bool
+	// sessions holds the session running in every server, keyed by server ID
+	sessions map[string]string
+	hubs     map[string]*hub
+	mu       sync.Mutex
+}
+
+func NewServer(cm *circuits.Manager) *Server {
+	return &Server{
+		cm:       cm,
+		watched:  make(map[string]bool),
+		sessions: make(map[string]string),
+		hubs:     make(map[string]*hub),
+	}
+}
+
+// Register adds the handlers of the live maps to the router: the page, the
+// track map and the WebSocket of every server.
+func (s *Server) Register(r *mux.Router) {
+	r.HandleFunc("/{server}", s.pageHandler)
+	r.HandleFunc("/{server}/track.svg", s.trackHandler)
+	r.HandleFunc("/{server}/ws", s.websocketHandler)
+}
+
+// Watch starts following the sessions of a server. Watching the same server
+// again does nothing, so it is safe to call it for every server after
+// reloading the configuration.
+func (s *Server) Watch(serverId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.watched[serverId] {
+		return
+	}
+	s.watched[serverId] = true
+
+	// the pubsub blocks the publisher until every subscriber reads the data, so
+	// the handlers only update the memory
+	sessionInfoChan := pubsub.LiveSessionInfoDataPubSub.Subscribe(pubsub.PubSubSessionInfoPreffix + serverId)
+	standingsChan := pubsub.LiveStandingDataPubSub.Subscribe(pubsub.PubSubDriversSessionPreffix + serverId)
+	go func() {
+		for sessionInfo := range sessionInfoChan {
+			s.updateSession(sessionInfo)
+		}
+	}()
+	go func() {
+		for standings := range standingsChan {
+			s.updateStandings(standings)
+		}
+	}()
+}
+
+func (s *Server) updateSession(data model.LiveSessionInfoData) {
+	si := data.SessionInfo
+	session := ""
+	if si.Session != "" && si.TrackName != "" {
+		session = fmt.Sprintf("%s · %s", si.TrackName, si.Session)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessions[data.ServerID] == session {
+		return
+	}
+	// the viewers of the previous session reload the page to get the new one
+	if h, found := s.hubs[data.ServerID]; found {
+		h.close()
+		delete(s.hubs, data.ServerID)
+	}
+	if session == "" {
+		// sent when the server goes offline
+		delete(s.sessions, data.ServerID)
+		return
+	}
+	s.sessions[data.ServerID] = session
+}
+
+func (s *Server) updateStandings(data model.LiveStandingData) {
+	s.mu.Lock()
+	h, found := s.hubs[data.ServerID]
+	s.mu.Unlock()
+	if found {
+		h.broadcast(data.Drivers)
+	}
+}
+
+// getHub returns the hub of the session running in the server, creating it
+// with the map of the track if needed.
+func (s *Server) getHub(ctx context.Context, serverId string) (*hub, error) {
+	s.mu.Lock()
+	session, running := s.sessions[serverId]
+	h, found := s.hubs[serverId]
+	s.mu.Unlock()
+	if !running {
+		return nil, errNoSession
+	}
+	if found {
+		return h, nil
+	}
+
+	srv, found := s.cm.GetServer(serverId)
+	if !found {
+		return nil, errNoSession
+	}
+	c, err := s.cm.GetCircuit(ctx, srv)
+	if err != nil {
+		return nil, err
+	}
+	opts := trackmap.DefaultOptions()
+	opts.SectorBoundaries = c.SectorBoundaries
+	projection, err := trackmap.NewProjection(c.AIW, opts)
+	if err != nil {
+		return nil, err
+	}
+	svg, err := trackmap.RenderSVG(c.AIW, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[serverId] != session {
+		// the session changed while the track was fetched
+		return nil, errNoSession
+	}
+	if h, found := s.hubs[serverId]; found {
+		return h, nil
+	}
+	h = newHub(session, projection, svg)
+	s.hubs[serverId] = h
+	return h, nil
+}
+
+func (s *Server) pageHandler(w http.ResponseWriter, r *http.Request) {
+	serverId := mux.Vars(r)["server"]
+	h, err := s.getHub(r.Context(), serverId)
+	if err != nil {
+		s.renderError(w, serverId, err)
+		return
+	}
+	scheme := "ws"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "wss"
+	}
+	data := page{
+		Title:        h.session,
+		TrackURL:     fmt.Sprintf("%s/%s/track.svg", Path, serverId),
+		WebSocketURL: fmt.Sprintf("%s://%s%s/%s/ws", scheme, r.Host, Path, serverId),
+		Width:        h.projection.Width,
+		Height:       h.projection.Height,
+	}
+	err = pageTemplate.Execute(w, data)
+	if err != nil {
+		log.Printf("Error rendering the livemap of %s: %s", serverId, err.Error())
+	}
+}
+
+func (s *Server) trackHandler(w http.ResponseWriter, r *http.Request) {
+	serverId := mux.Vars(r)["server"]
+	h, err := s.getHub(r.Context(), serverId)
+	if err != nil {
+		s.renderError(w, serverId, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	_, _ = w.Write(h.svg)
+}
+
+func (s *Server) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	serverId := mux.Vars(r)["server"]
+	h, err := s.getHub(r.Context(), serverId)
+	if err != nil {
+		s.renderError(w, serverId, err)
+		return
+	}
+	c, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Error upgrading the livemap connection of %s: %s", serverId, err.Error())
+		return
+	}
+	defer c.Close()
+
+	frames := h.subscribe()
+	defer h.unsubscribe(frames)
+	// the viewers do not send anything, but the messages must be read to know
+	// when they leave
+	left := make(chan bool)
+	go func() {
+		defer close(left)
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-left:
+			return
+		case frame, ok := <-frames:
+			if !ok {
+				// the session is over
+				_ = c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+				return
+			}
+			_ = c.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.WriteMessage(websocket.TextMessage, frame); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) renderError(w http.ResponseWriter, serverId string, err error) {
+	if errors.Is(err, errNoSession) {
+		http.Error(w, "There is no session running in the server", http.StatusNotFound)
+		return
+	}
+	log.Printf("Error preparing the livemap of %s: %s", serverId, err.Error())
+	http.Error(w, "The track map is not available yet", http.StatusServiceUnavailable)
+}
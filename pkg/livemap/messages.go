@@ -0,0 +1,10 @@
+package livemap
+
+import "github.com/nicksnyder/go-i18n/v2/i18n"
+
+var (
+	msgNoReplays   = &i18n.Message{ID: "livemap.noReplays", Other: "There are no replays yet"}
+	msgReplays     = &i18n.Message{ID: "livemap.replays", Other: "Latest replays:"}
+	msgRecording   = &i18n.Message{ID: "livemap.recording", Other: "The session running in %s is being recorded. I will send you its replay when it is over"}
+	msgReplayReady = &i18n.Message{ID: "livemap.replayReady", Other: "The replay of %s is ready:"}
+)
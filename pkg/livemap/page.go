@@ -0,0 +1,183 @@
+package livemap
+
+import "html/template"
+
+// page is the data of the live map page.
+type page struct {
+	Title        string
+	TrackURL     string
+	WebSocketURL string
+	Width        float64
+	Height       float64
+	// Replay shows the controls of the replays
+	Replay bool
+}
+
+var pageTemplate = template.Must(template.New("livemap").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>{{ .Title }}</title>
+  <style>
+    body { margin: 0; background: #15151e; color: #ffffff; font-family: sans-serif; }
+    header { padding: 8px 16px; font-weight: bold; }
+    #legend span { margin-right: 12px; font-weight: normal; font-size: 14px; }
+    #legend i { display: inline-block; width: 10px; height: 10px; border-radius: 5px; margin-right: 4px; }
+    svg { display: block; width: 100%; height: calc(100vh - 40px); }
+    .car { transition: transform 0.2s linear; }
+    .car text { font-size: 14px; font-weight: bold; text-anchor: middle; dominant-baseline: central; }
+    .pit { opacity: 0.4; }
+    #controls { display: flex; align-items: center; gap: 12px; padding: 0 16px 8px; font-size: 14px; }
+    #controls button { width: 40px; }
+    #seek { flex: 1; }
+    .replay svg { height: calc(100vh - 80px); }
+  </style>
+</head>
+<body{{ if .Replay }} class="replay"{{ end }}>
+  <header>{{ .Title }} <span id="legend"></span></header>
+  {{- if .Replay }}
+  <div id="controls">
+    <button id="play">&#10074;&#10074;</button>
+    <select id="speed">
+      <option value="0.5">0.5x</option>
+      <option value="1" selected>1x</option>
+      <option value="2">2x</option>
+      <option value="4">4x</option>
+      <option value="8">8x</option>
+    </select>
+    <input id="seek" type="range" min="0" max="0" value="0">
+    <span id="time">0:00 / 0:00</span>
+  </div>
+  {{- end }}
+  <svg id="map" viewBox="0 0 {{ .Width }} {{ .Height }}" preserveAspectRatio="xMidYMid meet" xmlns="http://www.w3.org/2000/svg">
+    <rect width="{{ .Width }}" height="{{ .Height }}" fill="#ffffff"></rect>
+    <image href="{{ .TrackURL }}" width="{{ .Width }}" height="{{ .Height }}"></image>
+    <g id="cars"></g>
+  </svg>
+  <script>
+    const svgNS = 'http://www.w3.org/2000/svg';
+    const carsGroup = document.getElementById('cars');
+    const legend = document.getElementById('legend');
+    const cars = new Map();
+    const classes = new Map();
+
+    function buildCar(car) {
+      const g = document.createElementNS(svgNS, 'g');
+      const circle = document.createElementNS(svgNS, 'circle');
+      const text = document.createElementNS(svgNS, 'text');
+      circle.setAttribute('r', 16);
+      circle.setAttribute('stroke-width', 3);
+      g.setAttribute('class', 'car');
+      g.appendChild(circle);
+      g.appendChild(text);
+      carsGroup.appendChild(g);
+      return {g: g, circle: circle, text: text};
+    }
+
+    function addClass(car) {
+      if (classes.has(car.class)) {
+        return;
+      }
+      classes.set(car.class, car.color);
+      const item = document.createElement('span');
+      const dot = document.createElement('i');
+      dot.style.background = car.color;
+      item.appendChild(dot);
+      item.appendChild(document.createTextNode(car.class));
+      legend.appendChild(item);
+    }
+
+    function draw(frame) {
+      const alive = new Set();
+      // the leader is drawn last, over the rest of the cars
+      const sorted = frame.cars.slice().sort((a, b) => b.position - a.position);
+      for (const car of sorted) {
+        alive.add(car.slotId);
+        let elements = cars.get(car.slotId);
+        if (!elements) {
+          elements = buildCar(car);
+          cars.set(car.slotId, elements);
+        }
+        addClass(car);
+        elements.g.setAttribute('transform', 'translate(' + car.x + ',' + car.y + ')');
+        elements.g.classList.toggle('pit', car.inPit);
+        elements.circle.setAttribute('fill', car.color);
+        elements.circle.setAttribute('stroke', car.position === 1 ? '#f5c518' : '#15151e');
+        elements.text.setAttribute('fill', car.color === '#ffffff' || car.color === '#f5c518' ? '#15151e' : '#ffffff');
+        elements.text.textContent = car.code;
+        carsGroup.appendChild(elements.g);
+      }
+      for (const [slotId, elements] of cars) {
+        if (!alive.has(slotId)) {
+          elements.g.remove();
+          cars.delete(slotId);
+        }
+      }
+    }
+
+    {{- if .Replay }}
+
+    function formatTime(ms) {
+      const seconds = Math.floor(ms / 1000);
+      return Math.floor(seconds / 60) + ':' + String(seconds % 60).padStart(2, '0');
+    }
+
+    function connect() {
+      const socket = new WebSocket('{{ .WebSocketURL }}');
+      const play = document.getElementById('play');
+      const speed = document.getElementById('speed');
+      const seek = document.getElementById('seek');
+      const time = document.getElementById('time');
+      let playing = true;
+      let seeking = false;
+
+      function send(action, value) {
+        socket.send(JSON.stringify({action: action, value: value}));
+      }
+
+      function setPlaying(value) {
+        playing = value;
+        play.innerHTML = playing ? '&#10074;&#10074;' : '&#9654;';
+      }
+
+      socket.addEventListener('message', (event) => {
+        const frame = JSON.parse(event.data);
+        const current = frame.time || 0;
+        draw(frame);
+        seek.max = frame.duration || 0;
+        if (!seeking) {
+          seek.value = current;
+        }
+        time.textContent = formatTime(current) + ' / ' + formatTime(frame.duration || 0);
+        if (current >= (frame.duration || 0)) {
+          setPlaying(false);
+        }
+      });
+      play.addEventListener('click', () => {
+        send(playing ? 'pause' : 'play', 0);
+        setPlaying(!playing);
+      });
+      speed.addEventListener('change', () => send('speed', parseFloat(speed.value)));
+      seek.addEventListener('input', () => seeking = true);
+      seek.addEventListener('change', () => {
+        seeking = false;
+        send('seek', parseFloat(seek.value));
+      });
+    }
+    {{- else }}
+
+    function connect() {
+      const socket = new WebSocket('{{ .WebSocketURL }}');
+      socket.addEventListener('message', (event) => draw(JSON.parse(event.data)));
+      // the connection is closed when the session is over, the page is
+      // loaded again to show the next one
+      socket.addEventListener('close', () => setTimeout(() => location.reload(), 5000));
+    }
+    {{- end }}
+
+    connect();
+  </script>
+</body>
+</html>
+`))
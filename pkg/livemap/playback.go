@@ -0,0 +1,311 @@
+package livemap
+
+import (
+	"encoding/json"
+	"errors"
+	"f1champshotlapsbot/pkg/trackmap"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// playbackTick is how often the position of a replay moves forward
+	playbackTick = 50 * time.Millisecond
+	minSpeed     = 0.5
+	maxSpeed     = 8
+
+	actionPlay  = "play"
+	actionPause = "pause"
+	actionSpeed = "speed"
+	actionSeek  = "seek"
+)
+
+// control is the message sent by the viewers of a replay to control it. The
+// value is the speed or the time to seek to in milliseconds.
+type control struct {
+	Action string  `json:"action"`
+	Value  float64 `json:"value"`
+}
+
+// playback is the state of a replay for a viewer, as every viewer watches it
+// at their own pace.
+type playback struct {
+	frames  []Frame
+	playing bool
+	speed   float64
+	// position is the time of the replay in milliseconds
+	position float64
+	// current is the index of the last frame sent
+	current int
+}
+
+func newPlayback(frames []Frame) *playback {
+	return &playback{
+		frames:  frames,
+		playing: true,
+		speed:   1,
+		current: -1,
+	}
+}
+
+func (p *playback) duration() float64 {
+	return float64(p.frames[len(p.frames)-1].Time)
+}
+
+func (p *playback) apply(c control) {
+	switch c.Action {
+	case actionPlay:
+		// playing again from the start when the replay is over
+		if p.position >= p.duration() {
+			p.position = 0
+		}
+		p.playing = true
+	case actionPause:
+		p.playing = false
+	case actionSpeed:
+		p.speed = math.Max(minSpeed, math.Min(maxSpeed, c.Value))
+	case actionSeek:
+		p.position = math.Max(0, math.Min(p.duration(), c.Value))
+		// the frame is sent even if it did not change, as the seek bar of
+		// the viewer is moved back to it
+		p.current = -1
+	}
+}
+
+func (p *playback) advance(elapsed time.Duration) {
+	if !p.playing {
+		return
+	}
+	p.position += float64(elapsed.Milliseconds()) * p.speed
+	if p.position >= p.duration() {
+		p.position = p.duration()
+		p.playing = false
+	}
+}
+
+// next returns the frame at the position of the replay, if it was not sent
+// yet.
+func (p *playback) next() (Frame, bool) {
+	i := sort.Search(len(p.frames), func(i int) bool {
+		return float64(p.frames[i].Time) > p.position
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i == p.current {
+		return Frame{}, false
+	}
+	p.current = i
+	return p.frames[i], true
+}
+
+// Register adds the handlers of the replays to the router: the page, the
+// track map and the WebSocket of every replay.
+func (rs *Replays) Register(r *mux.Router) {
+	r.HandleFunc("/{replay}", rs.pageHandler)
+	r.HandleFunc("/{replay}/track.svg", rs.trackHandler)
+	r.HandleFunc("/{replay}/ws", rs.websocketHandler)
+}
+
+// load reads the header of a replay and the options to draw its track.
+func (rs *Replays) load(id string) (string, replayHeader, trackmap.Options, error) {
+	opts := trackmap.DefaultOptions()
+	path, err := rs.path(id)
+	if err != nil {
+		return "", replayHeader{}, opts, err
+	}
+	header, err := readReplayHeader(path)
+	if err != nil {
+		return "", replayHeader{}, opts, err
+	}
+	opts.SectorBoundaries = header.SectorBoundaries
+	return path, header, opts, nil
+}
+
+func (rs *Replays) pageHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["replay"]
+	_, header, opts, err := rs.load(id)
+	if err != nil {
+		rs.renderError(w, id, err)
+		return
+	}
+	projection, err := trackmap.NewProjection(header.AIW, opts)
+	if err != nil {
+		rs.renderError(w, id, err)
+		return
+	}
+	scheme := "ws"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "wss"
+	}
+	data := page{
+		Title:        replayTitle(header.Replay),
+		TrackURL:     fmt.Sprintf("%s/%s/track.svg", ReplayPath, id),
+		WebSocketURL: fmt.Sprintf("%s://%s%s/%s/ws", scheme, r.Host, ReplayPath, id),
+		Width:        projection.Width,
+		Height:       projection.Height,
+		Replay:       true,
+	}
+	err = pageTemplate.Execute(w, data)
+	if err != nil {
+		log.Printf("Error rendering replay %s: %s", id, err.Error())
+	}
+}
+
+func (rs *Replays) trackHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["replay"]
+	_, header, opts, err := rs.load(id)
+	if err != nil {
+		rs.renderError(w, id, err)
+		return
+	}
+	svg, err := trackmap.RenderSVG(header.AIW, opts)
+	if err != nil {
+		rs.renderError(w, id, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	_, _ = w.Write(svg)
+}
+
+// sharedFrames are the frames of a replay being watched. They are read once
+// and shared by all its viewers, and dropped when the last one leaves.
+type sharedFrames struct {
+	loaded  chan bool
+	frames  []Frame
+	err     error
+	viewers int
+}
+
+// frames returns the frames of a replay for a new viewer, who must call
+// release once they leave. They are only read if nobody is watching it.
+func (rs *Replays) frames(id string) ([]Frame, func(), error) {
+	rs.playingMu.Lock()
+	sf, found := rs.playing[id]
+	if !found {
+		sf = &sharedFrames{loaded: make(chan bool)}
+		rs.playing[id] = sf
+	}
+	sf.viewers++
+	rs.playingMu.Unlock()
+
+	release := func() {
+		rs.playingMu.Lock()
+		defer rs.playingMu.Unlock()
+		sf.viewers--
+		if sf.viewers == 0 && rs.playing[id] == sf {
+			delete(rs.playing, id)
+		}
+	}
+	if found {
+		<-sf.loaded
+	} else {
+		sf.frames, sf.err = rs.readFrames(id)
+		close(sf.loaded)
+	}
+	if sf.err != nil {
+		release()
+		return nil, nil, sf.err
+	}
+	return sf.frames, release, nil
+}
+
+// readFrames reads the frames of a replay, with the positions of the cars in
+// the map of its track.
+func (rs *Replays) readFrames(id string) ([]Frame, error) {
+	path, header, opts, err := rs.load(id)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := trackmap.NewProjection(header.AIW, opts)
+	if err != nil {
+		return nil, err
+	}
+	return readReplayFrames(path, projection)
+}
+
+func (rs *Replays) websocketHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["replay"]
+	frames, release, err := rs.frames(id)
+	if err != nil {
+		rs.renderError(w, id, err)
+		return
+	}
+	defer release()
+	if len(frames) == 0 {
+		rs.renderError(w, id, errReplayNotFound)
+		return
+	}
+	c, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Error upgrading the connection of replay %s: %s", id, err.Error())
+		return
+	}
+	defer c.Close()
+
+	controls := make(chan control)
+	left := make(chan bool)
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		defer close(left)
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var ctl control
+			if err := json.Unmarshal(data, &ctl); err != nil {
+				continue
+			}
+			select {
+			case controls <- ctl:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(playbackTick)
+	defer ticker.Stop()
+	last := time.Now()
+	pb := newPlayback(frames)
+	for {
+		if frame, ok := pb.next(); ok {
+			data, err := json.Marshal(frame)
+			if err != nil {
+				log.Printf("Error encoding replay %s frame: %s", id, err.Error())
+				return
+			}
+			_ = c.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		}
+		select {
+		case <-left:
+			return
+		case ctl := <-controls:
+			pb.apply(ctl)
+		case now := <-ticker.C:
+			pb.advance(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+func (rs *Replays) renderError(w http.ResponseWriter, id string, err error) {
+	if errors.Is(err, errReplayNotFound) {
+		http.Error(w, "The replay was not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Error preparing replay %s: %s", id, err.Error())
+	http.Error(w, "The replay could not be loaded", http.StatusInternalServerError)
+}
@@ -0,0 +1,91 @@
+package livemap
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"log"
+	"sort"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	CommandReplay = "/replay"
+
+	// maxReplays is the number of replays listed by the bot
+	maxReplays = 10
+)
+
+// RenderReplays sends the links of the latest replays. The chat gets the
+// replays of the sessions being recorded once they are over.
+func (rs *Replays) RenderReplays() func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := rs.locale.Localizer(ctx)
+		replays, err := rs.List()
+		if err != nil {
+			return err
+		}
+		if len(replays) > maxReplays {
+			replays = replays[:maxReplays]
+		}
+
+		message := ""
+		if len(replays) == 0 {
+			message = locale.Localize(loc, msgNoReplays) + "\n"
+		} else {
+			message = locale.Localize(loc, msgReplays) + "\n\n"
+			for _, r := range replays {
+				message += rs.replayLine(r)
+			}
+		}
+		for _, serverName := range rs.waitFor(chatId) {
+			message += "\n" + fmt.Sprintf(locale.Localize(loc, msgRecording), serverName) + "\n"
+		}
+		msg := tgbotapi.NewMessage(chatId, message)
+		msg.DisableWebPagePreview = true
+		_, err = rs.bot.Send(msg)
+		return err
+	}
+}
+
+// waitFor makes the chat get the replays of the sessions being recorded and
+// returns the names of their servers.
+func (rs *Replays) waitFor(chatId int64) []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	names := []string{}
+	for _, rec := range rs.recordings {
+		rec.waiting[chatId] = true
+		names = append(names, rec.replay.ServerName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// notify sends the replay to the chats waiting for the session to be over.
+func (rs *Replays) notify(r Replay, rec *recording) {
+	// the recording is over, so no chat is added anymore
+	rs.mu.Lock()
+	chatIds := rec.waiting
+	rs.mu.Unlock()
+
+	for chatId := range chatIds {
+		msg := tgbotapi.NewMessage(chatId, replayReadyMessage(rs.locale.ForChat(chatId), r)+rs.replayLine(r))
+		msg.DisableWebPagePreview = true
+		_, err := rs.bot.Send(msg)
+		if err != nil {
+			log.Printf("Error sending replay %s to chat %d: %s", r.ID, chatId, err.Error())
+		}
+	}
+}
+
+func replayReadyMessage(loc *i18n.Localizer, r Replay) string {
+	return fmt.Sprintf(locale.Localize(loc, msgReplayReady), r.ServerName) + "\n\n"
+}
+
+func (rs *Replays) replayLine(r Replay) string {
+	return fmt.Sprintf("%s\n%s\n\n", replayTitle(r), rs.URL(r.ID))
+}
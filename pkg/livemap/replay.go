@@ -0,0 +1,610 @@
+package livemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"f1champshotlapsbot/pkg/circuits"
+	"f1champshotlapsbot/pkg/locale"
+	"f1champshotlapsbot/pkg/trackmap"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/model"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/pubsub"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// ReplayPath is where the replays are served
+	ReplayPath = "/replay"
+	// ReplaysDir is where the replays are saved
+	ReplaysDir = "./replays"
+
+	// replayInterval is the time between the positions saved in a replay
+	replayInterval = 250 * time.Millisecond
+	// replayBuffer is how many frames can wait to be written to the file
+	// before new ones are dropped
+	replayBuffer = 256
+
+	replayExt     = ".replay"
+	summaryExt    = ".json"
+	recordingExt  = ".recording"
+	replayTimeout = 30 * time.Second
+
+	// replayMaxAge and replayMaxCount bound the replays kept. The older ones
+	// are deleted every time a replay is saved.
+	replayMaxAge   = 30 * 24 * time.Hour
+	replayMaxCount = 200
+)
+
+var (
+	replayIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	replayIdInvalid = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+	errReplayNotFound = errors.New("replay not found")
+)
+
+// Replay describes a recorded session.
+type Replay struct {
+	ID         string    `json:"id"`
+	ServerID   string    `json:"serverId"`
+	ServerName string    `json:"serverName"`
+	TrackName  string    `json:"trackName"`
+	Session    string    `json:"session"`
+	StartedAt  time.Time `json:"startedAt"`
+	EndedAt    time.Time `json:"endedAt"`
+	// Duration is the time from the first to the last frame in milliseconds
+	Duration int64 `json:"duration"`
+}
+
+// A replay file is gzipped JSON, one value per line: the replayHeader and then
+// a replayFrame every replayInterval. The drivers of every slot are only
+// written when they show up or change. The Replay is also saved as JSON in a
+// summary file next to it, so the replays are listed without reading the
+// track.
+type replayHeader struct {
+	Replay
+	AIW              trackmap.AIW `json:"aiw"`
+	SectorBoundaries []float64    `json:"sectorBoundaries,omitempty"`
+}
+
+type replayFrame struct {
+	// T is the time from the start of the replay in milliseconds
+	T int64 `json:"t"`
+	// Cars holds the slot ID, the X and Z coordinates in the track, the
+	// position and 1 if the car is in the pits, for every car
+	Cars    [][5]float64 `json:"c"`
+	Drivers []Car        `json:"d,omitempty"`
+}
+
+// recording is a session being recorded. The frames are written to a
+// temporary file by its own goroutine, so the live timing is never blocked by
+// the disk.
+type recording struct {
+	replay  Replay
+	session string
+	frames  chan replayFrame
+	started time.Time
+	last    time.Time
+	drivers map[int]Car
+	colors  map[string]string
+	// track receives the circuit once it is fetched
+	track chan circuits.Circuit
+	// waiting holds the chats to send the replay to
+	waiting map[int64]bool
+}
+
+// Replays records the sessions of the rFactor2 servers and plays them back
+// in the live map page.
+type Replays struct {
+	bot        *tgbotapi.BotAPI
+	dir        string
+	domain     string
+	cm         *circuits.Manager
+	locale     *locale.Manager
+	watched    map[string]bool
+	recordings map[string]*recording
+	wg         sync.WaitGroup
+	mu         sync.Mutex
+	// playing holds the frames of the replays being watched, by ID
+	playing   map[string]*sharedFrames
+	playingMu sync.Mutex
+}
+
+func NewReplays(bot *tgbotapi.BotAPI, dir, domain string, cm *circuits.Manager, lm *locale.Manager) (*Replays, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	// the recordings left by a crash cannot be finished
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "*"+recordingExt))
+	for _, f := range leftovers {
+		_ = os.Remove(f)
+	}
+	rs := &Replays{
+		bot:        bot,
+		dir:        dir,
+		domain:     domain,
+		cm:         cm,
+		locale:     lm,
+		watched:    make(map[string]bool),
+		recordings: make(map[string]*recording),
+		playing:    make(map[string]*sharedFrames),
+	}
+	rs.prune()
+	return rs, nil
+}
+
+// URL returns the public address of a replay.
+func (rs *Replays) URL(id string) string {
+	return fmt.Sprintf("%s%s/%s", rs.domain, ReplayPath, id)
+}
+
+// Watch starts recording the sessions of a server. Watching the same server
+// again does nothing, so it is safe to call it for every server after
+// reloading the configuration.
+func (rs *Replays) Watch(serverId string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.watched[serverId] {
+		return
+	}
+	rs.watched[serverId] = true
+
+	// the pubsub blocks the publisher until every subscriber reads the data, so
+	// the handlers only update the memory and the files are written apart
+	sessionInfoChan := pubsub.LiveSessionInfoDataPubSub.Subscribe(pubsub.PubSubSessionInfoPreffix + serverId)
+	standingsChan := pubsub.LiveStandingDataPubSub.Subscribe(pubsub.PubSubDriversSessionPreffix + serverId)
+	go func() {
+		for sessionInfo := range sessionInfoChan {
+			rs.updateSession(sessionInfo)
+		}
+	}()
+	go func() {
+		for standings := range standingsChan {
+			rs.updateStandings(standings)
+		}
+	}()
+}
+
+// Stop finishes the recordings in progress and waits for their files to be
+// written.
+func (rs *Replays) Stop() {
+	rs.mu.Lock()
+	for serverId, rec := range rs.recordings {
+		close(rec.frames)
+		delete(rs.recordings, serverId)
+	}
+	rs.mu.Unlock()
+	rs.wg.Wait()
+}
+
+func (rs *Replays) updateSession(data model.LiveSessionInfoData) {
+	si := data.SessionInfo
+	session := ""
+	if si.Session != "" && si.TrackName != "" {
+		session = fmt.Sprintf("%s · %s", si.TrackName, si.Session)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rec, found := rs.recordings[data.ServerID]
+	if found && rec.session == session {
+		return
+	}
+	if found {
+		close(rec.frames)
+		delete(rs.recordings, data.ServerID)
+	}
+	if session == "" {
+		// sent when the server goes offline
+		return
+	}
+
+	now := time.Now()
+	rec = &recording{
+		replay: Replay{
+			ID:         fmt.Sprintf("%s-%s", replayIdInvalid.ReplaceAllString(data.ServerID, "_"), now.Format("20060102-150405")),
+			ServerID:   data.ServerID,
+			ServerName: data.ServerName,
+			TrackName:  si.TrackName,
+			Session:    si.Session,
+			StartedAt:  now,
+		},
+		session: session,
+		frames:  make(chan replayFrame, replayBuffer),
+		drivers: make(map[int]Car),
+		colors:  make(map[string]string),
+		track:   make(chan circuits.Circuit, 1),
+		waiting: make(map[int64]bool),
+	}
+	rs.recordings[data.ServerID] = rec
+	rs.wg.Add(2)
+	go rs.fetchTrack(rec)
+	go rs.write(rec)
+}
+
+func (rs *Replays) updateStandings(data model.LiveStandingData) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rec, found := rs.recordings[data.ServerID]
+	if !found || len(data.Drivers) == 0 {
+		return
+	}
+	now := time.Now()
+	if now.Sub(rec.last) < replayInterval {
+		return
+	}
+	if rec.started.IsZero() {
+		rec.started = now
+	}
+	rec.last = now
+
+	frame := replayFrame{
+		T:    now.Sub(rec.started).Milliseconds(),
+		Cars: make([][5]float64, 0, len(data.Drivers)),
+	}
+	for _, d := range data.Drivers {
+		color, found := rec.colors[d.CarClass]
+		if !found {
+			color = classColors[len(rec.colors)%len(classColors)]
+			rec.colors[d.CarClass] = color
+		}
+		driver := Car{
+			SlotID: d.SlotID,
+			Code:   helper.GetDriverCodeName(d.DriverName),
+			Class:  d.CarClass,
+			Color:  color,
+		}
+		if rec.drivers[d.SlotID] != driver {
+			rec.drivers[d.SlotID] = driver
+			frame.Drivers = append(frame.Drivers, driver)
+		}
+		inPit := 0.0
+		if d.Pitting || d.InGarageStall {
+			inPit = 1
+		}
+		frame.Cars = append(frame.Cars, [5]float64{
+			float64(d.SlotID),
+			// a tenth of a metre is enough and keeps the files small
+			math.Round(d.CarPosition.X*10) / 10,
+			math.Round(d.CarPosition.Z*10) / 10,
+			float64(d.Position),
+			inPit,
+		})
+	}
+	select {
+	case rec.frames <- frame:
+	default:
+		log.Printf("Replay %s is behind, a frame was dropped", rec.replay.ID)
+		// the drivers are sent again with the next frame
+		for _, driver := range frame.Drivers {
+			delete(rec.drivers, driver.SlotID)
+		}
+	}
+}
+
+// fetchTrack gets the track selected in the server, which is saved in the
+// replay to draw the map.
+func (rs *Replays) fetchTrack(rec *recording) {
+	defer rs.wg.Done()
+	defer close(rec.track)
+
+	ctx, cancel := context.WithTimeout(context.Background(), replayTimeout)
+	defer cancel()
+	srv, found := rs.cm.GetServer(rec.replay.ServerID)
+	if !found {
+		return
+	}
+	c, err := rs.cm.GetCircuit(ctx, srv)
+	if err != nil {
+		log.Printf("Error getting the track of replay %s: %s", rec.replay.ID, err.Error())
+		return
+	}
+	rec.track <- c
+}
+
+// write saves the frames of the recording in a temporary file until the
+// session is over. The replay is then sent to the chats waiting for it.
+func (rs *Replays) write(rec *recording) {
+	defer rs.wg.Done()
+
+	r, saved := rs.save(rec)
+	if saved {
+		rs.notify(r, rec)
+	}
+}
+
+// save writes the replay with the track at the start, so it can be listed
+// and drawn without reading the frames. Sessions with no cars on track are
+// not saved.
+func (rs *Replays) save(rec *recording) (Replay, bool) {
+	id := rec.replay.ID
+	tmpPath := filepath.Join(rs.dir, id+recordingExt)
+	defer os.Remove(tmpPath)
+	err := writeFrames(tmpPath, rec.frames)
+	// the frames are read until the end even on errors, so the live timing
+	// is not blocked
+	for range rec.frames {
+	}
+	if err != nil {
+		log.Printf("Error recording replay %s: %s", id, err.Error())
+		return Replay{}, false
+	}
+	if rec.started.IsZero() {
+		return Replay{}, false
+	}
+
+	header := replayHeader{Replay: rec.replay}
+	header.EndedAt = time.Now()
+	header.Duration = rec.last.Sub(rec.started).Milliseconds()
+	c := <-rec.track
+	header.AIW = c.AIW
+	header.SectorBoundaries = c.SectorBoundaries
+	if len(header.AIW) == 0 {
+		log.Printf("Replay %s is discarded, the track is not available", id)
+		return Replay{}, false
+	}
+	path := filepath.Join(rs.dir, id+replayExt)
+	err = writeReplay(path, header, tmpPath)
+	if err != nil {
+		log.Printf("Error saving replay %s: %s", id, err.Error())
+		return Replay{}, false
+	}
+	err = writeSummary(path, header.Replay)
+	if err != nil {
+		// it is written again the next time the replays are listed
+		log.Printf("Error saving the summary of replay %s: %s", id, err.Error())
+	}
+	log.Printf("Replay %s saved", id)
+	rs.prune()
+	return header.Replay, true
+}
+
+func writeFrames(path string, frames <-chan replayFrame) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	for frame := range frames {
+		err = enc.Encode(frame)
+		if err != nil {
+			return err
+		}
+	}
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeReplay writes the header and the frames of the temporary file to the
+// replay file, which only shows up once it is complete.
+func writeReplay(path string, header replayHeader, framesPath string) error {
+	frames, err := os.Open(framesPath)
+	if err != nil {
+		return err
+	}
+	defer frames.Close()
+	framesReader, err := gzip.NewReader(frames)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	err = json.NewEncoder(gz).Encode(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(gz, framesReader)
+	if err != nil {
+		return err
+	}
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// List returns the saved replays, the newest first.
+func (rs *Replays) List() ([]Replay, error) {
+	files, err := filepath.Glob(filepath.Join(rs.dir, "*"+replayExt))
+	if err != nil {
+		return nil, err
+	}
+	replays := []Replay{}
+	for _, file := range files {
+		r, err := readSummary(file)
+		if err != nil {
+			log.Printf("Error reading replay %s: %s", file, err.Error())
+			continue
+		}
+		replays = append(replays, r)
+	}
+	sort.Slice(replays, func(i, j int) bool {
+		return replays[i].StartedAt.After(replays[j].StartedAt)
+	})
+	return replays, nil
+}
+
+// prune deletes the replays older than replayMaxAge and the oldest ones over
+// replayMaxCount.
+func (rs *Replays) prune() {
+	replays, err := rs.List()
+	if err != nil {
+		log.Printf("Error listing the replays to delete: %s", err.Error())
+		return
+	}
+	for i, r := range replays {
+		if i < replayMaxCount && time.Since(r.StartedAt) < replayMaxAge {
+			continue
+		}
+		path := filepath.Join(rs.dir, r.ID+replayExt)
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error deleting replay %s: %s", r.ID, err.Error())
+			continue
+		}
+		_ = os.Remove(summaryPath(path))
+		log.Printf("Replay %s deleted", r.ID)
+	}
+}
+
+func (rs *Replays) path(id string) (string, error) {
+	if !replayIdPattern.MatchString(id) {
+		return "", errReplayNotFound
+	}
+	path := filepath.Join(rs.dir, id+replayExt)
+	if _, err := os.Stat(path); err != nil {
+		return "", errReplayNotFound
+	}
+	return path, nil
+}
+
+func summaryPath(replayPath string) string {
+	return strings.TrimSuffix(replayPath, replayExt) + summaryExt
+}
+
+// writeSummary saves the description of the replay in its summary file.
+func writeSummary(replayPath string, r Replay) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	path := summaryPath(replayPath)
+	tmpPath := path + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// readSummary returns the description of a replay. When its summary file is
+// missing, as for the replays saved before they had one, it is read from the
+// header of the replay and the summary is written.
+func readSummary(replayPath string) (Replay, error) {
+	var r Replay
+	data, err := os.ReadFile(summaryPath(replayPath))
+	if err == nil {
+		err = json.Unmarshal(data, &r)
+		return r, err
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return r, err
+	}
+	header, err := readReplayHeader(replayPath)
+	if err != nil {
+		return r, err
+	}
+	err = writeSummary(replayPath, header.Replay)
+	if err != nil {
+		log.Printf("Error saving the summary of replay %s: %s", header.ID, err.Error())
+	}
+	return header.Replay, nil
+}
+
+func readReplayHeader(path string) (replayHeader, error) {
+	var header replayHeader
+	err := readReplay(path, func(dec *json.Decoder) error {
+		return dec.Decode(&header)
+	})
+	return header, err
+}
+
+// readReplayFrames reads the frames of a replay, with the positions of the
+// cars in the map of the projection.
+func readReplayFrames(path string, p trackmap.Projection) ([]Frame, error) {
+	frames := []Frame{}
+	err := readReplay(path, func(dec *json.Decoder) error {
+		var header replayHeader
+		err := dec.Decode(&header)
+		if err != nil {
+			return err
+		}
+		session := fmt.Sprintf("%s · %s", header.TrackName, header.Session)
+		drivers := map[int]Car{}
+		for {
+			var rf replayFrame
+			err = dec.Decode(&rf)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			for _, driver := range rf.Drivers {
+				drivers[driver.SlotID] = driver
+			}
+			frame := Frame{
+				Session:  session,
+				Cars:     make([]Car, 0, len(rf.Cars)),
+				Time:     rf.T,
+				Duration: header.Duration,
+			}
+			for _, c := range rf.Cars {
+				car := drivers[int(c[0])]
+				car.SlotID = int(c[0])
+				x, y := p.Point(c[1], c[2])
+				car.X, car.Y = math.Round(x*10)/10, math.Round(y*10)/10
+				car.Position = int(c[3])
+				car.InPit = c[4] == 1
+				frame.Cars = append(frame.Cars, car)
+			}
+			frames = append(frames, frame)
+		}
+	})
+	return frames, err
+}
+
+func readReplay(path string, read func(dec *json.Decoder) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	return read(json.NewDecoder(gz))
+}
+
+// replayTitle is the title of the replay page.
+func replayTitle(r Replay) string {
+	return strings.Join([]string{r.TrackName, r.Session, r.ServerName, r.StartedAt.Local().Format("2006-01-02 15:04")}, " · ")
+}
@@ -0,0 +1,143 @@
+package locale
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/apps/live"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// Storer persists the language chosen by a chat with the /lang command.
+type Storer interface {
+	GetChatLanguage(chatId int64) (string, error)
+	SetChatLanguage(chatId int64, lang string) error
+}
+
+// Manager picks the localizer for every chat: the language chosen with /lang
+// wins over the language of the Telegram user, and both over the fallback.
+type Manager struct {
+	bundle   *i18n.Bundle
+	fallback string
+	store    Storer
+	langs    map[int64]string
+	mu       sync.Mutex
+}
+
+func NewManager(bundle *i18n.Bundle, fallback string, store Storer) *Manager {
+	return &Manager{
+		bundle:   bundle,
+		fallback: fallback,
+		store:    store,
+		langs:    make(map[int64]string),
+	}
+}
+
+// Localizer returns the localizer for the chat and user found in the context.
+func (m *Manager) Localizer(ctx context.Context) *i18n.Localizer {
+	langs := []string{}
+	if chat, ok := ctx.Value(live.ChatContextKey).(*tgbotapi.Chat); ok && chat != nil {
+		if lang := m.ChatLanguage(chat.ID); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	if user, ok := ctx.Value(live.UserContextKey).(*tgbotapi.User); ok && user != nil && user.LanguageCode != "" {
+		langs = append(langs, user.LanguageCode)
+	}
+	langs = append(langs, m.fallback)
+	return i18n.NewLocalizer(m.bundle, langs...)
+}
+
+// ForChat returns the localizer for a chat when there is no user interaction,
+// as when sending notifications.
+func (m *Manager) ForChat(chatId int64) *i18n.Localizer {
+	return i18n.NewLocalizer(m.bundle, m.ChatLanguage(chatId), m.fallback)
+}
+
+// Default returns the localizer for the fallback language.
+func (m *Manager) Default() *i18n.Localizer {
+	return i18n.NewLocalizer(m.bundle, m.fallback)
+}
+
+// Languages returns the languages with a loaded message file.
+func (m *Manager) Languages() []string {
+	langs := []string{}
+	for _, tag := range m.bundle.LanguageTags() {
+		langs = append(langs, tag.String())
+	}
+	return langs
+}
+
+// SetLanguage overrides the language of a chat.
+func (m *Manager) SetLanguage(chatId int64, lang string) error {
+	supported := false
+	for _, l := range m.Languages() {
+		if l == lang {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("language %q is not supported", lang)
+	}
+
+	err := m.store.SetChatLanguage(chatId, lang)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.langs[chatId] = lang
+	return nil
+}
+
+// Translations returns the message in every supported language. It is used to
+// match reply keyboard buttons whatever the language they were sent in.
+func (m *Manager) Translations(msg *i18n.Message) []string {
+	translations := []string{}
+	for _, lang := range m.Languages() {
+		loc := i18n.NewLocalizer(m.bundle, lang)
+		translations = append(translations, Localize(loc, msg))
+	}
+	return translations
+}
+
+// Is reports whether text is the message in any supported language.
+func (m *Manager) Is(text string, msg *i18n.Message) bool {
+	for _, translation := range m.Translations(msg) {
+		if text == translation {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatLanguage returns the language chosen by a chat or "" if it has not
+// chosen any.
+func (m *Manager) ChatLanguage(chatId int64) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if lang, found := m.langs[chatId]; found {
+		return lang
+	}
+	lang, err := m.store.GetChatLanguage(chatId)
+	if err != nil {
+		log.Printf("Error reading chat language: %s", err.Error())
+		return ""
+	}
+	m.langs[chatId] = lang
+	return lang
+}
+
+// Localize returns the message translated by the localizer.
+func Localize(loc *i18n.Localizer, msg *i18n.Message) string {
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: msg,
+	})
+}
@@ -0,0 +1,31 @@
+package results
+
+import "github.com/nicksnyder/go-i18n/v2/i18n"
+
+var (
+	msgNoSessions       = &i18n.Message{ID: "results.noSessions", Other: "There are no sessions recorded yet"}
+	msgSessionNotFound  = &i18n.Message{ID: "results.sessionNotFound", Other: "The selected session was not found. Go back and try again"}
+	msgNoEntries        = &i18n.Message{ID: "results.noEntries", Other: "There are no drivers classified in this session"}
+	msgChoosePractice   = &i18n.Message{ID: "results.choosePractice", Other: "Practice sessions (%d/%d):"}
+	msgChooseQualifying = &i18n.Message{ID: "results.chooseQualifying", Other: "Qualifying sessions (%d/%d):"}
+	msgChooseRace       = &i18n.Message{ID: "results.chooseRace", Other: "Race sessions (%d/%d):"}
+	msgResults          = &i18n.Message{ID: "results.results", Other: "%s in %q\n%s, %s"}
+	msgInProgress       = &i18n.Message{ID: "results.inProgress", Other: "Provisional results, the session was not finished"}
+
+	msgStatusRunning  = &i18n.Message{ID: "results.statusRunning", Other: "Running"}
+	msgStatusFinished = &i18n.Message{ID: "results.statusFinished", Other: "Finished"}
+	msgStatusDNF      = &i18n.Message{ID: "results.statusDNF", Other: "DNF"}
+	msgStatusDQ       = &i18n.Message{ID: "results.statusDQ", Other: "DQ"}
+
+	msgKeyboardClassification = &i18n.Message{ID: "results.keyboardClassification", Other: "Classification"}
+	msgKeyboardBest           = &i18n.Message{ID: "results.keyboardBest", Other: "Best laps"}
+	msgKeyboardSectors        = &i18n.Message{ID: "results.keyboardSectors", Other: "Sectors"}
+	msgKeyboardLaps           = &i18n.Message{ID: "results.keyboardLaps", Other: "Laps"}
+	msgKeyboardStatus         = &i18n.Message{ID: "results.keyboardStatus", Other: "Status"}
+	msgKeyboardCars           = &i18n.Message{ID: "results.keyboardCars", Other: "Cars"}
+	msgKeyboardDrivers        = &i18n.Message{ID: "results.keyboardDrivers", Other: "Drivers"}
+	msgKeyboardPace           = &i18n.Message{ID: "results.keyboardPace", Other: "Pace"}
+
+	msgHeaderPosition = &i18n.Message{ID: "results.headerPosition", Other: "POS"}
+	msgHeaderDriver   = &i18n.Message{ID: "apps.headerDriver", Other: "DRI"}
+)
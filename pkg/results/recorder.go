@@ -0,0 +1,300 @@
+package results
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/model"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/pubsub"
+)
+
+const (
+	// SnapshotInterval is how often the standings of the live sessions are saved
+	SnapshotInterval = 30 * time.Second
+
+	// gamePhaseSessionOver is the game phase sent by rFactor2 once the
+	// checkered flag has been shown to every car
+	gamePhaseSessionOver = 8
+)
+
+// Archiver saves the results of a session. Session.ID is set the first time
+// the session is saved.
+type Archiver interface {
+	SaveResult(s *Session, entries []Entry, laps []Lap) error
+}
+
+// Recorder archives the sessions played in the rFactor2 servers from their
+// live timing data. The standings are saved every SnapshotInterval and the
+// final classification when the session changes or the server goes offline.
+type Recorder struct {
+	archiver Archiver
+	watched  map[string]bool
+	live     map[string]*liveSession
+	// closed holds the sessions that are over but not saved yet
+	closed []*liveSession
+	mu     sync.Mutex
+}
+
+// liveSession is the state of a session being recorded.
+type liveSession struct {
+	session Session
+	entries map[string]*Entry
+	// laps holds the completed laps that are not saved yet
+	laps          []Lap
+	lapsCompleted map[string]int
+	dirty         bool
+}
+
+func NewRecorder(archiver Archiver) *Recorder {
+	r := &Recorder{
+		archiver: archiver,
+		watched:  make(map[string]bool),
+		live:     make(map[string]*liveSession),
+	}
+	stoppedChan := pubsub.SessionStoppedPubSub.Subscribe(pubsub.PubSubSessionStoppedPreffix)
+	go func() {
+		for serverId := range stoppedChan {
+			r.closeSession(serverId)
+		}
+	}()
+	return r
+}
+
+// Watch starts recording the sessions of a server. It must be called before
+// the server is polled. Watching the same server again does nothing, so it is
+// safe to call it for every server after reloading the configuration.
+func (r *Recorder) Watch(serverId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.watched[serverId] {
+		return
+	}
+	r.watched[serverId] = true
+
+	// the pubsub blocks the publisher until every subscriber reads the data, so
+	// the handlers only update the memory and the store is written by Run and Stop
+	sessionInfoChan := pubsub.LiveSessionInfoDataPubSub.Subscribe(pubsub.PubSubSessionInfoPreffix + serverId)
+	standingsChan := pubsub.LiveStandingDataPubSub.Subscribe(pubsub.PubSubDriversSessionPreffix + serverId)
+	go func() {
+		for sessionInfo := range sessionInfoChan {
+			r.updateSession(sessionInfo)
+		}
+	}()
+	go func() {
+		for standings := range standingsChan {
+			r.updateStandings(standings)
+		}
+	}()
+}
+
+// Run saves the sessions every tick until exitChan receives a value.
+func (r *Recorder) Run(ticker *time.Ticker, exitChan chan bool) {
+	for {
+		select {
+		case <-exitChan:
+			return
+		case <-ticker.C:
+			r.save()
+		}
+	}
+}
+
+// Stop closes the sessions still running and saves them. It must be called
+// once Run returned.
+func (r *Recorder) Stop() {
+	r.mu.Lock()
+	for serverId := range r.live {
+		r.closeLocked(serverId)
+	}
+	r.mu.Unlock()
+	r.save()
+}
+
+func (r *Recorder) updateSession(data model.LiveSessionInfoData) {
+	si := data.SessionInfo
+	if si.Session == "" || si.TrackName == "" {
+		// sent when the server goes offline
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ls, found := r.live[data.ServerID]
+	if found && (ls.session.Type != si.Session || ls.session.TrackName != si.TrackName) {
+		r.closeLocked(data.ServerID)
+		found = false
+	}
+	if !found {
+		now := time.Now()
+		ls = &liveSession{
+			session: Session{
+				ServerID:   data.ServerID,
+				ServerName: data.ServerName,
+				TrackName:  si.TrackName,
+				Type:       si.Session,
+				StartedAt:  now,
+				EndedAt:    now,
+			},
+			entries:       make(map[string]*Entry),
+			lapsCompleted: make(map[string]int),
+		}
+		r.live[data.ServerID] = ls
+	}
+	if si.GamePhase == gamePhaseSessionOver && !ls.session.Finished {
+		ls.session.Finished = true
+		ls.session.EndedAt = time.Now()
+		ls.dirty = true
+	}
+}
+
+func (r *Recorder) updateStandings(data model.LiveStandingData) {
+	if len(data.Drivers) == 0 {
+		// sent when the server goes offline, drivers are kept until then
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ls, found := r.live[data.ServerID]
+	if !found {
+		// the session is not known until its info is received
+		return
+	}
+	for _, driver := range data.Drivers {
+		entry, found := ls.entries[driver.DriverName]
+		if !found {
+			entry = &Entry{Driver: driver.DriverName}
+			ls.entries[driver.DriverName] = entry
+		}
+		entry.Position = driver.Position
+		entry.CarClass = driver.CarClass
+		entry.Vehicle = driver.VehicleName
+		entry.BestLap = driver.BestLapTime
+		entry.S1 = driver.BestSectorTime1
+		entry.S2 = driver.BestSectorTime2
+		entry.S3 = driver.BestSectorTime3
+		entry.Laps = driver.LapsCompleted
+		entry.Pitstops = driver.Pitstops
+		entry.FinishStatus = driver.FinishStatus
+		entry.TimeBehindLeader = driver.TimeBehindLeader
+		entry.LapsBehindLeader = driver.LapsBehindLeader
+
+		// a lap is recorded once the driver completes it
+		if driver.LapsCompleted > ls.lapsCompleted[driver.DriverName] {
+			ls.lapsCompleted[driver.DriverName] = driver.LapsCompleted
+			if driver.LapsCompleted > 0 {
+				ls.laps = append(ls.laps, newLap(driver))
+			}
+		}
+	}
+	if !ls.session.Finished {
+		ls.session.EndedAt = time.Now()
+	}
+	ls.dirty = true
+}
+
+// closeSession closes the session of a server that went offline.
+func (r *Recorder) closeSession(serverId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closeLocked(serverId)
+}
+
+func (r *Recorder) closeLocked(serverId string) {
+	ls, found := r.live[serverId]
+	if !found {
+		return
+	}
+	delete(r.live, serverId)
+	if len(ls.entries) > 0 {
+		r.closed = append(r.closed, ls)
+	}
+}
+
+// save writes the closed sessions and the standings of the live ones that
+// changed since the last save. Sessions without drivers are not saved.
+func (r *Recorder) save() {
+	type result struct {
+		ls      *liveSession
+		session Session
+		entries []Entry
+		laps    []Lap
+	}
+
+	r.mu.Lock()
+	pending := []result{}
+	lss := r.closed
+	r.closed = nil
+	for _, ls := range r.live {
+		if ls.dirty && len(ls.entries) > 0 {
+			lss = append(lss, ls)
+		}
+	}
+	for _, ls := range lss {
+		entries := []Entry{}
+		for _, entry := range ls.entries {
+			entries = append(entries, *entry)
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Position == entries[j].Position {
+				return entries[i].Driver < entries[j].Driver
+			}
+			return entries[i].Position < entries[j].Position
+		})
+		pending = append(pending, result{ls: ls, session: ls.session, entries: entries, laps: ls.laps})
+		ls.laps = nil
+		ls.dirty = false
+	}
+	r.mu.Unlock()
+
+	for _, p := range pending {
+		err := r.archiver.SaveResult(&p.session, p.entries, p.laps)
+		r.mu.Lock()
+		if err != nil {
+			log.Printf("Error saving results of %s in server %s: %s", p.session.Type, p.session.ServerID, err.Error())
+			// keep the laps for the next save
+			p.ls.laps = append(p.laps, p.ls.laps...)
+			p.ls.dirty = true
+			if r.live[p.session.ServerID] != p.ls && !r.isClosed(p.ls) {
+				r.closed = append(r.closed, p.ls)
+			}
+		} else {
+			p.ls.session.ID = p.session.ID
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *Recorder) isClosed(ls *liveSession) bool {
+	for _, closed := range r.closed {
+		if closed == ls {
+			return true
+		}
+	}
+	return false
+}
+
+func newLap(driver model.StandingDriverData) Lap {
+	lap := Lap{
+		Driver: driver.DriverName,
+		Lap:    driver.LapsCompleted,
+		Time:   driver.LastLapTime,
+		S1:     driver.LastSectorTime1,
+		S2:     -1,
+		S3:     -1,
+	}
+	// the second sector time is reported as the sum of the first two sectors
+	if driver.LastSectorTime1 > 0 && driver.LastSectorTime2 > 0 {
+		lap.S2 = driver.LastSectorTime2 - driver.LastSectorTime1
+		if driver.LastLapTime > 0 {
+			lap.S3 = driver.LastLapTime - driver.LastSectorTime2
+		}
+	}
+	return lap
+}
@@ -0,0 +1,54 @@
+package results
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/locale"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+func (rm *Manager) RenderSessions(kind string) func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := rm.locale.Localizer(ctx)
+		ss, err := rm.GetSessions(kind)
+		if err != nil {
+			return err
+		}
+		if len(ss) == 0 {
+			message := locale.Localize(loc, msgNoSessions)
+			msg := tgbotapi.NewMessage(chatId, message)
+			_, err = rm.bot.Send(msg)
+			return err
+		}
+		return SendSessionsData(chatId, kind, ss, 0, sessionsPerPage, nil, rm, loc)
+	}
+}
+
+func (rm *Manager) RenderShowSessionsCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+		loc := rm.locale.Localizer(ctx)
+		return HandleSessionsCallbackQuery(query.Message.Chat.ID, query.Message.MessageID, rm, loc, data[1:]...)
+	}
+}
+
+func (rm *Manager) RenderResult(sessionId int64) func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := rm.locale.Localizer(ctx)
+		return SendResultData(chatId, nil, sessionId, inlineKeyboardClassification, rm, loc)
+	}
+}
+
+func (rm *Manager) RenderResultDataCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+		loc := rm.locale.Localizer(ctx)
+		return HandleResultDataCallbackQuery(query.Message.Chat.ID, &query.Message.MessageID, rm, loc, data[1:]...)
+	}
+}
+
+func (rm *Manager) renderSessionNotFound(chatId int64, loc *i18n.Localizer) error {
+	message := locale.Localize(loc, msgSessionNotFound)
+	msg := tgbotapi.NewMessage(chatId, message)
+	_, err := rm.bot.Send(msg)
+	return err
+}
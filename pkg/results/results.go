@@ -0,0 +1,135 @@
+package results
+
+import (
+	"f1champshotlapsbot/pkg/locale"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	KindPractice   = "practice"
+	KindQualifying = "qualifying"
+	KindRace       = "race"
+
+	// finish status reported by the rFactor2 servers
+	FinishStatusNone     = "FSTAT_NONE"
+	FinishStatusFinished = "FSTAT_FINISHED"
+	FinishStatusDNF      = "FSTAT_DNF"
+	FinishStatusDQ       = "FSTAT_DQ"
+)
+
+var (
+	// kindPrefixes are the prefixes of the rFactor2 session names of every kind
+	kindPrefixes = map[string]string{
+		KindPractice:   "PRACTICE",
+		KindQualifying: "QUALIFY",
+		KindRace:       "RACE",
+	}
+)
+
+// Storer reads the results of the sessions archived from the live servers.
+type Storer interface {
+	ListResultSessions(typePrefix string) ([]Session, error)
+	GetResultSession(id int64) (Session, bool, error)
+	ListResultEntries(sessionId int64) ([]Entry, error)
+	ListResultLaps(sessionId int64) ([]Lap, error)
+	Archiver
+}
+
+// Session is a session played in one of the rFactor2 servers.
+type Session struct {
+	ID         int64
+	ServerID   string
+	ServerName string
+	TrackName  string
+	// Type is the session name given by the server: PRACTICE1, QUALIFY1, RACE1...
+	Type      string
+	StartedAt time.Time
+	EndedAt   time.Time
+	// Finished is set once the final classification is written
+	Finished bool
+}
+
+// Kind returns the kind of the session, empty if it is not a practice,
+// qualifying or race session.
+func (s Session) Kind() string {
+	for kind, prefix := range kindPrefixes {
+		if strings.HasPrefix(strings.ToUpper(s.Type), prefix) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// Entry is the classification of a driver in a session.
+type Entry struct {
+	Position     int
+	Driver       string
+	CarClass     string
+	Vehicle      string
+	BestLap      float64
+	S1           float64
+	S2           float64
+	S3           float64
+	Laps         int
+	Pitstops     int
+	FinishStatus string
+	// TimeBehindLeader and LapsBehindLeader are only meaningful in races
+	TimeBehindLeader float64
+	LapsBehindLeader float64
+}
+
+// Lap is a lap completed by a driver in a session. Times not available are
+// negative.
+type Lap struct {
+	Driver string
+	Lap    int
+	Time   float64
+	S1     float64
+	S2     float64
+	S3     float64
+}
+
+type Manager struct {
+	bot    *tgbotapi.BotAPI
+	store  Storer
+	locale *locale.Manager
+}
+
+func NewManager(bot *tgbotapi.BotAPI, store Storer, lm *locale.Manager) *Manager {
+	return &Manager{
+		bot:    bot,
+		store:  store,
+		locale: lm,
+	}
+}
+
+// GetSessions returns the archived sessions of a kind, the newest first.
+func (rm *Manager) GetSessions(kind string) ([]Session, error) {
+	return rm.store.ListResultSessions(kindPrefixes[kind])
+}
+
+// GetSession returns an archived session and its classification.
+func (rm *Manager) GetSession(id int64) (Session, []Entry, bool, error) {
+	s, found, err := rm.store.GetResultSession(id)
+	if err != nil || !found {
+		return s, nil, found, err
+	}
+	entries, err := rm.store.ListResultEntries(id)
+	return s, entries, true, err
+}
+
+// GetLaps returns the laps completed in an archived session by every driver.
+func (rm *Manager) GetLaps(id int64) (map[string][]Lap, error) {
+	laps, err := rm.store.ListResultLaps(id)
+	if err != nil {
+		return nil, err
+	}
+	byDriver := map[string][]Lap{}
+	for _, lap := range laps {
+		byDriver[lap.Driver] = append(byDriver[lap.Driver], lap)
+	}
+	return byDriver, nil
+}
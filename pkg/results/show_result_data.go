@@ -0,0 +1,250 @@
+package results
+
+import (
+	"bytes"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"strconv"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	inlineKeyboardClassification = "classification"
+	inlineKeyboardBest           = "best"
+	inlineKeyboardSectors        = "sectors"
+	inlineKeyboardLaps           = "laps"
+	inlineKeyboardStatus         = "status"
+	inlineKeyboardCars           = "cars"
+	inlineKeyboardDrivers        = "drivers"
+	inlineKeyboardPace           = "pace"
+
+	symbolClassification = "🏆"
+	symbolBest           = "⏱"
+	symbolSectors        = "🔂"
+	symbolLaps           = "🏁"
+	symbolStatus         = "🚩"
+	symbolCars           = "🏎️"
+	symbolDrivers        = "👐"
+	symbolPace           = "📈"
+	symbolNoTime         = "-"
+
+	SubcommandShowResultData = "show_result_data"
+)
+
+var (
+	inlineKeyboardLabels = map[string]*i18n.Message{
+		inlineKeyboardClassification: msgKeyboardClassification,
+		inlineKeyboardBest:           msgKeyboardBest,
+		inlineKeyboardSectors:        msgKeyboardSectors,
+		inlineKeyboardLaps:           msgKeyboardLaps,
+		inlineKeyboardStatus:         msgKeyboardStatus,
+		inlineKeyboardCars:           msgKeyboardCars,
+		inlineKeyboardDrivers:        msgKeyboardDrivers,
+		inlineKeyboardPace:           msgKeyboardPace,
+	}
+	finishStatuses = map[string]*i18n.Message{
+		FinishStatusNone:     msgStatusRunning,
+		FinishStatusFinished: msgStatusFinished,
+		FinishStatusDNF:      msgStatusDNF,
+		FinishStatusDQ:       msgStatusDQ,
+	}
+)
+
+func HandleResultDataCallbackQuery(chatId int64, messageId *int, rm *Manager, loc *i18n.Localizer, data ...string) error {
+	infoType := data[0]
+	sessionId, err := strconv.ParseInt(data[1], 10, 64)
+	if err != nil {
+		return rm.renderSessionNotFound(chatId, loc)
+	}
+	return SendResultData(chatId, messageId, sessionId, infoType, rm, loc)
+}
+
+func SendResultData(chatId int64, messageId *int, sessionId int64, infoType string, rm *Manager, loc *i18n.Localizer) error {
+	session, entries, found, err := rm.GetSession(sessionId)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return rm.renderSessionNotFound(chatId, loc)
+	}
+	if len(entries) == 0 {
+		message := locale.Localize(loc, msgNoEntries)
+		msg := tgbotapi.NewMessage(chatId, message)
+		_, err := rm.bot.Send(msg)
+		return err
+	}
+
+	var b bytes.Buffer
+	t := table.NewWriter()
+	t.SetOutputMirror(&b)
+	style := table.StyleRounded
+	style.Options.DrawBorder = false
+	t.SetStyle(style)
+	t.AppendSeparator()
+
+	var laps map[string][]Lap
+	if infoType == inlineKeyboardPace {
+		laps, err = rm.GetLaps(session.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	isRace := session.Kind() == KindRace
+	leaderBest := entries[0].BestLap
+	for _, entry := range entries {
+		if entry.BestLap > 0 && (leaderBest <= 0 || entry.BestLap < leaderBest) {
+			leaderBest = entry.BestLap
+		}
+	}
+
+	t.AppendHeader(table.Row{locale.Localize(loc, msgHeaderPosition), locale.Localize(loc, msgHeaderDriver), inlineKeyboardLabel(loc, infoType)})
+	for _, entry := range entries {
+		var value string
+		switch infoType {
+		case inlineKeyboardClassification:
+			value = classificationGap(entry, isRace, leaderBest)
+		case inlineKeyboardBest:
+			value = lapTime(entry.BestLap)
+		case inlineKeyboardSectors:
+			value = fmt.Sprintf("%s %s %s", sectorTime(entry.S1), sectorTime(entry.S2), sectorTime(entry.S3))
+		case inlineKeyboardLaps:
+			value = fmt.Sprint(entry.Laps)
+		case inlineKeyboardStatus:
+			value = fmt.Sprintf("%s (%d)", finishStatus(entry.FinishStatus, loc), entry.Pitstops)
+		case inlineKeyboardCars:
+			value = entry.CarClass
+		case inlineKeyboardDrivers:
+			value = entry.Driver
+		case inlineKeyboardPace:
+			value = pace(laps[entry.Driver])
+		}
+		t.AppendRow([]interface{}{
+			entry.Position,
+			helper.GetDriverCodeName(entry.Driver),
+			value,
+		})
+	}
+	t.Render()
+	if !session.Finished {
+		b.WriteString("\n" + locale.Localize(loc, msgInProgress) + "\n")
+	}
+
+	keyboard := getInlineKeyboardForSession(session.ID, loc)
+	title := fmt.Sprintf(locale.Localize(loc, msgResults), session.Type, session.TrackName, session.ServerName, session.StartedAt.Local().Format(dateFormat))
+	text := fmt.Sprintf("```\n%s\n\n%s```", title, b.String())
+	return rm.sendOrEdit(chatId, messageId, text, tgbotapi.ModeMarkdownV2, keyboard)
+}
+
+// classificationGap returns the gap to the leader: the race time (or laps)
+// in races and the best lap time in the rest of sessions.
+func classificationGap(entry Entry, isRace bool, leaderBest float64) string {
+	if entry.Position == 1 {
+		if isRace {
+			return fmt.Sprint(entry.Laps)
+		}
+		return lapTime(entry.BestLap)
+	}
+	if isRace {
+		if entry.LapsBehindLeader >= 1 {
+			return fmt.Sprintf("+%.0fL", entry.LapsBehindLeader)
+		}
+		return helper.SecondsToDiff(entry.TimeBehindLeader)
+	}
+	if entry.BestLap <= 0 {
+		return symbolNoTime
+	}
+	return helper.SecondsToDiff(entry.BestLap - leaderBest)
+}
+
+// pace returns the average time of the timed laps and how many they are.
+func pace(laps []Lap) string {
+	total := 0.0
+	timed := 0
+	for _, lap := range laps {
+		if lap.Time > 0 {
+			total += lap.Time
+			timed++
+		}
+	}
+	if timed == 0 {
+		return symbolNoTime
+	}
+	return fmt.Sprintf("%s (%d)", helper.SecondsToMinutes(total/float64(timed)), timed)
+}
+
+func lapTime(t float64) string {
+	if t <= 0 {
+		return symbolNoTime
+	}
+	return helper.SecondsToMinutes(t)
+}
+
+func sectorTime(t float64) string {
+	if t <= 0 {
+		return symbolNoTime
+	}
+	return helper.ToSectorTime(t)
+}
+
+func finishStatus(status string, loc *i18n.Localizer) string {
+	if msg, found := finishStatuses[status]; found {
+		return locale.Localize(loc, msg)
+	}
+	return status
+}
+
+// sendOrEdit sends a new message or edits the given one with the text and keyboard.
+func (rm *Manager) sendOrEdit(chatId int64, messageId *int, text, parseMode string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	var cfg tgbotapi.Chattable
+	if messageId == nil {
+		msg := tgbotapi.NewMessage(chatId, text)
+		msg.ParseMode = parseMode
+		msg.ReplyMarkup = keyboard
+		cfg = msg
+	} else {
+		msg := tgbotapi.NewEditMessageText(chatId, *messageId, text)
+		msg.ParseMode = parseMode
+		msg.ReplyMarkup = &keyboard
+		cfg = msg
+	}
+	_, err := rm.bot.Send(cfg)
+	return err
+}
+
+func inlineKeyboardLabel(loc *i18n.Localizer, infoType string) string {
+	if msg, found := inlineKeyboardLabels[infoType]; found {
+		return locale.Localize(loc, msg)
+	}
+	return infoType
+}
+
+func inlineKeyboardButton(loc *i18n.Localizer, infoType, symbol string, sessionId int64) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(inlineKeyboardLabel(loc, infoType)+" "+symbol, fmt.Sprintf("%s:%s:%d", SubcommandShowResultData, infoType, sessionId))
+}
+
+func getInlineKeyboardForSession(sessionId int64, loc *i18n.Localizer) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			inlineKeyboardButton(loc, inlineKeyboardClassification, symbolClassification, sessionId),
+			inlineKeyboardButton(loc, inlineKeyboardBest, symbolBest, sessionId),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			inlineKeyboardButton(loc, inlineKeyboardSectors, symbolSectors, sessionId),
+			inlineKeyboardButton(loc, inlineKeyboardLaps, symbolLaps, sessionId),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			inlineKeyboardButton(loc, inlineKeyboardStatus, symbolStatus, sessionId),
+			inlineKeyboardButton(loc, inlineKeyboardCars, symbolCars, sessionId),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			inlineKeyboardButton(loc, inlineKeyboardDrivers, symbolDrivers, sessionId),
+			inlineKeyboardButton(loc, inlineKeyboardPace, symbolPace, sessionId),
+		),
+	)
+}
@@ -0,0 +1,119 @@
+package results
+
+import (
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	SubcommandShowSessions = "show_results"
+	CommandResult          = "/result"
+
+	symbolInit = "⏮"
+	symbolPrev = "◀️"
+	symbolNext = "▶️"
+	symbolEnd  = "⏭"
+
+	sessionsPerPage = 10
+	dateFormat      = "2006-01-02 15:04"
+)
+
+var (
+	chooseMessages = map[string]*i18n.Message{
+		KindPractice:   msgChoosePractice,
+		KindQualifying: msgChooseQualifying,
+		KindRace:       msgChooseRace,
+	}
+)
+
+func SendSessionsData(chatId int64, kind string, ss []Session, currentPage, count int, messageId *int, rm *Manager, loc *i18n.Localizer) error {
+	text, keyboard := SessionsTextMarkup(kind, ss, currentPage, count, loc)
+
+	var cfg tgbotapi.Chattable
+	if messageId == nil {
+		msg := tgbotapi.NewMessage(chatId, text)
+		msg.ReplyMarkup = keyboard
+		cfg = msg
+	} else {
+		msg := tgbotapi.NewEditMessageText(chatId, *messageId, text)
+		msg.ReplyMarkup = &keyboard
+		cfg = msg
+	}
+
+	_, err := rm.bot.Send(cfg)
+	return err
+}
+
+func SessionsTextMarkup(kind string, ss []Session, currentPage, count int, loc *i18n.Localizer) (text string, markup tgbotapi.InlineKeyboardMarkup) {
+	maxPages := pages(len(ss), count)
+	from := currentPage * count
+	if from > len(ss) {
+		from = len(ss)
+	}
+	to := from + count
+	if to > len(ss) {
+		to = len(ss)
+	}
+
+	var lines []string
+	for _, s := range ss[from:to] {
+		lines = append(lines, fmt.Sprintf(" ▸ %s %s\n     %s · %s ➡ %s_%d", s.StartedAt.Local().Format(dateFormat), s.TrackName, s.ServerName, s.Type, CommandResult, s.ID))
+	}
+	text = fmt.Sprintf(locale.Localize(loc, chooseMessages[kind])+"\n\n", currentPage+1, maxPages)
+	text += strings.Join(lines, "\n")
+
+	var rows []tgbotapi.InlineKeyboardButton
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolInit, fmt.Sprintf("%s:init:%d:%d:%s", SubcommandShowSessions, currentPage, count, kind)))
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolPrev, fmt.Sprintf("%s:prev:%d:%d:%s", SubcommandShowSessions, currentPage, count, kind)))
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolNext, fmt.Sprintf("%s:next:%d:%d:%s", SubcommandShowSessions, currentPage, count, kind)))
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolEnd, fmt.Sprintf("%s:end:%d:%d:%s", SubcommandShowSessions, currentPage, count, kind)))
+
+	markup = tgbotapi.NewInlineKeyboardMarkup(rows)
+	return
+}
+
+func HandleSessionsCallbackQuery(chatId int64, messageId int, rm *Manager, loc *i18n.Localizer, data ...string) error {
+	pagerType := data[0]
+	currentPage, _ := strconv.Atoi(data[1])
+	itemsPerPage, _ := strconv.Atoi(data[2])
+	kind := data[3]
+
+	ss, err := rm.GetSessions(kind)
+	if err != nil {
+		return err
+	}
+	maxPages := pages(len(ss), itemsPerPage)
+
+	if pagerType == "next" {
+		nextPage := currentPage + 1
+		if nextPage < maxPages {
+			return SendSessionsData(chatId, kind, ss, nextPage, itemsPerPage, &messageId, rm, loc)
+		}
+	}
+	if pagerType == "prev" {
+		previousPage := currentPage - 1
+		if previousPage >= 0 {
+			return SendSessionsData(chatId, kind, ss, previousPage, itemsPerPage, &messageId, rm, loc)
+		}
+	}
+	if pagerType == "init" && currentPage != 0 {
+		return SendSessionsData(chatId, kind, ss, 0, itemsPerPage, &messageId, rm, loc)
+	}
+	if pagerType == "end" && currentPage != maxPages-1 {
+		return SendSessionsData(chatId, kind, ss, maxPages-1, itemsPerPage, &messageId, rm, loc)
+	}
+	return nil
+}
+
+// pages returns the number of pages needed to show count items, being at least one.
+func pages(items, count int) int {
+	if items == 0 || count <= 0 {
+		return 1
+	}
+	return (items + count - 1) / count
+}
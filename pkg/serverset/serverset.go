@@ -0,0 +1,291 @@
+package serverset
+
+import (
+	"context"
+	"errors"
+	"f1champshotlapsbot/pkg/config"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/livemap"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/servers"
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/webserver"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// serversPath is where the live map of every server is served, under
+	// its ID
+	serversPath = "/servers"
+)
+
+// Manager runs a servers manager for every rFactor2 server so servers can be
+// added and removed while the bot is running. A removed server is not polled
+// anymore, but the servers manager cannot close its open websocket, which
+// keeps publishing under the ID of the server until the rFactor2 server closes
+// it. So a server is only started once while the bot runs: a stopped server
+// cannot be started again, nor the URL or name of a running server changed,
+// without a restart.
+type Manager struct {
+	ctx        context.Context
+	bot        *tgbotapi.BotAPI
+	loc        *i18n.Localizer
+	domain     string
+	running    map[string]*runningServer
+	stopped    map[string]bool
+	order      []string
+	configured []config.Server
+	mu         sync.Mutex
+
+	// liveMaps are the routers of the live maps of the running servers, by
+	// server ID. The web server router cannot be changed while it serves, so
+	// a single handler looks them up.
+	liveMaps   map[string]http.Handler
+	liveMapsMu sync.RWMutex
+}
+
+type runningServer struct {
+	cfg      config.Server
+	ss       []servers.Server
+	sm       *servers.Manager
+	cancel   context.CancelFunc
+	ticker   *time.Ticker
+	exitChan chan bool
+	polling  bool
+}
+
+// ServerStatus is the poll state of a configured server.
+type ServerStatus struct {
+	Config  config.Server
+	Polling bool
+	// Status is the status symbol of the server, empty if it is not running
+	Status string
+}
+
+// Diff holds the IDs of the servers changed by an update.
+type Diff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d Diff) String() string {
+	if d.Empty() {
+		return "no changes"
+	}
+	parts := []string{}
+	if len(d.Added) > 0 {
+		parts = append(parts, fmt.Sprintf("added: %s", strings.Join(d.Added, ", ")))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, fmt.Sprintf("removed: %s", strings.Join(d.Removed, ", ")))
+	}
+	if len(d.Changed) > 0 {
+		parts = append(parts, fmt.Sprintf("changed: %s", strings.Join(d.Changed, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func NewManager(ctx context.Context, bot *tgbotapi.BotAPI, ws *webserver.Manager, domain string, loc *i18n.Localizer) *Manager {
+	m := &Manager{
+		ctx:      ctx,
+		bot:      bot,
+		loc:      loc,
+		domain:   domain,
+		running:  make(map[string]*runningServer),
+		stopped:  make(map[string]bool),
+		liveMaps: make(map[string]http.Handler),
+	}
+	ws.GetRouter(serversPath, serversPath).PathPrefix("/{id}/").HandlerFunc(m.serveLiveMap)
+	return m
+}
+
+// serveLiveMap serves the live map of a running server.
+func (m *Manager) serveLiveMap(w http.ResponseWriter, r *http.Request) {
+	m.liveMapsMu.RLock()
+	h, found := m.liveMaps[mux.Vars(r)["id"]]
+	m.liveMapsMu.RUnlock()
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// Update adds the new servers and stops the removed and disabled ones. A new
+// poll interval is applied in place. The servers whose URL or name changed
+// keep running as they were, and the servers stopped before are not started
+// again, both need a restart and are reported in the error. The new servers
+// are not polled until Start is called, so the apps can subscribe to their
+// data first.
+func (m *Manager) Update(configured []config.Server) (Diff, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.configured = configured
+	cfgServers := []config.Server{}
+	wanted := map[string]bool{}
+	for _, cfgServer := range configured {
+		if cfgServer.IsEnabled() {
+			cfgServers = append(cfgServers, cfgServer)
+			wanted[cfgServer.ID] = true
+		}
+	}
+
+	diff := Diff{}
+
+	for _, id := range m.order {
+		if !wanted[id] {
+			m.stop(id)
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+
+	errs := []error{}
+	order := []string{}
+	for _, cfgServer := range cfgServers {
+		rs, found := m.running[cfgServer.ID]
+		if !found && m.stopped[cfgServer.ID] {
+			errs = append(errs, fmt.Errorf("server %s was stopped, restart the bot to start it again", cfgServer.ID))
+			continue
+		} else if !found {
+			err := m.start(cfgServer)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error starting server %s: %w", cfgServer.ID, err))
+				continue
+			}
+			diff.Added = append(diff.Added, cfgServer.ID)
+		} else if rs.cfg.URL != cfgServer.URL || rs.cfg.Name != cfgServer.Name {
+			errs = append(errs, fmt.Errorf("the URL and name of server %s cannot change while it runs, restart the bot to apply them", cfgServer.ID))
+		} else if rs.cfg.PollInterval != cfgServer.PollInterval {
+			rs.cfg = cfgServer
+			if rs.polling {
+				rs.ticker.Reset(cfgServer.PollInterval)
+			}
+			diff.Changed = append(diff.Changed, cfgServer.ID)
+		}
+		order = append(order, cfgServer.ID)
+	}
+	m.order = order
+	return diff, errors.Join(errs...)
+}
+
+// Servers returns the running servers in the configured order.
+func (m *Manager) Servers() []servers.Server {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ss := []servers.Server{}
+	for _, id := range m.order {
+		ss = append(ss, m.running[id].ss...)
+	}
+	return ss
+}
+
+// Status returns the poll state of every configured server, including the
+// disabled ones.
+func (m *Manager) Status() []ServerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := []ServerStatus{}
+	for _, cfgServer := range m.configured {
+		status := ServerStatus{Config: cfgServer}
+		if rs, found := m.running[cfgServer.ID]; found {
+			status.Polling = rs.polling
+			status.Status = rs.ss[0].Status()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Start polls the servers that are not being polled yet.
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range m.order {
+		rs := m.running[id]
+		if rs.polling {
+			continue
+		}
+		rs.ticker = time.NewTicker(rs.cfg.PollInterval)
+		rs.polling = true
+		go rs.sm.Sync(rs.ticker, rs.exitChan)
+		log.Printf("Polling server %s (%s) every %s\n", rs.cfg.ID, rs.cfg.URL, rs.cfg.PollInterval)
+	}
+}
+
+// Stop stops polling all the servers.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range m.order {
+		m.stop(id)
+	}
+	m.order = nil
+}
+
+func (m *Manager) start(cfgServer config.Server) error {
+	path := serversPath + "/" + cfgServer.ID
+	r := mux.NewRouter()
+	liveMap := livemap.NewLiveMap(r.PathPrefix(path).Subrouter(), cfgServer.ID, path, m.loc)
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	ss := []servers.Server{servers.NewServer(cfgServer.ID, cfgServer.URL, m.domain)}
+	// the servers manager registers the livemap of every server under its
+	// index, so it gets a scratch router. It also sets the name and the
+	// livemap to its own, so they are replaced right away, before the server
+	// is polled: its goroutines only read them once the server sends data.
+	sm, err := servers.NewManager(ctx, m.bot, ss, webserver.NewManager(), m.loc)
+	if err != nil {
+		cancel()
+		return err
+	}
+	ss[0].Name = cfgServer.Name
+	ss[0].LiveMapPath = path
+	ss[0].LiveMap = liveMap
+	m.liveMapsMu.Lock()
+	m.liveMaps[cfgServer.ID] = r
+	m.liveMapsMu.Unlock()
+
+	m.running[cfgServer.ID] = &runningServer{
+		cfg:      cfgServer,
+		ss:       ss,
+		sm:       sm,
+		cancel:   cancel,
+		exitChan: make(chan bool, 1),
+	}
+	return nil
+}
+
+func (m *Manager) stop(id string) {
+	rs, found := m.running[id]
+	if !found {
+		return
+	}
+	if rs.polling {
+		rs.ticker.Stop()
+		rs.exitChan <- true
+		log.Printf("Stopped polling server %s\n", id)
+	}
+	rs.cancel()
+	delete(m.running, id)
+	m.stopped[id] = true
+	m.liveMapsMu.Lock()
+	delete(m.liveMaps, id)
+	m.liveMapsMu.Unlock()
+}
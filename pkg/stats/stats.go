@@ -0,0 +1,121 @@
+package stats
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	KindCommand  = "command"
+	KindButton   = "button"
+	KindCallback = "callback"
+)
+
+var (
+	commandTrack         = regexp.MustCompile(`^\/\d+$`)
+	commandTrackCategory = regexp.MustCompile(`^\/\d+_.+$`)
+	commandResult        = regexp.MustCompile(`^\/result_\d+$`)
+)
+
+// Stats counts the usage of the bot since it started.
+type Stats struct {
+	started time.Time
+	counts  map[string]map[string]int
+	users   map[int64]bool
+	chats   map[int64]bool
+	mu      sync.Mutex
+}
+
+// Count is the number of uses of a command, button or callback.
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Snapshot is a copy of the counters at a given time.
+type Snapshot struct {
+	Uptime time.Duration
+	Users  int
+	Chats  int
+	Totals map[string]int
+	// Counts holds the counts of every kind sorted by use
+	Counts map[string][]Count
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		started: time.Now(),
+		counts: map[string]map[string]int{
+			KindCommand:  {},
+			KindButton:   {},
+			KindCallback: {},
+		},
+		users: make(map[int64]bool),
+		chats: make(map[int64]bool),
+	}
+}
+
+// Record counts a use of the bot. Commands are counted without their
+// arguments and callbacks by their subcommand.
+func (s *Stats) Record(kind, name string, userId, chatId int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts[kind][normalize(kind, name)]++
+	s.users[userId] = true
+	s.chats[chatId] = true
+}
+
+func (s *Stats) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := Snapshot{
+		Uptime: time.Since(s.started),
+		Users:  len(s.users),
+		Chats:  len(s.chats),
+		Totals: make(map[string]int),
+		Counts: make(map[string][]Count),
+	}
+	for kind, counts := range s.counts {
+		cs := []Count{}
+		for name, count := range counts {
+			cs = append(cs, Count{Name: name, Count: count})
+			snapshot.Totals[kind] += count
+		}
+		sort.Slice(cs, func(i, j int) bool {
+			if cs[i].Count == cs[j].Count {
+				return cs[i].Name < cs[j].Name
+			}
+			return cs[i].Count > cs[j].Count
+		})
+		snapshot.Counts[kind] = cs
+	}
+	return snapshot
+}
+
+func normalize(kind, name string) string {
+	switch kind {
+	case KindCommand:
+		command := strings.Fields(name)
+		if len(command) == 0 {
+			return name
+		}
+		if commandTrack.MatchString(command[0]) {
+			return "/<track>"
+		}
+		if commandTrackCategory.MatchString(command[0]) {
+			return "/<track>_<category>"
+		}
+		if commandResult.MatchString(command[0]) {
+			return "/result_<session>"
+		}
+		return command[0]
+	case KindCallback:
+		return strings.Split(name, ":")[0]
+	}
+	return name
+}
@@ -0,0 +1,321 @@
+package store
+
+import (
+	"database/sql"
+	"f1champshotlapsbot/pkg/results"
+	"f1champshotlapsbot/pkg/tracks"
+	"log"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DbName = "./hotlaps-bot.db"
+)
+
+type Manager struct {
+	db *sql.DB
+	mu sync.Mutex
+}
+
+func NewManager(dbName string) (*Manager, error) {
+	db, err := sql.Open("sqlite", dbName)
+	if err != nil {
+		log.Printf("error opening database: %s\n", err)
+		return nil, err
+	}
+
+	for _, initTableStmt := range buildCreateHotlapsTables() {
+		_, err = db.Exec(initTableStmt)
+		if err != nil {
+			log.Printf("error init database: %s\n", err)
+			return nil, err
+		}
+	}
+
+	return &Manager{
+		db: db,
+		mu: sync.Mutex{},
+	}, nil
+}
+
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.db.Close()
+}
+
+// SaveTracks stores the tracklist keeping the order in which the API returned
+// it. The tracks not returned anymore are removed.
+func (m *Manager) SaveTracks(ts []*tracks.Track) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(buildDeleteTracksCommand())
+	if err != nil {
+		log.Printf("error updating database: %s\n", err)
+		tx.Rollback()
+		return err
+	}
+	for idx, t := range ts {
+		_, err = tx.Exec(buildUpsertTrackCommand(), t.ID, t.Name, idx)
+		if err != nil {
+			log.Printf("error updating database: %s\n", err)
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *Manager) ListTracks() ([]*tracks.Track, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, err := m.db.Query(buildSelectTracksCommand())
+	if err != nil {
+		return nil, err
+	}
+	return processSelectTracksRows(rows)
+}
+
+// SaveSessions replaces the current sessions of a track. Sessions not returned
+// by the API anymore are kept as history but flagged as inactive.
+func (m *Manager) SaveSessions(trackId string, ss []tracks.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(buildDeactivateSessionsCommand(), trackId)
+	if err != nil {
+		log.Printf("error updating database: %s\n", err)
+		tx.Rollback()
+		return err
+	}
+	for _, s := range ss {
+		_, err = tx.Exec(buildUpsertSessionCommand(), sessionValues(trackId, s)...)
+		if err != nil {
+			log.Printf("error updating database: %s\n", err)
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListSessions returns the sessions of a track seen in the last successful sync.
+func (m *Manager) ListSessions(trackId string) ([]tracks.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, err := m.db.Query(buildSelectSessionsCommand(), trackId)
+	if err != nil {
+		return nil, err
+	}
+	return processSelectSessionsRows(rows)
+}
+
+// ListSessionHistory returns every session ever stored for a track, including
+// the ones the API does not return anymore.
+func (m *Manager) ListSessionHistory(trackId string) ([]tracks.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, err := m.db.Query(buildSelectSessionHistoryCommand(), trackId)
+	if err != nil {
+		return nil, err
+	}
+	return processSelectSessionsRows(rows)
+}
+
+func (m *Manager) ToggleSubscription(chatId int64, trackId, categoryId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscribed, err := m.isSubscribed(chatId, trackId, categoryId)
+	if err != nil {
+		return false, err
+	}
+	stmt := buildInsertSubscriptionCommand()
+	if subscribed {
+		stmt = buildDeleteSubscriptionCommand()
+	}
+	_, err = m.db.Exec(stmt, chatId, trackId, categoryId)
+	if err != nil {
+		log.Printf("error updating database: %s\n", err)
+		return subscribed, err
+	}
+	return !subscribed, nil
+}
+
+func (m *Manager) IsSubscribed(chatId int64, trackId, categoryId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.isSubscribed(chatId, trackId, categoryId)
+}
+
+func (m *Manager) ListSubscribedChats(trackId, categoryId string) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, err := m.db.Query(buildSelectSubscribedChatsCommand(), trackId, categoryId)
+	if err != nil {
+		return nil, err
+	}
+	return processSelectChatsRows(rows)
+}
+
+// ListAllSubscribedChats returns the chats subscribed to any hotlaps leaderboard.
+func (m *Manager) ListAllSubscribedChats() ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, err := m.db.Query(buildSelectAllSubscribedChatsCommand())
+	if err != nil {
+		return nil, err
+	}
+	return processSelectChatsRows(rows)
+}
+
+func (m *Manager) isSubscribed(chatId int64, trackId, categoryId string) (bool, error) {
+	var count int
+	err := m.db.QueryRow(buildCountSubscriptionCommand(), chatId, trackId, categoryId).Scan(&count)
+	return count > 0, err
+}
+
+func (m *Manager) GetChatLanguage(chatId int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var lang string
+	err := m.db.QueryRow(buildSelectChatLanguageCommand(), chatId).Scan(&lang)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return lang, err
+}
+
+func (m *Manager) SetChatLanguage(chatId int64, lang string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.db.Exec(buildUpsertChatLanguageCommand(), chatId, lang)
+	if err != nil {
+		log.Printf("error updating database: %s\n", err)
+	}
+	return err
+}
+
+// ListResultSessions returns the archived sessions whose type starts with the
+// prefix, the newest first.
+func (m *Manager) ListResultSessions(typePrefix string) ([]results.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, err := m.db.Query(buildSelectResultSessionsCommand(), typePrefix)
+	if err != nil {
+		return nil, err
+	}
+	return processSelectResultSessionsRows(rows)
+}
+
+func (m *Manager) GetResultSession(id int64) (results.Session, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := scanResultSession(m.db.QueryRow(buildSelectResultSessionCommand(), id))
+	if err == sql.ErrNoRows {
+		return s, false, nil
+	}
+	return s, err == nil, err
+}
+
+// ListResultEntries returns the classification of an archived session.
+func (m *Manager) ListResultEntries(sessionId int64) ([]results.Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, err := m.db.Query(buildSelectResultEntriesCommand(), sessionId)
+	if err != nil {
+		return nil, err
+	}
+	return processSelectResultEntriesRows(rows)
+}
+
+// ListResultLaps returns the laps completed by every driver in an archived
+// session.
+func (m *Manager) ListResultLaps(sessionId int64) ([]results.Lap, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, err := m.db.Query(buildSelectResultLapsCommand(), sessionId)
+	if err != nil {
+		return nil, err
+	}
+	return processSelectResultLapsRows(rows)
+}
+
+// SaveResult stores the classification and the new laps of a session. The
+// session is created the first time and its ID is set in s.
+func (m *Manager) SaveResult(s *results.Session, entries []results.Entry, laps []results.Lap) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	id := s.ID
+	if id == 0 {
+		res, err := tx.Exec(buildInsertResultSessionCommand(), s.ServerID, s.ServerName, s.TrackName, s.Type, s.StartedAt.Unix(), s.EndedAt.Unix(), s.Finished)
+		if err != nil {
+			log.Printf("error updating database: %s\n", err)
+			tx.Rollback()
+			return err
+		}
+		id, err = res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else {
+		_, err = tx.Exec(buildUpdateResultSessionCommand(), s.ServerName, s.EndedAt.Unix(), s.Finished, id)
+		if err != nil {
+			log.Printf("error updating database: %s\n", err)
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, e := range entries {
+		_, err = tx.Exec(buildUpsertResultEntryCommand(), resultEntryValues(id, e)...)
+		if err != nil {
+			log.Printf("error updating database: %s\n", err)
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, l := range laps {
+		_, err = tx.Exec(buildUpsertResultLapCommand(), id, l.Driver, l.Lap, l.Time, l.S1, l.S2, l.S3)
+		if err != nil {
+			log.Printf("error updating database: %s\n", err)
+			tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	s.ID = id
+	return nil
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"database/sql"
+	"f1champshotlapsbot/pkg/results"
+	"f1champshotlapsbot/pkg/tracks"
+	"time"
+)
+
+const (
+	sessionFields       = "driver, track_course, s1, s2, s3, time, fuel, fl, fr, rl, rr, fcompound, rcompound, date_time, category, car_type, car_class, team, lapcount, lapcountcomplete"
+	resultSessionFields = "id, server_id, server_name, track_name, type, started_at, ended_at, finished"
+	resultEntryFields   = "position, driver, car_class, vehicle, best_lap, s1, s2, s3, laps, pitstops, finish_status, time_behind_leader, laps_behind_leader"
+	resultLapFields     = "driver, lap, time, s1, s2, s3"
+)
+
+func buildCreateHotlapsTables() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS tracks (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		position INTEGER NOT NULL);`,
+		// the categories are built from the sessions, older databases stored them
+		`DROP TABLE IF EXISTS categories;`,
+		`CREATE TABLE IF NOT EXISTS sessions (
+		track_id TEXT NOT NULL,
+		driver TEXT NOT NULL,
+		track_course TEXT,
+		s1 REAL,
+		s2 REAL,
+		s3 REAL,
+		time REAL NOT NULL,
+		fuel REAL,
+		fl REAL,
+		fr REAL,
+		rl REAL,
+		rr REAL,
+		fcompound TEXT,
+		rcompound TEXT,
+		date_time TEXT NOT NULL,
+		category TEXT NOT NULL,
+		car_type TEXT NOT NULL,
+		car_class TEXT,
+		team TEXT,
+		lapcount INTEGER,
+		lapcountcomplete INTEGER,
+		active INTEGER NOT NULL DEFAULT 1,
+		PRIMARY KEY (track_id, driver, category, car_type, date_time, time));`,
+		`CREATE TABLE IF NOT EXISTS subscriptions (
+		chat_id INTEGER NOT NULL,
+		track_id TEXT NOT NULL,
+		category_id TEXT NOT NULL,
+		PRIMARY KEY (chat_id, track_id, category_id));`,
+		`CREATE TABLE IF NOT EXISTS chat_languages (
+		chat_id INTEGER PRIMARY KEY,
+		lang TEXT NOT NULL);`,
+		`CREATE TABLE IF NOT EXISTS result_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		server_id TEXT NOT NULL,
+		server_name TEXT NOT NULL,
+		track_name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		started_at INTEGER NOT NULL,
+		ended_at INTEGER NOT NULL,
+		finished INTEGER NOT NULL DEFAULT 0);`,
+		`CREATE TABLE IF NOT EXISTS result_entries (
+		session_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		driver TEXT NOT NULL,
+		car_class TEXT,
+		vehicle TEXT,
+		best_lap REAL,
+		s1 REAL,
+		s2 REAL,
+		s3 REAL,
+		laps INTEGER,
+		pitstops INTEGER,
+		finish_status TEXT,
+		time_behind_leader REAL,
+		laps_behind_leader REAL,
+		PRIMARY KEY (session_id, driver));`,
+		`CREATE TABLE IF NOT EXISTS result_laps (
+		session_id INTEGER NOT NULL,
+		driver TEXT NOT NULL,
+		lap INTEGER NOT NULL,
+		time REAL,
+		s1 REAL,
+		s2 REAL,
+		s3 REAL,
+		PRIMARY KEY (session_id, driver, lap));`,
+	}
+}
+
+func buildUpsertTrackCommand() string {
+	return `INSERT OR REPLACE INTO tracks (id, name, position) VALUES (?, ?, ?)`
+}
+
+func buildDeleteTracksCommand() string {
+	return `DELETE FROM tracks`
+}
+
+func buildSelectTracksCommand() string {
+	return `SELECT name FROM tracks ORDER BY position`
+}
+
+func processSelectTracksRows(rows *sql.Rows) ([]*tracks.Track, error) {
+	defer rows.Close()
+
+	ts := make([]*tracks.Track, 0)
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return ts, err
+		}
+		ts = append(ts, tracks.NewTrack(name))
+	}
+	return ts, rows.Err()
+}
+
+func buildDeactivateSessionsCommand() string {
+	return `UPDATE sessions SET active = 0 WHERE track_id = ?`
+}
+
+func buildUpsertSessionCommand() string {
+	return `INSERT OR REPLACE INTO sessions (track_id, ` + sessionFields + `, active)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`
+}
+
+func sessionValues(trackId string, s tracks.Session) []interface{} {
+	return []interface{}{
+		trackId, s.Driver, s.TrackCourse, s.S1, s.S2, s.S3, s.Time, s.Fuel, s.Fl, s.Fr, s.Rl, s.Rr,
+		s.Fcompound, s.Rcompound, s.DateTime, s.Category, s.CarType, s.CarClass, s.Team, s.Lapcount, s.Lapcountcomplete,
+	}
+}
+
+func buildSelectSessionsCommand() string {
+	return `SELECT ` + sessionFields + ` FROM sessions WHERE track_id = ? AND active = 1 ORDER BY time`
+}
+
+func buildSelectSessionHistoryCommand() string {
+	return `SELECT ` + sessionFields + ` FROM sessions WHERE track_id = ? ORDER BY time`
+}
+
+func processSelectSessionsRows(rows *sql.Rows) ([]tracks.Session, error) {
+	defer rows.Close()
+
+	ss := make([]tracks.Session, 0)
+	for rows.Next() {
+		var s tracks.Session
+		err := rows.Scan(&s.Driver, &s.TrackCourse, &s.S1, &s.S2, &s.S3, &s.Time, &s.Fuel, &s.Fl, &s.Fr, &s.Rl, &s.Rr,
+			&s.Fcompound, &s.Rcompound, &s.DateTime, &s.Category, &s.CarType, &s.CarClass, &s.Team, &s.Lapcount, &s.Lapcountcomplete)
+		if err != nil {
+			return ss, err
+		}
+		ss = append(ss, s)
+	}
+	return ss, rows.Err()
+}
+
+func buildInsertSubscriptionCommand() string {
+	return `INSERT OR REPLACE INTO subscriptions (chat_id, track_id, category_id) VALUES (?, ?, ?)`
+}
+
+func buildDeleteSubscriptionCommand() string {
+	return `DELETE FROM subscriptions WHERE chat_id = ? AND track_id = ? AND category_id = ?`
+}
+
+func buildCountSubscriptionCommand() string {
+	return `SELECT COUNT(*) FROM subscriptions WHERE chat_id = ? AND track_id = ? AND category_id = ?`
+}
+
+func buildSelectSubscribedChatsCommand() string {
+	return `SELECT chat_id FROM subscriptions WHERE track_id = ? AND category_id = ?`
+}
+
+func buildSelectAllSubscribedChatsCommand() string {
+	return `SELECT DISTINCT chat_id FROM subscriptions`
+}
+
+func processSelectChatsRows(rows *sql.Rows) ([]int64, error) {
+	defer rows.Close()
+
+	chatIds := make([]int64, 0)
+	for rows.Next() {
+		var chatId int64
+		err := rows.Scan(&chatId)
+		if err != nil {
+			return chatIds, err
+		}
+		chatIds = append(chatIds, chatId)
+	}
+	return chatIds, rows.Err()
+}
+
+func buildSelectChatLanguageCommand() string {
+	return `SELECT lang FROM chat_languages WHERE chat_id = ?`
+}
+
+func buildUpsertChatLanguageCommand() string {
+	return `INSERT OR REPLACE INTO chat_languages (chat_id, lang) VALUES (?, ?)`
+}
+
+func buildInsertResultSessionCommand() string {
+	return `INSERT INTO result_sessions (server_id, server_name, track_name, type, started_at, ended_at, finished)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+}
+
+func buildUpdateResultSessionCommand() string {
+	return `UPDATE result_sessions SET server_name = ?, ended_at = ?, finished = ? WHERE id = ?`
+}
+
+func buildSelectResultSessionsCommand() string {
+	return `SELECT ` + resultSessionFields + ` FROM result_sessions WHERE type LIKE ? || '%' ORDER BY started_at DESC`
+}
+
+func buildSelectResultSessionCommand() string {
+	return `SELECT ` + resultSessionFields + ` FROM result_sessions WHERE id = ?`
+}
+
+// scanner is implemented by both sql.Row and sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanResultSession(row scanner) (results.Session, error) {
+	var s results.Session
+	var startedAt, endedAt int64
+	err := row.Scan(&s.ID, &s.ServerID, &s.ServerName, &s.TrackName, &s.Type, &startedAt, &endedAt, &s.Finished)
+	s.StartedAt = time.Unix(startedAt, 0)
+	s.EndedAt = time.Unix(endedAt, 0)
+	return s, err
+}
+
+func processSelectResultSessionsRows(rows *sql.Rows) ([]results.Session, error) {
+	defer rows.Close()
+
+	ss := make([]results.Session, 0)
+	for rows.Next() {
+		s, err := scanResultSession(rows)
+		if err != nil {
+			return ss, err
+		}
+		ss = append(ss, s)
+	}
+	return ss, rows.Err()
+}
+
+func buildUpsertResultEntryCommand() string {
+	return `INSERT OR REPLACE INTO result_entries (session_id, ` + resultEntryFields + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+}
+
+func resultEntryValues(sessionId int64, e results.Entry) []interface{} {
+	return []interface{}{
+		sessionId, e.Position, e.Driver, e.CarClass, e.Vehicle, e.BestLap, e.S1, e.S2, e.S3,
+		e.Laps, e.Pitstops, e.FinishStatus, e.TimeBehindLeader, e.LapsBehindLeader,
+	}
+}
+
+func buildSelectResultEntriesCommand() string {
+	return `SELECT ` + resultEntryFields + ` FROM result_entries WHERE session_id = ? ORDER BY position`
+}
+
+func processSelectResultEntriesRows(rows *sql.Rows) ([]results.Entry, error) {
+	defer rows.Close()
+
+	entries := make([]results.Entry, 0)
+	for rows.Next() {
+		var e results.Entry
+		err := rows.Scan(&e.Position, &e.Driver, &e.CarClass, &e.Vehicle, &e.BestLap, &e.S1, &e.S2, &e.S3,
+			&e.Laps, &e.Pitstops, &e.FinishStatus, &e.TimeBehindLeader, &e.LapsBehindLeader)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func buildUpsertResultLapCommand() string {
+	return `INSERT OR REPLACE INTO result_laps (session_id, ` + resultLapFields + `) VALUES (?, ?, ?, ?, ?, ?, ?)`
+}
+
+func buildSelectResultLapsCommand() string {
+	return `SELECT ` + resultLapFields + ` FROM result_laps WHERE session_id = ? ORDER BY driver, lap`
+}
+
+func processSelectResultLapsRows(rows *sql.Rows) ([]results.Lap, error) {
+	defer rows.Close()
+
+	laps := make([]results.Lap, 0)
+	for rows.Next() {
+		var l results.Lap
+		err := rows.Scan(&l.Driver, &l.Lap, &l.Time, &l.S1, &l.S2, &l.S3)
+		if err != nil {
+			return laps, err
+		}
+		laps = append(laps, l)
+	}
+	return laps, rows.Err()
+}
@@ -0,0 +1,106 @@
+package trackmap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// The AIW data also has two waypoints for every grid slot, from type 2 to 99,
+// and for every pit garage, from type 100.
+const (
+	// TypeRacingLine is the ordered path of the racing line. It starts at the
+	// start/finish line.
+	TypeRacingLine = 0
+	// TypePitLane is the ordered path of the pit lane, from the pit entry to
+	// the pit exit
+	TypePitLane = 1
+)
+
+var ErrNoWaypoints = errors.New("the AIW data has no waypoints to draw")
+
+// Waypoint is a point of the AIW file of a track. Y is the height.
+type Waypoint struct {
+	Type int     `json:"type"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Z    float64 `json:"z"`
+}
+
+type AIW []Waypoint
+
+// Bounds is the box holding a set of waypoints.
+type Bounds struct {
+	MinX, MaxX float64
+	MinY, MaxY float64
+	MinZ, MaxZ float64
+}
+
+// Width returns the size of the box along the X axis.
+func (b Bounds) Width() float64 {
+	return b.MaxX - b.MinX
+}
+
+// Depth returns the size of the box along the Z axis.
+func (b Bounds) Depth() float64 {
+	return b.MaxZ - b.MinZ
+}
+
+// Parse reads the AIW waypoints encoded as JSON.
+func Parse(r io.Reader) (AIW, error) {
+	var aiw AIW
+	err := json.NewDecoder(r).Decode(&aiw)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing AIW data: %w", err)
+	}
+	return aiw, nil
+}
+
+// Load reads the AIW waypoints from a JSON file.
+func Load(path string) (AIW, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Filter returns the waypoints of the given types, keeping their order.
+func (aiw AIW) Filter(types ...int) AIW {
+	included := map[int]bool{}
+	for _, t := range types {
+		included[t] = true
+	}
+	filtered := AIW{}
+	for _, w := range aiw {
+		if included[w.Type] {
+			filtered = append(filtered, w)
+		}
+	}
+	return filtered
+}
+
+// Bounds returns the box holding all the waypoints.
+func (aiw AIW) Bounds() (Bounds, error) {
+	if len(aiw) == 0 {
+		return Bounds{}, ErrNoWaypoints
+	}
+	b := Bounds{
+		MinX: math.Inf(1), MaxX: math.Inf(-1),
+		MinY: math.Inf(1), MaxY: math.Inf(-1),
+		MinZ: math.Inf(1), MaxZ: math.Inf(-1),
+	}
+	for _, w := range aiw {
+		b.MinX = math.Min(b.MinX, w.X)
+		b.MaxX = math.Max(b.MaxX, w.X)
+		b.MinY = math.Min(b.MinY, w.Y)
+		b.MaxY = math.Max(b.MaxY, w.Y)
+		b.MinZ = math.Min(b.MinZ, w.Z)
+		b.MaxZ = math.Max(b.MaxZ, w.Z)
+	}
+	return b, nil
+}
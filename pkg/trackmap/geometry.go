@@ -0,0 +1,222 @@
+package trackmap
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// resampleStep is the distance in metres between the samples of the
+	// racing line used to find the corners
+	resampleStep = 5.0
+	// curvatureWindow is the number of samples at each side of a sample used
+	// to measure how much the racing line turns there
+	curvatureWindow = 4
+	// minCurvature is the curvature of a corner, in radians per metre. It
+	// matches a radius of 150 metres.
+	minCurvature = 1.0 / 150
+	// maxCornerGap is the longest straight part, in metres, inside a corner
+	// turning to the same side, like a double apex
+	maxCornerGap = 30.0
+	// minCornerAngle leaves out the kinks that are taken flat out
+	minCornerAngle = 25 * math.Pi / 180
+)
+
+// Path is an ordered list of waypoints with the distance of each one from the
+// first waypoint.
+type Path struct {
+	Waypoints AIW
+	Distances []float64
+	// Closed tells whether the path ends next to where it starts, as the
+	// racing line of a circuit does
+	Closed bool
+}
+
+// Corner is a corner of the racing line.
+type Corner struct {
+	// Number is the position of the corner in the lap, starting by 1
+	Number int
+	// Distance is the distance in metres of the apex from the start of the path
+	Distance float64
+	Apex     Waypoint
+	// Angle is the heading change along the corner in radians. Corners turning
+	// to opposite sides have opposite signs.
+	Angle float64
+}
+
+// NewPath returns the path through the waypoints in order.
+func NewPath(waypoints AIW) Path {
+	p := Path{
+		Waypoints: waypoints,
+		Distances: make([]float64, len(waypoints)),
+	}
+	for i := 1; i < len(waypoints); i++ {
+		p.Distances[i] = p.Distances[i-1] + distance(waypoints[i-1], waypoints[i])
+	}
+	if len(waypoints) > 2 {
+		step := p.Distances[len(waypoints)-1] / float64(len(waypoints)-1)
+		p.Closed = distance(waypoints[0], waypoints[len(waypoints)-1]) <= 4*step
+	}
+	return p
+}
+
+// Length returns the length of the path in metres, including the way back to
+// the first waypoint when it is closed.
+func (p Path) Length() float64 {
+	if len(p.Waypoints) == 0 {
+		return 0
+	}
+	last := len(p.Waypoints) - 1
+	if p.Closed {
+		return p.Distances[last] + distance(p.Waypoints[last], p.Waypoints[0])
+	}
+	return p.Distances[last]
+}
+
+// At returns the point of the path at the distance from its start, along with
+// the direction of the path there as a unit vector in the XZ plane. The
+// distance wraps around closed paths and it is clamped to the ends of open
+// ones. The path cannot be empty.
+func (p Path) At(d float64) (Waypoint, float64, float64) {
+	n := len(p.Waypoints)
+	if n == 1 {
+		return p.Waypoints[0], 1, 0
+	}
+	length := p.Length()
+	if p.Closed {
+		d = math.Mod(d, length)
+		if d < 0 {
+			d += length
+		}
+	} else {
+		d = math.Max(0, math.Min(d, length))
+	}
+
+	// the segment from the waypoint i to the next one holds the distance
+	i := sort.SearchFloat64s(p.Distances, d) - 1
+	if i < 0 {
+		i = 0
+	}
+	if !p.Closed && i > n-2 {
+		i = n - 2
+	}
+	from, to := p.Waypoints[i], p.Waypoints[(i+1)%n]
+	segment := distance(from, to)
+	if segment == 0 {
+		return from, 1, 0
+	}
+	f := (d - p.Distances[i]) / segment
+	w := Waypoint{
+		Type: from.Type,
+		X:    from.X + (to.X-from.X)*f,
+		Y:    from.Y + (to.Y-from.Y)*f,
+		Z:    from.Z + (to.Z-from.Z)*f,
+	}
+	return w, (to.X - from.X) / segment, (to.Z - from.Z) / segment
+}
+
+// Corners finds the corners of a closed path, like the racing line, from how
+// much it turns along the lap. Chicanes are returned as one corner for every
+// change of direction.
+func Corners(p Path) []Corner {
+	length := p.Length()
+	n := int(length / resampleStep)
+	if !p.Closed || n <= 2*curvatureWindow {
+		return []Corner{}
+	}
+
+	headings := make([]float64, n)
+	for i := range headings {
+		_, dx, dz := p.At(float64(i) * resampleStep)
+		headings[i] = math.Atan2(dz, dx)
+	}
+	curvatures := make([]float64, n)
+	for i := range curvatures {
+		turn := angleDiff(headings[(i+curvatureWindow)%n], headings[(i-curvatureWindow+n)%n])
+		curvatures[i] = turn / (2 * curvatureWindow * resampleStep)
+	}
+
+	// the corners are the runs of samples turning to the same side, which are
+	// joined when the gap between them is short
+	type run struct{ first, last int }
+	runs := []run{}
+	for i := 0; i < n; i++ {
+		if math.Abs(curvatures[i]) < minCurvature {
+			continue
+		}
+		j := i
+		for j+1 < n && math.Abs(curvatures[j+1]) >= minCurvature && sameSide(curvatures[i], curvatures[j+1]) {
+			j++
+		}
+		if len(runs) > 0 {
+			prev := runs[len(runs)-1]
+			if sameSide(curvatures[prev.last], curvatures[i]) && float64(i-prev.last)*resampleStep <= maxCornerGap {
+				runs[len(runs)-1].last = j
+				i = j
+				continue
+			}
+		}
+		runs = append(runs, run{first: i, last: j})
+		i = j
+	}
+	// the lap wraps around, so a corner may start before the end of the path
+	// and end after its start. Its last run goes on past the end, through
+	// the samples of the first one.
+	if len(runs) > 1 {
+		first, last := runs[0], runs[len(runs)-1]
+		if sameSide(curvatures[last.last], curvatures[first.first]) && float64(first.first+n-last.last)*resampleStep <= maxCornerGap {
+			runs[len(runs)-1].last = first.last + n
+			runs = runs[1:]
+		}
+	}
+
+	corners := []Corner{}
+	for _, r := range runs {
+		angle := 0.0
+		apex := r.first
+		for i := r.first; i <= r.last; i++ {
+			angle += angleDiff(headings[(i+1)%n], headings[i%n])
+			if math.Abs(curvatures[i%n]) > math.Abs(curvatures[apex%n]) {
+				apex = i
+			}
+		}
+		if math.Abs(angle) < minCornerAngle {
+			continue
+		}
+		d := float64(apex%n) * resampleStep
+		w, _, _ := p.At(d)
+		corners = append(corners, Corner{
+			Distance: d,
+			Apex:     w,
+			Angle:    angle,
+		})
+	}
+	// a corner across the start has its apex at either side of it
+	sort.Slice(corners, func(i, j int) bool {
+		return corners[i].Distance < corners[j].Distance
+	})
+	for i := range corners {
+		corners[i].Number = i + 1
+	}
+	return corners
+}
+
+// angleDiff returns the difference between two angles in the range [-π, π].
+func angleDiff(a, b float64) float64 {
+	d := math.Mod(a-b, 2*math.Pi)
+	if d > math.Pi {
+		d -= 2 * math.Pi
+	} else if d < -math.Pi {
+		d += 2 * math.Pi
+	}
+	return d
+}
+
+func sameSide(a, b float64) bool {
+	return (a > 0) == (b > 0)
+}
+
+// distance returns the distance between two waypoints in the XZ plane.
+func distance(a, b Waypoint) float64 {
+	return math.Hypot(b.X-a.X, b.Z-a.Z)
+}
@@ -0,0 +1,89 @@
+package trackmap
+
+import (
+	"math"
+	"testing"
+)
+
+// rectangle returns the waypoints of a closed rectangle with rounded corners,
+// one every metre or so, starting at the given distance from the middle of
+// its first corner.
+func rectangle(width, depth, radius float64, start int) AIW {
+	centers := []Waypoint{{X: width - radius, Z: radius}, {X: width - radius, Z: depth - radius}, {X: radius, Z: depth - radius}, {X: radius, Z: radius}}
+	aiw := AIW{}
+	for i, c := range centers {
+		from := float64(i)*math.Pi/2 - math.Pi/2
+		steps := int(radius * math.Pi / 2)
+		for j := 0; j < steps; j++ {
+			a := from + math.Pi/2*float64(j)/float64(steps)
+			aiw = append(aiw, Waypoint{X: c.X + radius*math.Cos(a), Z: c.Z + radius*math.Sin(a)})
+		}
+		end := Waypoint{X: c.X + radius*math.Cos(from+math.Pi/2), Z: c.Z + radius*math.Sin(from+math.Pi/2)}
+		next := centers[(i+1)%len(centers)]
+		to := Waypoint{X: next.X + radius*math.Cos(from+math.Pi/2), Z: next.Z + radius*math.Sin(from+math.Pi/2)}
+		side := int(distance(end, to))
+		for j := 0; j < side; j++ {
+			f := float64(j) / float64(side)
+			aiw = append(aiw, Waypoint{X: end.X + (to.X-end.X)*f, Z: end.Z + (to.Z-end.Z)*f})
+		}
+	}
+	// the first corner starts the path, so its middle is half an arc later
+	start += int(radius*math.Pi/4) + len(aiw)
+	return append(aiw[start%len(aiw):], aiw[:start%len(aiw)]...)
+}
+
+func circle(radius float64) AIW {
+	aiw := AIW{}
+	n := int(2 * math.Pi * radius)
+	for i := 0; i < n; i++ {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		aiw = append(aiw, Waypoint{X: radius * math.Cos(a), Z: radius * math.Sin(a)})
+	}
+	return aiw
+}
+
+func TestCorners(t *testing.T) {
+	tests := []struct {
+		name      string
+		aiw       AIW
+		want      int
+		wantAngle float64
+	}{
+		{name: "imola", aiw: nil, want: 11},
+		{name: "spa", aiw: nil, want: 13},
+		{name: "rectangle", aiw: rectangle(400, 200, 40, 150), want: 4, wantAngle: math.Pi / 2},
+		{name: "rectangle starting in a corner", aiw: rectangle(400, 200, 40, 0), want: 4, wantAngle: math.Pi / 2},
+		{name: "rectangle starting at the end of a corner", aiw: rectangle(400, 200, 40, 30), want: 4, wantAngle: math.Pi / 2},
+		{name: "rectangle starting before a corner", aiw: rectangle(400, 200, 40, -40), want: 4, wantAngle: math.Pi / 2},
+		{name: "wide circle", aiw: circle(300), want: 0},
+		{name: "tight circle", aiw: circle(100), want: 1, wantAngle: 2 * math.Pi},
+		{name: "open path", aiw: rectangle(400, 200, 40, 150)[:800], want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aiw := tt.aiw
+			if aiw == nil {
+				aiw = loadTrack(t, tt.name).Filter(TypeRacingLine)
+			}
+			p := NewPath(aiw)
+			corners := Corners(p)
+			if len(corners) != tt.want {
+				t.Fatalf("expected %d corners, got %d: %+v", tt.want, len(corners), corners)
+			}
+			for i, c := range corners {
+				if c.Number != i+1 {
+					t.Errorf("expected corner %d numbered %d, got %d", i, i+1, c.Number)
+				}
+				if i > 0 && c.Distance <= corners[i-1].Distance {
+					t.Errorf("corner %d at %.0fm is not after corner %d at %.0fm", c.Number, c.Distance, i, corners[i-1].Distance)
+				}
+				if c.Distance < 0 || c.Distance >= p.Length() {
+					t.Errorf("corner %d at %.0fm is out of the lap of %.0fm", c.Number, c.Distance, p.Length())
+				}
+				if tt.wantAngle != 0 && math.Abs(math.Abs(c.Angle)-tt.wantAngle) > 0.1 {
+					t.Errorf("expected corner %d to turn %.0f degrees, got %.0f", c.Number, tt.wantAngle*180/math.Pi, c.Angle*180/math.Pi)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package trackmap
+
+import (
+	"f1champshotlapsbot/pkg/fonts"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dkit"
+)
+
+const (
+	labelFontSize = 12.0
+	labelPadding  = 4.0
+	// labelGap is the space in pixels between a label and the racing line
+	labelGap = 6.0
+	// pitLabelDistance is how far in metres from the pit entry and exit their
+	// labels are placed, where the pit lane is apart from the racing line
+	pitLabelDistance = 60.0
+
+	// labelTries is how many times a label is pushed away from its place
+	// when it overlaps the labels already drawn
+	labelTries = 4
+
+	labelPitEntry = "PIT IN"
+	labelPitExit  = "PIT OUT"
+)
+
+// labeler draws the labels of a map keeping them apart from each other.
+type labeler struct {
+	gc     draw2d.GraphicContext
+	placed []box
+}
+
+type box struct {
+	x1, y1, x2, y2 float64
+}
+
+func (b box) overlaps(o box) bool {
+	return b.x1 < o.x2 && o.x1 < b.x2 && b.y1 < o.y2 && o.y1 < b.y2
+}
+
+// drawOverlays marks the start/finish line, the sector boundaries, the pit
+// entry and exit and the corner numbers, as set in the options. The marks of
+// a path are not drawn unless the path is drawn too.
+func drawOverlays(gc draw2d.GraphicContext, racingLine, pitLane Path, p Projection, opts Options, included map[int]bool) {
+	hasRacingLine := included[TypeRacingLine] && len(racingLine.Waypoints) >= 2
+	hasPitLane := included[TypePitLane] && len(pitLane.Waypoints) >= 2
+
+	l := &labeler{gc: gc}
+	if hasRacingLine {
+		markLength := 3 * opts.RacingLineWidth
+		if opts.StartFinish {
+			drawMark(gc, racingLine, 0, p, markLength, opts.FinishLineWidth, opts.FinishLineColor)
+		}
+		if opts.Corners {
+			for _, c := range Corners(racingLine) {
+				drawCornerLabel(l, racingLine, c, p, opts)
+			}
+		}
+		for i, d := range opts.SectorBoundaries {
+			x, y, nx, ny := drawMark(gc, racingLine, d, p, markLength, opts.FinishLineWidth, opts.SectorColor)
+			offset := markLength/2 + labelGap + labelFontSize/2 + labelPadding
+			l.draw(fmt.Sprintf("S%d", i+2), x+nx*offset, y+ny*offset, nx, ny, opts.SectorColor, opts.LabelBackground)
+		}
+	}
+	if hasPitLane && opts.PitEntryExit {
+		drawPitLabel(l, pitLane, racingLine, pitLabelDistance, labelPitEntry, p, opts)
+		drawPitLabel(l, pitLane, racingLine, pitLane.Length()-pitLabelDistance, labelPitExit, p, opts)
+	}
+}
+
+// drawMark draws a line across the path at the distance. It returns the centre
+// of the line in the map and the unit vector along the line.
+func drawMark(gc draw2d.GraphicContext, path Path, d float64, p Projection, length, width float64, c color.Color) (float64, float64, float64, float64) {
+	w, _, _ := path.At(d)
+	ahead, _, _ := path.At(d + resampleStep)
+	x, y := p.Point(w.X, w.Z)
+	ax, ay := p.Point(ahead.X, ahead.Z)
+	nx, ny := unit(-(ay - y), ax-x)
+
+	if c != nil && width > 0 {
+		gc.SetStrokeColor(c)
+		gc.SetLineWidth(width)
+		gc.SetLineCap(draw2d.ButtCap)
+		gc.BeginPath()
+		gc.MoveTo(x-nx*length/2, y-ny*length/2)
+		gc.LineTo(x+nx*length/2, y+ny*length/2)
+		gc.Stroke()
+	}
+	return x, y, nx, ny
+}
+
+// drawCornerLabel draws the number of the corner next to its apex, on the
+// outside of the corner.
+func drawCornerLabel(l *labeler, racingLine Path, c Corner, p Projection, opts Options) {
+	before, _, _ := racingLine.At(c.Distance - 4*resampleStep)
+	after, _, _ := racingLine.At(c.Distance + 4*resampleStep)
+	x, y := p.Point(c.Apex.X, c.Apex.Z)
+	bx, by := p.Point(before.X, before.Z)
+	ax, ay := p.Point(after.X, after.Z)
+	// the middle of the points around the apex is inside the corner
+	ix, iy := unit((bx+ax)/2-x, (by+ay)/2-y)
+	offset := opts.RacingLineWidth/2 + labelGap + labelFontSize/2 + labelPadding
+	l.draw(fmt.Sprint(c.Number), x-ix*offset, y-iy*offset, -ix, -iy, opts.LabelColor, opts.LabelBackground)
+}
+
+// drawPitLabel draws the label at the distance along the pit lane, on the
+// side away from the racing line.
+func drawPitLabel(l *labeler, pitLane, racingLine Path, d float64, text string, p Projection, opts Options) {
+	w, _, _ := pitLane.At(d)
+	x, y := p.Point(w.X, w.Z)
+	rx, ry := x, y
+	best := math.Inf(1)
+	for _, r := range racingLine.Waypoints {
+		if dist := distance(w, r); dist < best {
+			best = dist
+			rx, ry = p.Point(r.X, r.Z)
+		}
+	}
+	sx, sy := unit(x-rx, y-ry)
+	offset := opts.PitLaneWidth/2 + labelGap + labelFontSize/2 + labelPadding
+	l.draw(text, x+sx*offset, y+sy*offset, sx, sy, opts.PitLaneColor, opts.LabelBackground)
+}
+
+// draw draws the text centred at the point over a rounded box. The label is
+// pushed along the direction while it overlaps the labels already drawn.
+func (l *labeler) draw(text string, x, y, dx, dy float64, fg, bg color.Color) {
+	if fg == nil {
+		return
+	}
+	gc := l.gc
+	gc.SetFontData(fonts.Bold)
+	gc.SetFontSize(labelFontSize)
+	left, top, right, bottom := gc.GetStringBounds(text)
+	w := math.Max(right-left, bottom-top)
+	h := bottom - top
+
+	var b box
+	for try := 1; ; try++ {
+		b = box{x - w/2 - labelPadding, y - h/2 - labelPadding, x + w/2 + labelPadding, y + h/2 + labelPadding}
+		overlapped := false
+		for _, placed := range l.placed {
+			if b.overlaps(placed) {
+				overlapped = true
+				break
+			}
+		}
+		if !overlapped || try == labelTries || (dx == 0 && dy == 0) {
+			break
+		}
+		x += dx * (h + 2*labelPadding)
+		y += dy * (h + 2*labelPadding)
+	}
+	l.placed = append(l.placed, b)
+
+	if bg != nil {
+		gc.SetFillColor(bg)
+		gc.BeginPath()
+		draw2dkit.RoundedRectangle(gc, b.x1, b.y1, b.x2, b.y2, 2*labelPadding, 2*labelPadding)
+		gc.Fill()
+	}
+	gc.SetFillColor(fg)
+	gc.FillStringAt(text, x-(right-left)/2-left, y+h/2-bottom)
+}
+
+// unit returns the vector with length 1, or a zero vector for a zero vector.
+func unit(x, y float64) (float64, float64) {
+	l := math.Hypot(x, y)
+	if l == 0 {
+		return 0, 0
+	}
+	return x / l, y / l
+}
@@ -0,0 +1,284 @@
+package trackmap
+
+import (
+	"bytes"
+	"errors"
+	"f1champshotlapsbot/pkg/fonts"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+const (
+	profileFontSize = 11.0
+	// minProfileRange is the smallest height range in metres of the chart, so
+	// the flat tracks do not look like hills
+	minProfileRange = 10.0
+	// profileHeightTicks and profileDistanceTicks are about how many grid
+	// lines are drawn along each axis
+	profileHeightTicks   = 5
+	profileDistanceTicks = 8
+
+	// the space in pixels around the plot for the axis and sector labels
+	profileMarginLeft   = 56.0
+	profileMarginRight  = 24.0
+	profileMarginTop    = 40.0
+	profileMarginBottom = 36.0
+)
+
+// ProfileOptions tells how an elevation profile is drawn.
+type ProfileOptions struct {
+	// Width and Height are the size of the chart in pixels
+	Width  float64
+	Height float64
+	// Background fills the chart. It is transparent when nil
+	Background      color.Color
+	LineColor       color.Color
+	FillColor       color.Color
+	GridColor       color.Color
+	TextColor       color.Color
+	SectorColor     color.Color
+	LabelColor      color.Color
+	LabelBackground color.Color
+	LineWidth       float64
+
+	// Corners numbers the corners found along the racing line
+	Corners bool
+	// SectorBoundaries are the distances in metres from the start/finish line
+	// where the sectors 2 and 3 start, as in Options
+	SectorBoundaries []float64
+}
+
+// DefaultProfileOptions returns the options used by the bot, with the colours
+// of the track maps.
+func DefaultProfileOptions() ProfileOptions {
+	return ProfileOptions{
+		Width:           960,
+		Height:          360,
+		Background:      color.RGBA{0xff, 0xff, 0xff, 0xff},
+		LineColor:       color.RGBA{0xe1, 0x06, 0x00, 0xff},
+		FillColor:       color.NRGBA{0xe1, 0x06, 0x00, 0x33},
+		GridColor:       color.RGBA{0xdd, 0xdd, 0xdd, 0xff},
+		TextColor:       color.RGBA{0x15, 0x15, 0x1e, 0xff},
+		SectorColor:     color.RGBA{0xf5, 0xc5, 0x18, 0xff},
+		LabelColor:      color.RGBA{0xff, 0xff, 0xff, 0xff},
+		LabelBackground: color.RGBA{0x15, 0x15, 0x1e, 0xff},
+		LineWidth:       3,
+		Corners:         true,
+	}
+}
+
+func (o ProfileOptions) validate() error {
+	if o.Width <= profileMarginLeft+profileMarginRight || o.Height <= profileMarginTop+profileMarginBottom {
+		return fmt.Errorf("invalid size %fx%f, it is too small for the chart", o.Width, o.Height)
+	}
+	if o.LineWidth < 0 {
+		return errors.New("the line width cannot be negative")
+	}
+	for _, d := range o.SectorBoundaries {
+		if d <= 0 {
+			return fmt.Errorf("invalid sector boundary %f, it must be positive", d)
+		}
+	}
+	return nil
+}
+
+// ProfilePoint is the height of the racing line at a distance from the
+// start/finish line.
+type ProfilePoint struct {
+	Distance float64
+	Height   float64
+}
+
+// Profile returns the height of the racing line every few metres along the
+// lap, from the start/finish line back to it. The heights are measured from
+// the lowest point of the lap.
+func Profile(aiw AIW) ([]ProfilePoint, error) {
+	racingLine := NewPath(aiw.Filter(TypeRacingLine))
+	if len(racingLine.Waypoints) < 2 {
+		return nil, ErrNoWaypoints
+	}
+	length := racingLine.Length()
+	n := int(math.Ceil(length / resampleStep))
+	points := make([]ProfilePoint, 0, n+1)
+	lowest := math.Inf(1)
+	for i := 0; i <= n; i++ {
+		d := math.Min(float64(i)*resampleStep, length)
+		w, _, _ := racingLine.At(d)
+		points = append(points, ProfilePoint{Distance: d, Height: w.Y})
+		lowest = math.Min(lowest, w.Y)
+	}
+	for i := range points {
+		points[i].Height -= lowest
+	}
+	return points, nil
+}
+
+// RenderProfilePNG draws the elevation profile of the racing line as PNG: the
+// height against the distance from the start/finish line.
+func RenderProfilePNG(aiw AIW, opts ProfileOptions) ([]byte, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	points, err := Profile(aiw)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := fonts.Cache()
+	if err != nil {
+		return nil, err
+	}
+	dest := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(opts.Width)), int(math.Ceil(opts.Height))))
+	gc := draw2dimg.NewGraphicContext(dest)
+	gc.FontCache = cache
+	// font sizes are given in pixels
+	gc.SetDPI(72)
+	var corners []Corner
+	if opts.Corners {
+		corners = Corners(NewPath(aiw.Filter(TypeRacingLine)))
+	}
+	drawProfile(gc, points, corners, opts)
+
+	var b bytes.Buffer
+	err = png.Encode(&b, dest)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func drawProfile(gc draw2d.GraphicContext, points []ProfilePoint, corners []Corner, opts ProfileOptions) {
+	if opts.Background != nil {
+		gc.SetFillColor(opts.Background)
+		gc.BeginPath()
+		gc.MoveTo(0, 0)
+		gc.LineTo(opts.Width, 0)
+		gc.LineTo(opts.Width, opts.Height)
+		gc.LineTo(0, opts.Height)
+		gc.Close()
+		gc.Fill()
+	}
+
+	length := points[len(points)-1].Distance
+	highest := minProfileRange
+	for _, p := range points {
+		highest = math.Max(highest, p.Height)
+	}
+	heightStep := niceStep(highest / profileHeightTicks)
+	top := math.Ceil(highest/heightStep) * heightStep
+	distanceStep := niceStep(length / profileDistanceTicks)
+
+	left, right := profileMarginLeft, opts.Width-profileMarginRight
+	upper, lower := profileMarginTop, opts.Height-profileMarginBottom
+	x := func(d float64) float64 { return left + d/length*(right-left) }
+	y := func(h float64) float64 { return lower - h/top*(lower-upper) }
+
+	// the grid with the heights at the left and the distances below
+	gc.SetFontData(fonts.Regular)
+	gc.SetFontSize(profileFontSize)
+	for h := 0.0; h <= top; h += heightStep {
+		drawLine(gc, left, y(h), right, y(h), 1, opts.GridColor)
+		text := fmt.Sprintf("%.0f m", h)
+		_, t, r, b := gc.GetStringBounds(text)
+		drawText(gc, text, left-8-r, y(h)+(b-t)/2-b, opts.TextColor)
+	}
+	for d := 0.0; d <= length; d += distanceStep {
+		drawLine(gc, x(d), upper, x(d), lower, 1, opts.GridColor)
+		text := fmt.Sprintf("%g km", d/1000)
+		l, t, r, _ := gc.GetStringBounds(text)
+		drawText(gc, text, x(d)-(r-l)/2-l, lower+8-t, opts.TextColor)
+	}
+
+	if opts.FillColor != nil {
+		gc.SetFillColor(opts.FillColor)
+		gc.BeginPath()
+		gc.MoveTo(x(0), lower)
+		for _, p := range points {
+			gc.LineTo(x(p.Distance), y(p.Height))
+		}
+		gc.LineTo(x(length), lower)
+		gc.Close()
+		gc.Fill()
+	}
+	if opts.LineColor != nil && opts.LineWidth > 0 {
+		gc.SetStrokeColor(opts.LineColor)
+		gc.SetLineWidth(opts.LineWidth)
+		gc.SetLineCap(draw2d.RoundCap)
+		gc.SetLineJoin(draw2d.RoundJoin)
+		gc.BeginPath()
+		for i, p := range points {
+			if i == 0 {
+				gc.MoveTo(x(p.Distance), y(p.Height))
+			} else {
+				gc.LineTo(x(p.Distance), y(p.Height))
+			}
+		}
+		gc.Stroke()
+	}
+
+	l := &labeler{gc: gc}
+	for i, d := range opts.SectorBoundaries {
+		if d >= length {
+			continue
+		}
+		gc.SetLineDash([]float64{6, 4}, 0)
+		drawLine(gc, x(d), upper, x(d), lower, 2, opts.SectorColor)
+		gc.SetLineDash(nil, 0)
+		l.draw(fmt.Sprintf("S%d", i+2), x(d), upper/2, 0, 0, opts.SectorColor, opts.LabelBackground)
+	}
+	// the corner numbers are placed over the line, pushed up when they are
+	// too close to each other, as in the chicanes
+	for _, c := range corners {
+		i := int(math.Min(math.Round(c.Distance/resampleStep), float64(len(points)-1)))
+		offset := opts.LineWidth/2 + labelGap + labelFontSize/2 + labelPadding
+		l.draw(fmt.Sprint(c.Number), x(c.Distance), y(points[i].Height)-offset, 0, -1, opts.LabelColor, opts.LabelBackground)
+	}
+}
+
+// drawLine strokes a straight line between two points.
+func drawLine(gc draw2d.GraphicContext, x1, y1, x2, y2, width float64, c color.Color) {
+	if c == nil {
+		return
+	}
+	gc.SetStrokeColor(c)
+	gc.SetLineWidth(width)
+	gc.SetLineCap(draw2d.ButtCap)
+	gc.BeginPath()
+	gc.MoveTo(x1, y1)
+	gc.LineTo(x2, y2)
+	gc.Stroke()
+}
+
+// drawText fills the text with its baseline starting at the point.
+func drawText(gc draw2d.GraphicContext, text string, x, y float64, c color.Color) {
+	if c == nil {
+		return
+	}
+	gc.SetFillColor(c)
+	gc.FillStringAt(text, x, y)
+}
+
+// niceStep returns the step of 1, 2 or 5 times a power of 10 closest above
+// the given one, so the grid lines fall on round values.
+func niceStep(step float64) float64 {
+	if step <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(step)))
+	switch f := step / magnitude; {
+	case f <= 1:
+		return magnitude
+	case f <= 2:
+		return 2 * magnitude
+	case f <= 5:
+		return 5 * magnitude
+	default:
+		return 10 * magnitude
+	}
+}
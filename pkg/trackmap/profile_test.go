@@ -0,0 +1,108 @@
+package trackmap
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	for _, track := range testTracks {
+		t.Run(track, func(t *testing.T) {
+			aiw := loadTrack(t, track)
+			points, err := Profile(aiw)
+			if err != nil {
+				t.Fatalf("error computing the profile: %s", err)
+			}
+			if len(points) < 2 {
+				t.Fatalf("expected a point every %.0fm, got %d points", resampleStep, len(points))
+			}
+			if points[0].Distance != 0 {
+				t.Errorf("expected the first point at 0m, got %fm", points[0].Distance)
+			}
+			length := NewPath(aiw.Filter(TypeRacingLine)).Length()
+			if last := points[len(points)-1].Distance; last != length {
+				t.Errorf("expected the last point at the length of the lap %fm, got %fm", length, last)
+			}
+			lowest := math.Inf(1)
+			for i, p := range points {
+				lowest = math.Min(lowest, p.Height)
+				if i > 0 && p.Distance <= points[i-1].Distance {
+					t.Errorf("point %d at %fm is not after the previous one at %fm", i, p.Distance, points[i-1].Distance)
+				}
+			}
+			if lowest != 0 {
+				t.Errorf("expected the heights measured from the lowest point, the lowest is %fm", lowest)
+			}
+		})
+	}
+}
+
+func TestProfileNoRacingLine(t *testing.T) {
+	tests := map[string]AIW{
+		"empty AIW":       {},
+		"pit lane only":   loadTrack(t, "imola").Filter(TypePitLane),
+		"single waypoint": loadTrack(t, "imola").Filter(TypeRacingLine)[:1],
+	}
+	for name, aiw := range tests {
+		t.Run(name, func(t *testing.T) {
+			points, err := Profile(aiw)
+			if !errors.Is(err, ErrNoWaypoints) {
+				t.Errorf("expected %q, got %d points and error %v", ErrNoWaypoints, len(points), err)
+			}
+		})
+	}
+}
+
+func TestRenderProfilePNG(t *testing.T) {
+	for _, track := range testTracks {
+		t.Run(track, func(t *testing.T) {
+			png, err := RenderProfilePNG(loadTrack(t, track), DefaultProfileOptions())
+			if err != nil {
+				t.Fatalf("error rendering the profile: %s", err)
+			}
+			checkGolden(t, track+".profile.png", png)
+		})
+	}
+}
+
+func TestRenderProfileErrors(t *testing.T) {
+	imola := loadTrack(t, "imola")
+	tests := []struct {
+		name    string
+		aiw     AIW
+		opts    func(*ProfileOptions)
+		wantErr error
+	}{
+		{name: "empty AIW", aiw: AIW{}, opts: func(o *ProfileOptions) {}, wantErr: ErrNoWaypoints},
+		{name: "no racing line", aiw: imola.Filter(TypePitLane), opts: func(o *ProfileOptions) {}, wantErr: ErrNoWaypoints},
+		{name: "zero width", aiw: imola, opts: func(o *ProfileOptions) { o.Width = 0 }},
+		{name: "width smaller than the margins", aiw: imola, opts: func(o *ProfileOptions) { o.Width = profileMarginLeft + profileMarginRight }},
+		{name: "height smaller than the margins", aiw: imola, opts: func(o *ProfileOptions) { o.Height = profileMarginTop }},
+		{name: "negative line width", aiw: imola, opts: func(o *ProfileOptions) { o.LineWidth = -1 }},
+		{name: "zero sector boundary", aiw: imola, opts: func(o *ProfileOptions) { o.SectorBoundaries = []float64{1500, 0} }},
+		{name: "negative sector boundary", aiw: imola, opts: func(o *ProfileOptions) { o.SectorBoundaries = []float64{-10} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultProfileOptions()
+			tt.opts(&opts)
+			b, err := RenderProfilePNG(tt.aiw, opts)
+			if err == nil {
+				t.Fatalf("expected an error, got %d bytes", len(b))
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %q, got %q", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRenderProfileSectorBoundaries(t *testing.T) {
+	opts := DefaultProfileOptions()
+	opts.SectorBoundaries = []float64{1500, 3200}
+	_, err := RenderProfilePNG(loadTrack(t, "imola"), opts)
+	if err != nil {
+		t.Fatalf("error rendering the profile with sectors: %s", err)
+	}
+}
@@ -0,0 +1,248 @@
+package trackmap
+
+import (
+	"bytes"
+	"errors"
+	"f1champshotlapsbot/pkg/fonts"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dsvg"
+)
+
+// Options tells how a track map is drawn.
+type Options struct {
+	// Scale is the number of pixels per metre
+	Scale float64
+	// Margin is the space in pixels around the track
+	Margin float64
+	// Landscape rotates the map 90 degrees when the track is taller than wide
+	Landscape bool
+	// Background fills the map. It is transparent when nil
+	Background      color.Color
+	RacingLineColor color.Color
+	PitLaneColor    color.Color
+	FinishLineColor color.Color
+	SectorColor     color.Color
+	LabelColor      color.Color
+	LabelBackground color.Color
+	RacingLineWidth float64
+	PitLaneWidth    float64
+	FinishLineWidth float64
+	// Types are the waypoint types drawn: TypeRacingLine and TypePitLane
+	Types []int
+
+	// StartFinish marks the start/finish line, where the racing line starts
+	StartFinish bool
+	// PitEntryExit marks where the pit lane leaves and joins the racing line
+	PitEntryExit bool
+	// Corners numbers the corners found along the racing line
+	Corners bool
+	// SectorBoundaries are the distances in metres from the start/finish line
+	// where the sectors 2 and 3 start. The AIW data does not have them, so the
+	// sectors are not marked unless they are given.
+	SectorBoundaries []float64
+}
+
+// DefaultOptions returns the options used by the bot: a black racing line
+// with the pit lane in grey, the start/finish line in red and the corners
+// numbered.
+func DefaultOptions() Options {
+	return Options{
+		Scale:           0.5,
+		Margin:          40,
+		Landscape:       true,
+		RacingLineColor: color.RGBA{0x00, 0x00, 0x00, 0xff},
+		PitLaneColor:    color.RGBA{0x88, 0x88, 0x88, 0xff},
+		FinishLineColor: color.RGBA{0xe1, 0x06, 0x00, 0xff},
+		SectorColor:     color.RGBA{0xf5, 0xc5, 0x18, 0xff},
+		LabelColor:      color.RGBA{0xff, 0xff, 0xff, 0xff},
+		LabelBackground: color.RGBA{0x15, 0x15, 0x1e, 0xff},
+		RacingLineWidth: 10,
+		PitLaneWidth:    6,
+		FinishLineWidth: 5,
+		Types:           []int{TypeRacingLine, TypePitLane},
+		StartFinish:     true,
+		PitEntryExit:    true,
+		Corners:         true,
+	}
+}
+
+func (o Options) validate() error {
+	if o.Scale <= 0 {
+		return fmt.Errorf("invalid scale %f, it must be positive", o.Scale)
+	}
+	if o.Margin < 0 {
+		return fmt.Errorf("invalid margin %f, it cannot be negative", o.Margin)
+	}
+	if o.RacingLineWidth < 0 || o.PitLaneWidth < 0 || o.FinishLineWidth < 0 {
+		return errors.New("the line widths cannot be negative")
+	}
+	if len(o.Types) == 0 {
+		return errors.New("no waypoint types to draw")
+	}
+	for _, d := range o.SectorBoundaries {
+		if d <= 0 {
+			return fmt.Errorf("invalid sector boundary %f, it must be positive", d)
+		}
+	}
+	return nil
+}
+
+// Projection converts the coordinates of the track, in metres, to the
+// coordinates of the map, in pixels.
+type Projection struct {
+	Width   float64
+	Height  float64
+	bounds  Bounds
+	scale   float64
+	margin  float64
+	rotated bool
+}
+
+// NewProjection returns the projection that fits the waypoints of the types
+// in the options into a map.
+func NewProjection(aiw AIW, opts Options) (Projection, error) {
+	err := opts.validate()
+	if err != nil {
+		return Projection{}, err
+	}
+	b, err := aiw.Filter(opts.Types...).Bounds()
+	if err != nil {
+		return Projection{}, err
+	}
+	p := Projection{
+		Width:  b.Width()*opts.Scale + 2*opts.Margin,
+		Height: b.Depth()*opts.Scale + 2*opts.Margin,
+		bounds: b,
+		scale:  opts.Scale,
+		margin: opts.Margin,
+	}
+	if opts.Landscape && p.Height > p.Width {
+		p.rotated = true
+		p.Width, p.Height = p.Height, p.Width
+	}
+	return p, nil
+}
+
+// Point returns the position in the map of the X and Z coordinates of the
+// track. The Z axis points up in the map, as seen from above the track.
+func (p Projection) Point(x, z float64) (float64, float64) {
+	u := (x-p.bounds.MinX)*p.scale + p.margin
+	v := (p.bounds.MaxZ-z)*p.scale + p.margin
+	if p.rotated {
+		// the unrotated map is Height pixels wide
+		return p.Width - v, u
+	}
+	return u, v
+}
+
+// RenderSVG draws the track map as SVG.
+func RenderSVG(aiw AIW, opts Options) ([]byte, error) {
+	p, err := NewProjection(aiw, opts)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := fonts.Cache()
+	if err != nil {
+		return nil, err
+	}
+	dest := draw2dsvg.NewSvg()
+	dest.Width = fmt.Sprintf("%d", int(math.Ceil(p.Width)))
+	dest.Height = fmt.Sprintf("%d", int(math.Ceil(p.Height)))
+	// the labels are drawn as paths since the fonts are not installed in
+	// the browsers
+	dest.FontMode = draw2dsvg.PathFontMode
+	gc := draw2dsvg.NewGraphicContext(dest)
+	gc.FontCache = cache
+	draw(gc, aiw, p, opts)
+
+	var b bytes.Buffer
+	err = draw2dsvg.WriteSvg(&b, dest)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// RenderPNG draws the track map as PNG.
+func RenderPNG(aiw AIW, opts Options) ([]byte, error) {
+	p, err := NewProjection(aiw, opts)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := fonts.Cache()
+	if err != nil {
+		return nil, err
+	}
+	dest := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(p.Width)), int(math.Ceil(p.Height))))
+	gc := draw2dimg.NewGraphicContext(dest)
+	gc.FontCache = cache
+	// font sizes are given in pixels
+	gc.SetDPI(72)
+	draw(gc, aiw, p, opts)
+
+	var b bytes.Buffer
+	err = png.Encode(&b, dest)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func draw(gc draw2d.GraphicContext, aiw AIW, p Projection, opts Options) {
+	if opts.Background != nil {
+		gc.SetFillColor(opts.Background)
+		gc.BeginPath()
+		gc.MoveTo(0, 0)
+		gc.LineTo(p.Width, 0)
+		gc.LineTo(p.Width, p.Height)
+		gc.LineTo(0, p.Height)
+		gc.Close()
+		gc.Fill()
+	}
+
+	included := map[int]bool{}
+	for _, t := range opts.Types {
+		included[t] = true
+	}
+	racingLine := NewPath(aiw.Filter(TypeRacingLine))
+	pitLane := NewPath(aiw.Filter(TypePitLane))
+	// the racing line is drawn over the pit lane where they meet
+	if included[TypePitLane] {
+		drawPath(gc, pitLane, p, opts.PitLaneColor, opts.PitLaneWidth)
+	}
+	if included[TypeRacingLine] {
+		drawPath(gc, racingLine, p, opts.RacingLineColor, opts.RacingLineWidth)
+	}
+	drawOverlays(gc, racingLine, pitLane, p, opts, included)
+}
+
+// drawPath strokes the waypoints of the path in order.
+func drawPath(gc draw2d.GraphicContext, path Path, p Projection, c color.Color, width float64) {
+	if len(path.Waypoints) < 2 || c == nil || width == 0 {
+		return
+	}
+	gc.SetStrokeColor(c)
+	gc.SetLineWidth(width)
+	gc.SetLineCap(draw2d.RoundCap)
+	gc.SetLineJoin(draw2d.RoundJoin)
+	gc.BeginPath()
+	for i, w := range path.Waypoints {
+		x, y := p.Point(w.X, w.Z)
+		if i == 0 {
+			gc.MoveTo(x, y)
+		} else {
+			gc.LineTo(x, y)
+		}
+	}
+	if path.Closed {
+		gc.Close()
+	}
+	gc.Stroke()
+}
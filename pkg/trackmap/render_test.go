@@ -0,0 +1,125 @@
+package trackmap
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+var testTracks = []string{"imola", "spa", "barna", "bahrain", "aiw"}
+
+func loadTrack(t *testing.T, track string) AIW {
+	t.Helper()
+	aiw, err := Load(filepath.Join("..", "..", "poc", "track-layout", fmt.Sprintf("track.%s.json", track)))
+	if err != nil {
+		t.Fatalf("error loading track %s: %s", track, err)
+	}
+	return aiw
+}
+
+// checkGolden compares the rendered map with the golden file, or writes it
+// when the tests are run with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		err := os.MkdirAll("testdata", 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(golden, got, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("error reading golden file, run the tests with -update to create it: %s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from %s, run the tests with -update if the change is expected", name, golden)
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	for _, track := range testTracks {
+		t.Run(track, func(t *testing.T) {
+			svg, err := RenderSVG(loadTrack(t, track), DefaultOptions())
+			if err != nil {
+				t.Fatalf("error rendering SVG: %s", err)
+			}
+			checkGolden(t, track+".svg", svg)
+		})
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	for _, track := range testTracks {
+		t.Run(track, func(t *testing.T) {
+			png, err := RenderPNG(loadTrack(t, track), DefaultOptions())
+			if err != nil {
+				t.Fatalf("error rendering PNG: %s", err)
+			}
+			checkGolden(t, track+".png", png)
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	imola := loadTrack(t, "imola")
+	tests := []struct {
+		name    string
+		aiw     AIW
+		opts    func(*Options)
+		wantErr error
+	}{
+		{name: "empty AIW", aiw: AIW{}, opts: func(o *Options) {}, wantErr: ErrNoWaypoints},
+		{name: "types without waypoints", aiw: imola, opts: func(o *Options) { o.Types = []int{99} }, wantErr: ErrNoWaypoints},
+		{name: "no types", aiw: imola, opts: func(o *Options) { o.Types = nil }},
+		{name: "zero scale", aiw: imola, opts: func(o *Options) { o.Scale = 0 }},
+		{name: "negative scale", aiw: imola, opts: func(o *Options) { o.Scale = -0.5 }},
+		{name: "negative margin", aiw: imola, opts: func(o *Options) { o.Margin = -1 }},
+		{name: "negative line width", aiw: imola, opts: func(o *Options) { o.PitLaneWidth = -1 }},
+		{name: "zero sector boundary", aiw: imola, opts: func(o *Options) { o.SectorBoundaries = []float64{1500, 0} }},
+		{name: "negative sector boundary", aiw: imola, opts: func(o *Options) { o.SectorBoundaries = []float64{-10} }},
+	}
+
+	renderers := []struct {
+		name   string
+		render func(AIW, Options) ([]byte, error)
+	}{
+		{"svg", RenderSVG},
+		{"png", RenderPNG},
+	}
+	for _, tt := range tests {
+		for _, r := range renderers {
+			t.Run(tt.name+"/"+r.name, func(t *testing.T) {
+				opts := DefaultOptions()
+				tt.opts(&opts)
+				b, err := r.render(tt.aiw, opts)
+				if err == nil {
+					t.Fatalf("expected an error, got %d bytes", len(b))
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %q, got %q", tt.wantErr, err)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderSectorBoundaries(t *testing.T) {
+	opts := DefaultOptions()
+	opts.SectorBoundaries = []float64{1500, 3200}
+	_, err := RenderPNG(loadTrack(t, "imola"), opts)
+	if err != nil {
+		t.Fatalf("error rendering PNG with sectors: %s", err)
+	}
+}
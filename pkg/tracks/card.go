@@ -0,0 +1,114 @@
+package tracks
+
+import (
+	"f1champshotlapsbot/pkg/cards"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"log"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	inlineKeyboardCard = "card"
+	symbolCard         = "🖼"
+
+	// maxCardRows keeps the card readable on a phone
+	maxCardRows = 30
+)
+
+// SendCardData sends the leaderboard of the category as an image. The text
+// table of times is sent instead if the image cannot be rendered or sent.
+func SendCardData(chatId int64, messageId *int, trackId, categoryId string, tm *Manager, loc *i18n.Localizer) error {
+	track, found := tm.GetTrackByID(trackId)
+	if !found {
+		return tm.RenderTrackNotFound(chatId, loc)
+	}
+	category, found := track.GetCategoryById(categoryId)
+	if !found || len(category.Sessions) == 0 {
+		message := locale.Localize(loc, msgSessionsNotFound)
+		msg := tgbotapi.NewMessage(chatId, message)
+		_, err := tm.bot.Send(msg)
+		return err
+	}
+
+	lb := leaderboardCard(track, category, tm.categoryLaps(trackId, category), loc)
+	data, err := cards.RenderLeaderboard(lb)
+	if err == nil {
+		photo := tgbotapi.NewPhoto(chatId, tgbotapi.FileBytes{
+			Name:  fmt.Sprintf("%s_%s.png", helper.ToID(track.Name), categoryId),
+			Bytes: data,
+		})
+		photo.Caption = fmt.Sprintf(locale.Localize(loc, msgResults), track.Name, category.Name)
+		_, err = tm.bot.Send(photo)
+		if err == nil {
+			return nil
+		}
+	}
+	log.Printf("Error sending leaderboard card of %s/%s: %s", trackId, categoryId, err.Error())
+	return SendSessionData(chatId, messageId, trackId, categoryId, inlineKeyboardTimes, tm, loc)
+}
+
+// leaderboardCard builds the card of the category. The sectors are coloured
+// comparing them with the laps in history: purple for the fastest sector of
+// the category and green for the personal best of the driver.
+func leaderboardCard(track *Track, category Category, history []Session, loc *i18n.Localizer) cards.Leaderboard {
+	drivers := uniqueDrivers(category.Sessions)
+	_, ideal := OptimalLeaderboard(history)
+
+	lb := cards.Leaderboard{
+		Title:    track.Name,
+		Subtitle: fmt.Sprintf(locale.Localize(loc, msgCardSubtitle), category.Name, len(drivers)),
+		Header: cards.Header{
+			Position: locale.Localize(loc, msgHeaderPosition),
+			Driver:   locale.Localize(loc, msgCardHeaderDriver),
+			Class:    locale.Localize(loc, msgCardHeaderClass),
+			Time:     locale.Localize(loc, msgCardHeaderTime),
+			Gap:      locale.Localize(loc, msgCardHeaderGap),
+			Sectors:  locale.Localize(loc, msgCardHeaderSectors),
+			Compound: locale.Localize(loc, msgHeaderTyres),
+		},
+	}
+	if len(drivers) > maxCardRows {
+		lb.Footer = fmt.Sprintf(locale.Localize(loc, msgCardFooter), maxCardRows, len(drivers))
+		drivers = drivers[:maxCardRows]
+	}
+
+	leaderTime := drivers[0].Time
+	for idx, s := range drivers {
+		personal := OptimalLap(s.Driver, history)
+		gap := ""
+		if idx > 0 {
+			gap = helper.SecondsToDiff(s.Time - leaderTime)
+		}
+		lb.Rows = append(lb.Rows, cards.Row{
+			Position: idx + 1,
+			Driver:   s.Driver,
+			CarClass: s.CarClass,
+			Time:     helper.SecondsToMinutes(s.Time),
+			Gap:      gap,
+			Sectors: [3]cards.Sector{
+				cardSector(s.S1, ideal.Sectors.S1, personal.S1),
+				cardSector(s.S2, ideal.Sectors.S2, personal.S2),
+				cardSector(s.S3, ideal.Sectors.S3, personal.S3),
+			},
+			Compound: compound(s, loc),
+		})
+	}
+	return lb
+}
+
+func cardSector(time, fastest, personalBest float64) cards.Sector {
+	switch {
+	case time <= 0:
+		return cards.Sector{Time: time, Color: cards.SectorNone}
+	case time <= fastest:
+		return cards.Sector{Time: time, Color: cards.SectorPurple}
+	case time <= personalBest:
+		return cards.Sector{Time: time, Color: cards.SectorGreen}
+	}
+	return cards.Sector{Time: time, Color: cards.SectorYellow}
+}
@@ -0,0 +1,303 @@
+package tracks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxErrorBody is how much of the body of a failed response is kept in
+	// the error, enough to see what the API answered
+	maxErrorBody = 200
+)
+
+var (
+	// ErrNotFound is returned when the API has nothing for the request.
+	ErrNotFound = errors.New("not found in the API")
+	// ErrServer is returned when the API answers with an error status.
+	ErrServer = errors.New("error in the API")
+	// ErrDecode is returned when the response of the API cannot be read.
+	ErrDecode = errors.New("unexpected response from the API")
+	// ErrUnavailable is returned when the API cannot be reached or does not
+	// answer in time.
+	ErrUnavailable = errors.New("the API is not available")
+)
+
+// APIError is the error of a request to the F1Champs API. Its Kind is one of
+// ErrNotFound, ErrServer, ErrDecode or ErrUnavailable, so it can be checked
+// with errors.Is.
+type APIError struct {
+	Kind   error
+	URL    string
+	Status int
+	Err    error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.URL, e.Kind.Error())
+	if e.Status != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.Status)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// ClientConfig sets how long the requests to the API can take and how they
+// are retried.
+type ClientConfig struct {
+	// Timeout bounds every attempt of a request
+	Timeout time.Duration
+	// Retries is the number of times a request is repeated after a network
+	// error or a 5xx status
+	Retries int
+	// Backoff is the wait before the first retry, which doubles on every
+	// retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// TTL is how long a response is used without asking the API again. Then
+	// it is only downloaded again if it changed.
+	TTL time.Duration
+}
+
+// DefaultClientConfig returns the configuration used by the bot when the
+// values are not set.
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Timeout:    15 * time.Second,
+		Retries:    3,
+		Backoff:    500 * time.Millisecond,
+		MaxBackoff: 8 * time.Second,
+		TTL:        5 * time.Minute,
+	}
+}
+
+// cachedResponse is the last response of the API for a URL, with the
+// validators to ask for it again only if it changed.
+type cachedResponse struct {
+	body         []byte
+	etag         string
+	lastModified string
+	expires      time.Time
+}
+
+// Client requests the tracks and the laps to the F1Champs API. The responses
+// are cached by URL: they are reused until their TTL expires and then
+// requested with If-None-Match and If-Modified-Since, so the API only sends
+// them again when they changed.
+type Client struct {
+	domain     string
+	cfg        ClientConfig
+	httpClient *http.Client
+	cache      map[string]*cachedResponse
+	mu         sync.Mutex
+}
+
+func NewClient(domain string, cfg ClientConfig) *Client {
+	return &Client{
+		domain: domain,
+		cfg:    cfg,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		cache: make(map[string]*cachedResponse),
+	}
+}
+
+// Expire makes the next requests ask the API whether the cached responses
+// changed, without waiting for their TTL.
+func (c *Client) Expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cr := range c.cache {
+		cr.expires = time.Time{}
+	}
+}
+
+// GetTracks returns the tracks with laps recorded. It is false when the
+// tracklist did not change since the last time it was requested.
+func (c *Client) GetTracks(ctx context.Context) ([]*Track, bool, error) {
+	var trackNames []string
+	changed, err := c.getJSON(ctx, c.tracksURL(), &trackNames)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var tracks []*Track
+	for _, trackName := range trackNames {
+		tracks = append(tracks, NewTrack(trackName))
+	}
+	return tracks, changed, nil
+}
+
+// GetSessions returns the laps recorded in a track, the fastest first. It is
+// false when they did not change since the last time they were requested.
+func (c *Client) GetSessions(ctx context.Context, track string) ([]Session, bool, error) {
+	var trackSessions []Session
+	changed, err := c.getJSON(ctx, c.sessionsURL(track), &trackSessions)
+	if err != nil {
+		return nil, false, err
+	}
+
+	sort.Slice(trackSessions, func(i, j int) bool {
+		return trackSessions[i].Time < trackSessions[j].Time
+	})
+	return trackSessions, changed, nil
+}
+
+func (c *Client) tracksURL() string {
+	return fmt.Sprintf("%s/v3/laps?tracklist=tracklist", c.domain)
+}
+
+func (c *Client) sessionsURL(track string) string {
+	return fmt.Sprintf("%s/v3/laps?track=%s", c.domain, url.QueryEscape(track))
+}
+
+// getJSON decodes the response of the URL into v and tells whether it changed
+// since the previous request. The request is retried with an exponential
+// backoff while the API cannot be reached or answers with a 5xx status.
+func (c *Client) getJSON(ctx context.Context, url string, v any) (bool, error) {
+	backoff := c.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		body, changed, err := c.get(ctx, url)
+		if err == nil {
+			err = json.Unmarshal(body, v)
+			if err != nil {
+				c.forget(url)
+				return false, &APIError{Kind: ErrDecode, URL: url, Err: err}
+			}
+			return changed, nil
+		}
+		if !retryable(err) || attempt >= c.cfg.Retries || ctx.Err() != nil {
+			return false, err
+		}
+
+		// the jitter spreads the retries of the requests failing at once
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Printf("Request to %s failed, retrying in %s: %s", url, wait.Round(time.Millisecond), err.Error())
+		select {
+		case <-ctx.Done():
+			return false, err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > c.cfg.MaxBackoff {
+			backoff = c.cfg.MaxBackoff
+		}
+	}
+}
+
+// get returns the body of the URL, from the cache while its TTL lasts.
+func (c *Client) get(ctx context.Context, url string) ([]byte, bool, error) {
+	c.mu.Lock()
+	cached, found := c.cache[url]
+	if found && time.Now().Before(cached.expires) {
+		c.mu.Unlock()
+		return cached.body, false, nil
+	}
+	c.mu.Unlock()
+
+	status, header, body, err := c.fetch(ctx, url, cached)
+	if err == nil && status == http.StatusNotModified {
+		c.mu.Lock()
+		if found && c.cache[url] == cached {
+			cached.expires = time.Now().Add(c.cfg.TTL)
+			c.mu.Unlock()
+			return cached.body, false, nil
+		}
+		c.mu.Unlock()
+		// the cached body was forgotten meanwhile, or the API answered 304 to
+		// a request without validators, so the body is requested once more
+		// without them
+		found = false
+		status, header, body, err = c.fetch(ctx, url, nil)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	switch {
+	case status == http.StatusNotFound:
+		c.forget(url)
+		return nil, false, &APIError{Kind: ErrNotFound, URL: url, Status: status}
+	case status != http.StatusOK:
+		if len(body) > maxErrorBody {
+			body = body[:maxErrorBody]
+		}
+		return nil, false, &APIError{Kind: ErrServer, URL: url, Status: status, Err: fmt.Errorf("%q", body)}
+	}
+
+	// the API may not send the validators, the body tells if it changed then
+	changed := !found || !bytes.Equal(cached.body, body)
+	c.mu.Lock()
+	c.cache[url] = &cachedResponse{
+		body:         body,
+		etag:         header.Get("ETag"),
+		lastModified: header.Get("Last-Modified"),
+		expires:      time.Now().Add(c.cfg.TTL),
+	}
+	c.mu.Unlock()
+	return body, changed, nil
+}
+
+// fetch requests the URL and returns the status, the headers and the body of
+// the response. The validators of the cached response are sent, if any, so
+// the API answers 304 when it did not change.
+func (c *Client) fetch(ctx context.Context, url string, cached *cachedResponse) (int, http.Header, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, nil, &APIError{Kind: ErrUnavailable, URL: url, Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, &APIError{Kind: ErrUnavailable, URL: url, Err: err}
+	}
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// forget removes the cached response of the URL, so it is downloaded again.
+func (c *Client) forget(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cache, url)
+}
+
+func retryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Kind == ErrUnavailable || (apiErr.Kind == ErrServer && apiErr.Status >= http.StatusInternalServerError)
+}
@@ -0,0 +1,323 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+)
+
+func newTestClient(url string) *Client {
+	cfg := DefaultClientConfig()
+	cfg.Retries = 0
+	return NewClient(url, cfg)
+}
+
+func TestGetNotModified(t *testing.T) {
+	var requests, conditional int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`["Imola","Spa"]`))
+	}))
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+	ctx := context.Background()
+
+	ts, changed, err := c.GetTracks(ctx)
+	if err != nil || !changed || len(ts) != 2 {
+		t.Fatalf("first request: got %d tracks, changed %t, error %v", len(ts), changed, err)
+	}
+	c.Expire()
+	ts, changed, err = c.GetTracks(ctx)
+	if err != nil || changed || len(ts) != 2 {
+		t.Fatalf("request not modified: got %d tracks, changed %t, error %v", len(ts), changed, err)
+	}
+	if requests != 2 || conditional != 1 {
+		t.Errorf("expected 2 requests, 1 conditional, got %d and %d", requests, conditional)
+	}
+}
+
+func TestGetNotModifiedWithoutCache(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// a 304 with no validators in the request, as a broken proxy may
+			// answer
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			t.Errorf("the retry has validators: %v", r.Header)
+		}
+		_, _ = w.Write([]byte(`["Imola"]`))
+	}))
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	ts, changed, err := c.GetTracks(context.Background())
+	if err != nil || !changed || len(ts) != 1 {
+		t.Fatalf("got %d tracks, changed %t, error %v", len(ts), changed, err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestGetNotModifiedAfterForget(t *testing.T) {
+	var c *Client
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(`["Imola"]`))
+		case 2:
+			// the cached response is dropped while it is validated
+			c.forget(c.tracksURL())
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			_, _ = w.Write([]byte(`["Imola","Spa"]`))
+		}
+	}))
+	defer srv.Close()
+	c = newTestClient(srv.URL)
+	ctx := context.Background()
+
+	_, _, err := c.GetTracks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Expire()
+	ts, changed, err := c.GetTracks(ctx)
+	if err != nil || !changed || len(ts) != 2 {
+		t.Fatalf("got %d tracks, changed %t, error %v", len(ts), changed, err)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestGetNotModifiedTwice(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	_, _, err := c.GetTracks(context.Background())
+	var apiErr *APIError
+	if !errors.Is(err, ErrServer) || !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotModified {
+		t.Fatalf("expected a server error with status 304, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+// newRetryClient returns a client that retries twice without waiting long.
+func newRetryClient(url string) *Client {
+	cfg := DefaultClientConfig()
+	cfg.Retries = 2
+	cfg.Backoff = time.Millisecond
+	cfg.MaxBackoff = 2 * time.Millisecond
+	return NewClient(url, cfg)
+}
+
+func TestGetErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		handler      func(w http.ResponseWriter, n int32)
+		wantKind     error
+		wantRequests int32
+	}{
+		{
+			name: "retries the 5xx until the maximum attempts",
+			handler: func(w http.ResponseWriter, n int32) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantKind:     ErrServer,
+			wantRequests: 3,
+		},
+		{
+			name: "retries the network errors until the maximum attempts",
+			handler: func(w http.ResponseWriter, n int32) {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+			},
+			wantKind:     ErrUnavailable,
+			wantRequests: 3,
+		},
+		{
+			name: "stops retrying once the API answers",
+			handler: func(w http.ResponseWriter, n int32) {
+				if n < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				_, _ = w.Write([]byte(`["Imola"]`))
+			},
+			wantRequests: 3,
+		},
+		{
+			name: "does not retry a 404",
+			handler: func(w http.ResponseWriter, n int32) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantKind:     ErrNotFound,
+			wantRequests: 1,
+		},
+		{
+			name: "does not retry a 4xx",
+			handler: func(w http.ResponseWriter, n int32) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantKind:     ErrServer,
+			wantRequests: 1,
+		},
+		{
+			name: "reports an HTML 500 as a server error",
+			handler: func(w http.ResponseWriter, n int32) {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte("<html><body>" + strings.Repeat("Internal Server Error ", 50) + "</body></html>"))
+			},
+			wantKind:     ErrServer,
+			wantRequests: 3,
+		},
+		{
+			name: "reports malformed JSON as a decode error",
+			handler: func(w http.ResponseWriter, n int32) {
+				_, _ = w.Write([]byte(`["Imola",`))
+			},
+			wantKind:     ErrDecode,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.handler(w, atomic.AddInt32(&requests, 1))
+			}))
+			defer srv.Close()
+			c := newRetryClient(srv.URL)
+
+			_, _, err := c.GetTracks(context.Background())
+			if tt.wantKind == nil && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.wantKind != nil && !errors.Is(err, tt.wantKind) {
+				t.Fatalf("expected %q, got %v", tt.wantKind, err)
+			}
+			if err != nil && len(err.Error()) > len(c.tracksURL())+maxErrorBody+100 {
+				t.Errorf("the error keeps the whole body: %s", err)
+			}
+			if got := atomic.LoadInt32(&requests); got != tt.wantRequests {
+				t.Errorf("expected %d requests, got %d", tt.wantRequests, got)
+			}
+		})
+	}
+}
+
+func TestGetTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	cfg := DefaultClientConfig()
+	cfg.Retries = 0
+	cfg.Timeout = 50 * time.Millisecond
+	c := NewClient(srv.URL, cfg)
+
+	start := time.Now()
+	_, _, err := c.GetTracks(context.Background())
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected %q, got %v", ErrUnavailable, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the request to stop after %s, it took %s", cfg.Timeout, elapsed)
+	}
+}
+
+// newTestBot returns a bot sending its messages to a fake Telegram API, which
+// keeps their texts.
+func newTestBot(t *testing.T) (*tgbotapi.BotAPI, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	texts := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Bot","username":"bot"}}`))
+			return
+		}
+		mu.Lock()
+		texts = append(texts, r.FormValue("text"))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}`))
+	}))
+	t.Cleanup(srv.Close)
+	bot, err := tgbotapi.NewBotAPIWithClient("token", srv.URL+"/bot%s/%s", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, texts...)
+	}
+}
+
+func TestRenderAPIError(t *testing.T) {
+	bot, sent := newTestBot(t)
+	tm := NewTrackManager(bot, newTestClient("http://localhost"), newFakeStore(), nil)
+	loc := i18n.NewLocalizer(i18n.NewBundle(language.English), "en")
+
+	messages := map[string]bool{}
+	for _, kind := range []error{ErrNotFound, ErrServer, ErrDecode, ErrUnavailable} {
+		err := tm.renderAPIError(1, loc, fmt.Errorf("getting tracks: %w", &APIError{Kind: kind, URL: "http://localhost"}))
+		if err != nil {
+			t.Fatalf("error rendering %q: %s", kind, err)
+		}
+		texts := sent()
+		if len(texts) != len(messages)+1 {
+			t.Fatalf("expected a message for %q, got %v", kind, texts)
+		}
+		text := texts[len(texts)-1]
+		if messages[text] {
+			t.Errorf("the message for %q is used by another kind: %s", kind, text)
+		}
+		messages[text] = true
+	}
+
+	other := errors.New("not from the API")
+	err := tm.renderAPIError(1, loc, other)
+	if err != other {
+		t.Errorf("expected the error not from the API returned, got %v", err)
+	}
+	if len(sent()) != len(messages) {
+		t.Errorf("expected no message for the error not from the API, got %v", sent())
+	}
+}
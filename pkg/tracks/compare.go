@@ -0,0 +1,146 @@
+package tracks
+
+import (
+	"bytes"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	inlineKeyboardCompare = "compare"
+	symbolCompare         = "⚔️"
+	symbolBack            = "↩️"
+
+	driversPerRow = 4
+)
+
+// SendCompareData walks the user through the head to head comparison. With no
+// picks it asks for the first driver, with one pick for the second one and
+// with both picks it renders the comparison. Picks are positions in the
+// category leaderboard so the callback data fits in Telegram limits.
+func SendCompareData(chatId int64, messageId *int, trackId, categoryId string, tm *Manager, loc *i18n.Localizer, picks ...string) error {
+	track, found := tm.GetTrackByID(trackId)
+	if !found {
+		return tm.RenderTrackNotFound(chatId, loc)
+	}
+	category, found := track.GetCategoryById(categoryId)
+	if !found || len(category.Sessions) == 0 {
+		message := locale.Localize(loc, msgSessionsNotFound)
+		msg := tgbotapi.NewMessage(chatId, message)
+		_, err := tm.bot.Send(msg)
+		return err
+	}
+
+	drivers := uniqueDrivers(category.Sessions)
+	idxs := []int{}
+	for _, pick := range picks {
+		idx, err := strconv.Atoi(pick)
+		if err != nil || idx < 0 || idx >= len(drivers) {
+			// the leaderboard changed since the picker was shown
+			return SendCompareData(chatId, messageId, trackId, categoryId, tm, loc)
+		}
+		idxs = append(idxs, idx)
+	}
+
+	var text string
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	switch len(idxs) {
+	case 0:
+		text = fmt.Sprintf(locale.Localize(loc, msgCompareChooseFirst), track.Name, category.Name)
+		keyboard = getInlineKeyboardDriverPicker(trackId, categoryId, drivers, picks)
+	case 1:
+		text = fmt.Sprintf(locale.Localize(loc, msgCompareChooseSecond), drivers[idxs[0]].Driver)
+		keyboard = getInlineKeyboardDriverPicker(trackId, categoryId, drivers, picks)
+	default:
+		a := drivers[idxs[0]]
+		b := drivers[idxs[1]]
+		text = fmt.Sprintf("```\n"+locale.Localize(loc, msgCompareTitle)+"\n\n%s```", a.Driver, b.Driver, track.Name, category.Name,
+			renderCompareTable(a, b, idxs[0]+1, idxs[1]+1, tm.categoryLaps(trackId, category), loc))
+		keyboard = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				inlineKeyboardButton(loc, inlineKeyboardCompare, symbolCompare, trackId, categoryId),
+				inlineKeyboardButton(loc, inlineKeyboardTimes, symbolTimes, trackId, categoryId),
+			),
+		)
+	}
+
+	parseMode := ""
+	if len(idxs) > 1 {
+		parseMode = tgbotapi.ModeMarkdownV2
+	}
+	return tm.sendOrEdit(chatId, messageId, text, parseMode, keyboard)
+}
+
+func getInlineKeyboardDriverPicker(trackId, categoryId string, drivers []Session, picks []string) tgbotapi.InlineKeyboardMarkup {
+	prefix := fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardCompare, trackId, categoryId)
+	if len(picks) > 0 {
+		prefix += ":" + strings.Join(picks, ":")
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for idx, driver := range drivers {
+		if len(picks) > 0 && picks[0] == strconv.Itoa(idx) {
+			continue
+		}
+		if len(rows) == 0 || len(rows[len(rows)-1]) == driversPerRow {
+			rows = append(rows, []tgbotapi.InlineKeyboardButton{})
+		}
+		label := fmt.Sprintf("%d. %s", idx+1, helper.GetDriverCodeName(driver.Driver))
+		rows[len(rows)-1] = append(rows[len(rows)-1], tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%s:%d", prefix, idx)))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(symbolBack, fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardTimes, trackId, categoryId)),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func renderCompareTable(a, b Session, posA, posB int, ss []Session, loc *i18n.Localizer) string {
+	var buf bytes.Buffer
+	t := table.NewWriter()
+	t.SetOutputMirror(&buf)
+	style := table.StyleRounded
+	style.Options.DrawBorder = false
+	t.SetStyle(style)
+
+	t.AppendHeader(table.Row{"", helper.GetDriverCodeName(a.Driver), helper.GetDriverCodeName(b.Driver), "Δ"})
+	t.AppendRows([]table.Row{
+		{locale.Localize(loc, msgHeaderPosition), fmt.Sprintf("P%d", posA), fmt.Sprintf("P%d", posB), ""},
+		{locale.Localize(loc, msgHeaderLap), helper.SecondsToMinutes(a.Time), helper.SecondsToMinutes(b.Time), signedDiff(a.Time, b.Time)},
+		{"S1", helper.ToSectorTime(a.S1), helper.ToSectorTime(b.S1), signedDiff(a.S1, b.S1)},
+		{"S2", helper.ToSectorTime(a.S2), helper.ToSectorTime(b.S2), signedDiff(a.S2, b.S2)},
+		{"S3", helper.ToSectorTime(a.S3), helper.ToSectorTime(b.S3), signedDiff(a.S3, b.S3)},
+	})
+	optimalA := OptimalLap(a.Driver, ss)
+	optimalB := OptimalLap(b.Driver, ss)
+	t.AppendSeparator()
+	t.AppendRows([]table.Row{
+		{strings.ToUpper(locale.Localize(loc, msgHeaderOptimal)), helper.SecondsToMinutes(optimalA.Time()), helper.SecondsToMinutes(optimalB.Time()), signedDiff(optimalA.Time(), optimalB.Time())},
+		{locale.Localize(loc, msgHeaderTyres), compound(a, loc), compound(b, loc), ""},
+		{locale.Localize(loc, msgHeaderFl), fmt.Sprintf("%.1f", a.Fl), fmt.Sprintf("%.1f", b.Fl), ""},
+		{locale.Localize(loc, msgHeaderFr), fmt.Sprintf("%.1f", a.Fr), fmt.Sprintf("%.1f", b.Fr), ""},
+		{locale.Localize(loc, msgHeaderRl), fmt.Sprintf("%.1f", a.Rl), fmt.Sprintf("%.1f", b.Rl), ""},
+		{locale.Localize(loc, msgHeaderRr), fmt.Sprintf("%.1f", a.Rr), fmt.Sprintf("%.1f", b.Rr), ""},
+		{locale.Localize(loc, msgHeaderFuel), fmt.Sprintf("%.1f", a.Fuel), fmt.Sprintf("%.1f", b.Fuel), ""},
+	})
+	t.Render()
+
+	return buf.String() + fmt.Sprintf("\n%s: %s\n%s: %s\n", helper.GetDriverCodeName(a.Driver), a.DateTime, helper.GetDriverCodeName(b.Driver), b.DateTime)
+}
+
+// compound returns the front compound name of a session.
+func compound(s Session, loc *i18n.Localizer) string {
+	tyreSlice := strings.Split(s.Fcompound, ",")
+	tyre := locale.Localize(loc, msgUnknown)
+	if len(tyreSlice) > 0 {
+		tyre = tyreSlice[len(tyreSlice)-1]
+	}
+	return tyre
+}
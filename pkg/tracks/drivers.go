@@ -0,0 +1,130 @@
+package tracks
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+)
+
+// DriverBest is the best lap of a driver for a track and category.
+type DriverBest struct {
+	Track    *Track
+	Category Category
+	Session  Session
+	Position int
+	Gap      float64
+}
+
+// GetDriverBests returns the best lap of the driver in every track and
+// category, sorted by track and category name.
+func (tm *Manager) GetDriverBests(ctx context.Context, driver string) ([]DriverBest, error) {
+	ts, err := tm.GetTracks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bests := []DriverBest{}
+	for _, t := range ts {
+		cats, err := t.GetCategories(ctx, tm.api)
+		if err != nil {
+			log.Printf("Error getting categories for %s: %s", t.Name, err.Error())
+			continue
+		}
+		for _, cat := range cats {
+			if best, found := driverBestInCategory(driver, cat); found {
+				best.Track = t
+				bests = append(bests, best)
+			}
+		}
+	}
+
+	sort.SliceStable(bests, func(i, j int) bool {
+		if bests[i].Track.Name == bests[j].Track.Name {
+			return bests[i].Category.Name < bests[j].Category.Name
+		}
+		return bests[i].Track.Name < bests[j].Track.Name
+	})
+	return bests, nil
+}
+
+// FindDrivers returns the driver names matching the given name. An exact
+// (case insensitive) match wins over partial matches.
+func (tm *Manager) FindDrivers(ctx context.Context, name string) ([]string, error) {
+	ts, err := tm.GetTracks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	partial := map[string]bool{}
+	for _, t := range ts {
+		cats, err := t.GetCategories(ctx, tm.api)
+		if err != nil {
+			log.Printf("Error getting categories for %s: %s", t.Name, err.Error())
+			continue
+		}
+		for _, cat := range cats {
+			for _, s := range cat.Sessions {
+				driver := strings.ToLower(s.Driver)
+				if driver == name {
+					return []string{s.Driver}, nil
+				}
+				if strings.Contains(driver, name) {
+					partial[s.Driver] = true
+				}
+			}
+		}
+	}
+
+	drivers := make([]string, 0, len(partial))
+	for d := range partial {
+		drivers = append(drivers, d)
+	}
+	sort.Strings(drivers)
+	return drivers, nil
+}
+
+// FindDriverByID returns the driver name whose ID (see helper.ToID) matches.
+func (tm *Manager) FindDriverByID(ctx context.Context, driverId string) (string, bool) {
+	ts, err := tm.GetTracks(ctx)
+	if err != nil {
+		return "", false
+	}
+	for _, t := range ts {
+		cats, err := t.GetCategories(ctx, tm.api)
+		if err != nil {
+			continue
+		}
+		for _, cat := range cats {
+			for _, s := range cat.Sessions {
+				if helper.ToID(s.Driver) == driverId {
+					return s.Driver, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// driverBestInCategory relies on category sessions being sorted by time.
+func driverBestInCategory(driver string, cat Category) (DriverBest, bool) {
+	if len(cat.Sessions) == 0 {
+		return DriverBest{}, false
+	}
+	ahead := map[string]bool{}
+	for _, s := range cat.Sessions {
+		if s.Driver == driver {
+			return DriverBest{
+				Category: cat,
+				Session:  s,
+				Position: len(ahead) + 1,
+				Gap:      s.Time - cat.Sessions[0].Time,
+			}, true
+		}
+		ahead[s.Driver] = true
+	}
+	return DriverBest{}, false
+}
@@ -0,0 +1,134 @@
+package tracks
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	inlineKeyboardExport = "export"
+	symbolExport         = "📄"
+
+	exportCSV      = "csv"
+	exportJSON     = "json"
+	exportMarkdown = "md"
+)
+
+var (
+	exportFormats = []string{exportCSV, exportJSON, exportMarkdown}
+	exportHeader  = []string{
+		"position", "driver", "time", "s1", "s2", "s3", "fcompound", "rcompound", "fl", "fr", "rl", "rr",
+		"fuel", "lapcountcomplete", "lapcount", "date", "carClass", "carType", "team", "trackCourse",
+	}
+)
+
+// SendExportData asks for the format of the export and, once picked, sends
+// the whole leaderboard of the category as a document.
+func SendExportData(chatId int64, messageId *int, trackId, categoryId string, tm *Manager, loc *i18n.Localizer, format ...string) error {
+	track, found := tm.GetTrackByID(trackId)
+	if !found {
+		return tm.RenderTrackNotFound(chatId, loc)
+	}
+	category, found := track.GetCategoryById(categoryId)
+	if !found || len(category.Sessions) == 0 {
+		message := locale.Localize(loc, msgSessionsNotFound)
+		msg := tgbotapi.NewMessage(chatId, message)
+		_, err := tm.bot.Send(msg)
+		return err
+	}
+
+	if len(format) == 0 {
+		text := fmt.Sprintf(locale.Localize(loc, msgExportChoose), track.Name, category.Name)
+		return tm.sendOrEdit(chatId, messageId, text, "", getInlineKeyboardExportFormats(trackId, categoryId, loc))
+	}
+
+	data, err := exportSessions(format[0], category.Sessions)
+	if err != nil {
+		return err
+	}
+	doc := tgbotapi.NewDocument(chatId, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("%s_%s.%s", helper.ToID(track.Name), categoryId, format[0]),
+		Bytes: data,
+	})
+	doc.Caption = fmt.Sprintf(locale.Localize(loc, msgResults), track.Name, category.Name)
+	_, err = tm.bot.Send(doc)
+	return err
+}
+
+// exportSessions renders the sessions, already sorted by time, in the format.
+func exportSessions(format string, ss []Session) ([]byte, error) {
+	switch format {
+	case exportCSV:
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		err := w.Write(exportHeader)
+		if err != nil {
+			return nil, err
+		}
+		for idx, s := range ss {
+			err = w.Write(exportRow(idx+1, s, formatSeconds))
+			if err != nil {
+				return nil, err
+			}
+		}
+		w.Flush()
+		return b.Bytes(), w.Error()
+	case exportJSON:
+		return json.MarshalIndent(ss, "", "  ")
+	case exportMarkdown:
+		var b bytes.Buffer
+		b.WriteString("| " + strings.Join(exportHeader, " | ") + " |\n")
+		b.WriteString(strings.Repeat("|---", len(exportHeader)) + "|\n")
+		for idx, s := range ss {
+			row := exportRow(idx+1, s, helper.SecondsToMinutes)
+			for i := range row {
+				row[i] = strings.ReplaceAll(row[i], "|", "\\|")
+			}
+			b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		}
+		return b.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// exportRow returns the values of a session in the order of exportHeader. The
+// lap time is rendered with lapTime.
+func exportRow(position int, s Session, lapTime func(float64) string) []string {
+	return []string{
+		strconv.Itoa(position), s.Driver, lapTime(s.Time), formatSeconds(s.S1), formatSeconds(s.S2), formatSeconds(s.S3),
+		s.Fcompound, s.Rcompound, formatFloat(s.Fl), formatFloat(s.Fr), formatFloat(s.Rl), formatFloat(s.Rr),
+		formatFloat(s.Fuel), strconv.Itoa(s.Lapcountcomplete), strconv.Itoa(s.Lapcount), s.DateTime,
+		s.CarClass, s.CarType, s.Team, s.TrackCourse,
+	}
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func getInlineKeyboardExportFormats(trackId, categoryId string, loc *i18n.Localizer) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, format := range exportFormats {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(strings.ToUpper(format), fmt.Sprintf("%s:%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardExport, trackId, categoryId, format)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(
+			inlineKeyboardButton(loc, inlineKeyboardTimes, symbolBack, trackId, categoryId),
+		),
+	)
+}
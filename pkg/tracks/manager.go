@@ -2,128 +2,288 @@ package tracks
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
+	"f1champshotlapsbot/pkg/locale"
 	"log"
-	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
-
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 const (
 	tracksPerPage = 10
+
+	// RefreshInterval is how often the tracks are synced. The responses of
+	// the API are cached for the TTL of the client, so most syncs do not
+	// make any request and the rest only download what changed.
+	RefreshInterval = time.Minute
 )
 
+// Storer persists the tracks and their sessions so they are served from disk
+// after a restart and kept when the API is down.
+type Storer interface {
+	SaveTracks(ts []*Track) error
+	ListTracks() ([]*Track, error)
+	SaveSessions(trackId string, ss []Session) error
+	ListSessions(trackId string) ([]Session, error)
+	ListSessionHistory(trackId string) ([]Session, error)
+	ToggleSubscription(chatId int64, trackId, categoryId string) (bool, error)
+	IsSubscribed(chatId int64, trackId, categoryId string) (bool, error)
+	ListSubscribedChats(trackId, categoryId string) ([]int64, error)
+}
+
+// snapshot is the list of tracks served to the users. It is never modified
+// once stored in the manager, a sync stores a new one instead, so it can be
+// read without locks while the tracks are synced.
+type snapshot struct {
+	tracks []*Track
+	byID   map[string]*Track
+}
+
+func newSnapshot(ts []*Track) *snapshot {
+	s := &snapshot{
+		tracks: ts,
+		byID:   make(map[string]*Track, len(ts)),
+	}
+	for _, t := range ts {
+		s.byID[t.ID] = t
+	}
+	return s
+}
+
+// Manager serves the tracks from the last snapshot of the store and syncs
+// them with the API in the background. The categories of every track are
+// loaded on first access and guarded by the track itself.
 type Manager struct {
-	tracks    []*Track
-	mu        sync.Mutex
-	apiDomain string
+	snapshot  atomic.Pointer[snapshot]
+	api       *Client
 	bot       *tgbotapi.BotAPI
+	store     Storer
+	locale    *locale.Manager
+	refreshMu sync.Mutex
+
+	// notifyMu sends the notifications of one refresh at a time, so they
+	// are paced together
+	notifyMu sync.Mutex
+
+	prefetchMu     sync.Mutex
+	prefetching    *PrefetchStatus
+	prefetchCancel context.CancelFunc
+
+	// recent is the activity of the tracks of recentOf, computed once for
+	// every snapshot
+	recentMu sync.Mutex
+	recent   []Activity
+	recentOf *snapshot
 }
 
-func NewTrackManager(bot *tgbotapi.BotAPI, domain string) *Manager {
-	return &Manager{
-		apiDomain: domain,
-		bot:       bot,
+func NewTrackManager(bot *tgbotapi.BotAPI, api *Client, store Storer, lm *locale.Manager) *Manager {
+	tm := &Manager{
+		api:    api,
+		bot:    bot,
+		store:  store,
+		locale: lm,
+	}
+	tm.snapshot.Store(newSnapshot(nil))
+	err := tm.load()
+	if err != nil {
+		log.Printf("Error loading stored tracks: %s", err.Error())
 	}
+	return tm
 }
 
 func (tm *Manager) Sync(ctx context.Context, ticker *time.Ticker, exitChan chan bool) {
 	go func() {
+		_ = tm.refresh(ctx)
 		for {
 			select {
 			case <-exitChan:
+				tm.stopPrefetch()
 				return
 			case t := <-ticker.C:
-				log.Println("Resetting tracks and sessions at: ", t)
-				tm.mu.Lock()
-				tm.tracks = []*Track{}
-				tm.mu.Unlock()
+				log.Println("Syncing tracks and sessions at: ", t)
+				_ = tm.refresh(ctx)
 			}
 		}
 	}()
 }
 
-func (tm *Manager) Lock() {
-	tm.mu.Lock()
+// Refresh syncs the tracks and sessions right away, out of the ticker, asking
+// the API for the ones whose TTL has not expired yet too.
+func (tm *Manager) Refresh(ctx context.Context) error {
+	tm.api.Expire()
+	return tm.refresh(ctx)
 }
 
-func (tm *Manager) Unlock() {
-	tm.mu.Unlock()
+// refresh syncs the tracks and sessions and then notifies the improvements
+// found in the background, so the next refresh does not wait for them.
+func (tm *Manager) refresh(ctx context.Context) error {
+	improvements, err := tm.update(ctx)
+	if len(improvements) > 0 {
+		go tm.notifyImprovements(improvements)
+	}
+	return err
 }
 
-func (tm *Manager) GetTracks(ctx context.Context) ([]*Track, error) {
-	if len(tm.tracks) == 0 {
-		// if there is no tracks, fetch them
-		ts, err := getTracks(ctx, tm.apiDomain)
+// update downloads the tracklist and the sessions of every track into the
+// store and returns the improvements in the sessions stored. Only the tracks
+// whose sessions changed are stored again and reloaded. Tracks that cannot be
+// fetched keep their previously stored sessions. Only one update runs at a
+// time.
+func (tm *Manager) update(ctx context.Context) ([]Improvement, error) {
+	tm.refreshMu.Lock()
+	defer tm.refreshMu.Unlock()
+
+	ts, tracksChanged, err := tm.api.GetTracks(ctx)
+	if err != nil {
+		log.Printf("Error fetching tracks, keeping stored ones: %s", err.Error())
+		return nil, err
+	}
+	if tracksChanged {
+		err = tm.store.SaveTracks(ts)
 		if err != nil {
-			return ts, err
+			log.Printf("Error storing tracks: %s", err.Error())
+			// download them again on next refresh, they are not stored
+			tm.api.forget(tm.api.tracksURL())
+			return nil, err
 		}
-		tm.tracks = ts
+	}
+	improvements := []Improvement{}
+	changed := []string{}
+	for _, t := range ts {
+		ss, sessionsChanged, err := tm.api.GetSessions(ctx, t.Name)
+		if err != nil {
+			log.Printf("Error fetching sessions for %s, keeping stored ones: %s", t.Name, err.Error())
+			continue
+		}
+		if !sessionsChanged {
+			continue
+		}
+		old, err := tm.store.ListSessions(t.ID)
+		if err != nil {
+			log.Printf("Error reading stored sessions for %s: %s", t.Name, err.Error())
+			tm.api.forget(tm.api.sessionsURL(t.Name))
+			continue
+		}
+		err = tm.store.SaveSessions(t.ID, ss)
+		if err != nil {
+			log.Printf("Error storing sessions for %s: %s", t.Name, err.Error())
+			tm.api.forget(tm.api.sessionsURL(t.Name))
+			continue
+		}
+		improvements = append(improvements, diffSessions(t, old, ss)...)
+		changed = append(changed, t.ID)
 	}
 
-	return tm.tracks, nil
+	if tracksChanged {
+		err = tm.load()
+		if err != nil {
+			log.Printf("Error loading stored tracks: %s", err.Error())
+			return improvements, err
+		}
+	} else {
+		tm.reset(changed)
+	}
+	if tracksChanged || len(changed) > 0 {
+		tm.prefetch(ctx)
+	}
+	return improvements, nil
 }
 
-func (tm *Manager) GetTrackByID(id string) (*Track, bool) {
-	for _, track := range tm.tracks {
-		if track.ID == id {
-			return track, true
+// reset drops the categories of the tracks so they are read again from the
+// store on next access. The rest of the tracks keep theirs. The tracks are
+// stored in a new snapshot, so what is computed from them is computed again.
+func (tm *Manager) reset(trackIds []string) {
+	if len(trackIds) == 0 {
+		return
+	}
+	s := tm.snapshot.Load()
+	for _, id := range trackIds {
+		if t, found := s.byID[id]; found {
+			t.resetCategories()
 		}
 	}
-	return &Track{}, false
+	tm.snapshot.Store(newSnapshot(s.tracks))
 }
 
-func (tm *Manager) GetRange(from, to int) []*Track {
-	return tm.tracks[from:to]
+// load replaces the in-memory tracks with the stored ones. Categories are
+// read lazily from the store on first access.
+func (tm *Manager) load() error {
+	ts, err := tm.store.ListTracks()
+	if err != nil {
+		return err
+	}
+	for _, t := range ts {
+		t.store = tm.store
+	}
+	tm.snapshot.Store(newSnapshot(ts))
+	return nil
 }
 
-func getTracks(ctx context.Context, domain string) ([]*Track, error) {
-	// Make a get request
-	url := fmt.Sprintf("%s/v3/laps?tracklist=tracklist", domain)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
+// GetTracks returns the tracks, fetching them from the API if none was synced
+// yet. The slice is shared and must not be modified.
+func (tm *Manager) GetTracks(ctx context.Context) ([]*Track, error) {
+	if s := tm.snapshot.Load(); len(s.tracks) > 0 {
+		return s.tracks, nil
 	}
 
-	// Do the request
-	resp, err := http.DefaultClient.Do(req)
+	// if there is no tracks, fetch them, waiting for the sync running
+	tm.refreshMu.Lock()
+	defer tm.refreshMu.Unlock()
+	if s := tm.snapshot.Load(); len(s.tracks) > 0 {
+		return s.tracks, nil
+	}
+	ts, _, err := tm.api.GetTracks(ctx)
 	if err != nil {
-		return nil, err
+		return ts, err
+	}
+	err = tm.store.SaveTracks(ts)
+	if err != nil {
+		log.Printf("Error storing tracks: %s", err.Error())
+	}
+	for _, t := range ts {
+		t.store = tm.store
 	}
+	tm.snapshot.Store(newSnapshot(ts))
+	tm.prefetch(ctx)
+	return ts, nil
+}
 
-	// Close the response body on function return
-	defer resp.Body.Close()
+func (tm *Manager) GetTrackByID(id string) (*Track, bool) {
+	track, found := tm.snapshot.Load().byID[id]
+	if !found {
+		return &Track{}, false
+	}
+	return track, true
+}
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// GetPage returns the tracks in the page of count tracks, along with the page
+// returned and the number of pages. Pages out of range return the closest one.
+func (tm *Manager) GetPage(page, count int) ([]*Track, int, int) {
+	if count <= 0 {
+		count = tracksPerPage
 	}
+	s := tm.snapshot.Load()
+	maxPages := pages(len(s.tracks), count)
+	page = max(0, min(page, maxPages-1))
+	from := min(page*count, len(s.tracks))
+	to := min(from+count, len(s.tracks))
+	return s.tracks[from:to], page, maxPages
+}
 
-	// Unmarshal the response body into a TrackResponse struct
-	var trackNames []string
-	err = json.Unmarshal(body, &trackNames)
+// GetCategoryHistory returns all the stored laps of a category, including the
+// ones that are not part of the current leaderboard.
+func (tm *Manager) GetCategoryHistory(trackId, categoryId string) ([]Session, error) {
+	ss, err := tm.store.ListSessionHistory(trackId)
 	if err != nil {
 		return nil, err
 	}
-
-	// Create a slice of Track structs
-	var tracks []*Track
-	for _, trackName := range trackNames {
-		track := Track{
-			Command: "/" + helper.ToID(trackName),
-			ID:      helper.ToID(trackName),
-			Name:    trackName,
-			mu:      sync.Mutex{},
+	history := []Session{}
+	for _, s := range ss {
+		if id, _ := ExtractCategory(s.Category); id == categoryId {
+			history = append(history, s)
 		}
-		tracks = append(tracks, &track)
 	}
-
-	return tracks, nil
+	return history, nil
 }
@@ -0,0 +1,183 @@
+package tracks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeStore keeps the tracks and sessions in memory.
+type fakeStore struct {
+	mu       sync.Mutex
+	names    []string
+	sessions map[string][]Session
+}
+
+func newFakeStore(names ...string) *fakeStore {
+	return &fakeStore{names: names, sessions: map[string][]Session{}}
+}
+
+func (fs *fakeStore) SaveTracks(ts []*Track) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.names = nil
+	for _, t := range ts {
+		fs.names = append(fs.names, t.Name)
+	}
+	return nil
+}
+
+func (fs *fakeStore) ListTracks() ([]*Track, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	ts := []*Track{}
+	for _, name := range fs.names {
+		ts = append(ts, NewTrack(name))
+	}
+	return ts, nil
+}
+
+func (fs *fakeStore) SaveSessions(trackId string, ss []Session) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.sessions[trackId] = ss
+	return nil
+}
+
+func (fs *fakeStore) ListSessions(trackId string) ([]Session, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.sessions[trackId], nil
+}
+
+func (fs *fakeStore) ListSessionHistory(trackId string) ([]Session, error) {
+	return fs.ListSessions(trackId)
+}
+
+func (fs *fakeStore) ToggleSubscription(chatId int64, trackId, categoryId string) (bool, error) {
+	return false, nil
+}
+
+func (fs *fakeStore) IsSubscribed(chatId int64, trackId, categoryId string) (bool, error) {
+	return false, nil
+}
+
+func (fs *fakeStore) ListSubscribedChats(trackId, categoryId string) ([]int64, error) {
+	return nil, nil
+}
+
+func trackNames(n int) []string {
+	names := []string{}
+	for i := 0; i < n; i++ {
+		names = append(names, fmt.Sprintf("Track %d", i))
+	}
+	return names
+}
+
+func TestGetPage(t *testing.T) {
+	tests := []struct {
+		name      string
+		tracks    int
+		page      int
+		count     int
+		wantLen   int
+		wantPage  int
+		wantPages int
+		wantFirst string
+	}{
+		{name: "empty", tracks: 0, page: 0, count: 10, wantLen: 0, wantPage: 0, wantPages: 1},
+		{name: "empty out of range", tracks: 0, page: 3, count: 10, wantLen: 0, wantPage: 0, wantPages: 1},
+		{name: "first page", tracks: 25, page: 0, count: 10, wantLen: 10, wantPage: 0, wantPages: 3, wantFirst: "Track 0"},
+		{name: "middle page", tracks: 25, page: 1, count: 10, wantLen: 10, wantPage: 1, wantPages: 3, wantFirst: "Track 10"},
+		{name: "partial last page", tracks: 25, page: 2, count: 10, wantLen: 5, wantPage: 2, wantPages: 3, wantFirst: "Track 20"},
+		{name: "full last page", tracks: 20, page: 1, count: 10, wantLen: 10, wantPage: 1, wantPages: 2, wantFirst: "Track 10"},
+		{name: "past the last page", tracks: 25, page: 7, count: 10, wantLen: 5, wantPage: 2, wantPages: 3, wantFirst: "Track 20"},
+		{name: "negative page", tracks: 25, page: -1, count: 10, wantLen: 10, wantPage: 0, wantPages: 3, wantFirst: "Track 0"},
+		{name: "default count", tracks: 25, page: 1, count: 0, wantLen: 10, wantPage: 1, wantPages: 3, wantFirst: "Track 10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := NewTrackManager(nil, newTestClient("http://localhost"), newFakeStore(trackNames(tt.tracks)...), nil)
+			ts, page, pages := tm.GetPage(tt.page, tt.count)
+			if len(ts) != tt.wantLen || page != tt.wantPage || pages != tt.wantPages {
+				t.Fatalf("got %d tracks in page %d of %d, expected %d in page %d of %d", len(ts), page, pages, tt.wantLen, tt.wantPage, tt.wantPages)
+			}
+			if len(ts) > 0 && ts[0].Name != tt.wantFirst {
+				t.Errorf("expected the page to start at %s, got %s", tt.wantFirst, ts[0].Name)
+			}
+		})
+	}
+}
+
+// TestConcurrentRefresh reads the tracks while they are synced with a tracklist
+// that changes on every request. It is meant to be run with -race.
+func TestConcurrentRefresh(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if r.URL.Query().Get("tracklist") != "" {
+			names := trackNames(5 + int(n%17))
+			fmt.Fprintf(w, `["%s"]`, strings.Join(names, `","`))
+			return
+		}
+		fmt.Fprintf(w, `[{"id":"%d","driver":"Driver","category":"GT3","time":%d}]`, n, 90+n%10)
+	}))
+	defer srv.Close()
+	cfg := DefaultClientConfig()
+	cfg.Retries = 0
+	cfg.TTL = 0
+	tm := NewTrackManager(nil, NewClient(srv.URL, cfg), newFakeStore(), nil)
+	defer tm.stopPrefetch()
+	ctx := context.Background()
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			err := tm.Refresh(ctx)
+			if err != nil {
+				t.Errorf("error refreshing: %s", err)
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				ts, err := tm.GetTracks(ctx)
+				if err != nil {
+					t.Errorf("error getting tracks: %s", err)
+					return
+				}
+				for page := -1; page < 5; page++ {
+					pts, p, pages := tm.GetPage(page, 3)
+					if len(pts) > 3 || p < 0 || p >= pages {
+						t.Errorf("got %d tracks in page %d of %d", len(pts), p, pages)
+						return
+					}
+				}
+				for _, track := range ts {
+					_, _ = track.GetCategories(ctx, tm.api)
+					track.GetCategoryById("gt3")
+					tm.GetTrackByID(track.ID)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+}
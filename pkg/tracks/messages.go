@@ -0,0 +1,77 @@
+package tracks
+
+import "github.com/nicksnyder/go-i18n/v2/i18n"
+
+var (
+	msgNoTracks              = &i18n.Message{ID: "tracks.noTracks", Other: "There are no tracks available"}
+	msgNoCategories          = &i18n.Message{ID: "tracks.noCategories", Other: "There are no categories for this track"}
+	msgNoSessions            = &i18n.Message{ID: "tracks.noSessions", Other: "There are no sessions available"}
+	msgNoSessionsRecorded    = &i18n.Message{ID: "tracks.noSessionsRecorded", Other: "There are no sessions recorded"}
+	msgTrackNotFound         = &i18n.Message{ID: "tracks.trackNotFound", Other: "The selected track was not found. Go back and try again"}
+	msgSessionsNotFound      = &i18n.Message{ID: "tracks.sessionsNotFound", Other: "The sessions for the track were not found. Go back and try again"}
+	msgChooseCategory        = &i18n.Message{ID: "tracks.chooseCategory", Other: "Choose category for %s:"}
+	msgChooseTrack           = &i18n.Message{ID: "tracks.chooseTrack", Other: "Choose the track from the list (%d/%d):"}
+	msgResults               = &i18n.Message{ID: "tracks.results", Other: "Results in %q for %q"}
+	msgUnknown               = &i18n.Message{ID: "tracks.unknown", Other: "(unknown)"}
+	msgOutOfCutOff           = &i18n.Message{ID: "tracks.outOfCutOff", Other: "Out of %.0f%% (%s)"}
+	msgCouldNotSubscribe     = &i18n.Message{ID: "tracks.couldNotSubscribe", Other: "The notifications settings could not be changed"}
+	msgDriverUsage           = &i18n.Message{ID: "tracks.driverUsage", Other: "Write the name of the driver, for example: /driver Fernando Alonso"}
+	msgDriverAmbiguous       = &i18n.Message{ID: "tracks.driverAmbiguous", Other: "There are several drivers with that name, choose one:"}
+	msgDriverNotFound        = &i18n.Message{ID: "tracks.driverNotFound", Other: "There are no laps recorded for the driver"}
+	msgDriverBests           = &i18n.Message{ID: "tracks.driverBests", Other: "Best laps of %s (%d/%d):"}
+	msgRecentActivity        = &i18n.Message{ID: "tracks.recentActivity", Other: "Recent activity (%d/%d):"}
+	msgCompareChooseFirst    = &i18n.Message{ID: "tracks.compareChooseFirst", Other: "Choose the first driver to compare in %q for %q:"}
+	msgCompareChooseSecond   = &i18n.Message{ID: "tracks.compareChooseSecond", Other: "Choose the driver to compare with %s:"}
+	msgCompareTitle          = &i18n.Message{ID: "tracks.compareTitle", Other: "%s vs %s in %q for %q"}
+	msgOptimalTitle          = &i18n.Message{ID: "tracks.optimalTitle", Other: "Optimal lap in %q for %q"}
+	msgIdealLap              = &i18n.Message{ID: "tracks.idealLap", Other: "Ideal lap: %s"}
+	msgImprovementP1         = &i18n.Message{ID: "tracks.improvementP1", Other: "New P1 in %s (%s)"}
+	msgImprovementPB         = &i18n.Message{ID: "tracks.improvementPB", Other: "Personal best in %s (%s)"}
+	msgImprovementNewEntry   = &i18n.Message{ID: "tracks.improvementNewEntry", Other: "New driver in %s (%s)"}
+	msgImprovementPreviousP1 = &i18n.Message{ID: "tracks.improvementPreviousP1", Other: "Previous P1: %s by %s"}
+	msgImprovementPrevious   = &i18n.Message{ID: "tracks.improvementPrevious", Other: "Previous: %s"}
+	msgImprovementLeader     = &i18n.Message{ID: "tracks.improvementLeader", Other: "P1: %s by %s"}
+	msgImprovementGap        = &i18n.Message{ID: "tracks.improvementGap", Other: "Gap: %s"}
+	msgImprovementSectors    = &i18n.Message{ID: "tracks.improvementSectors", Other: "Sectors: %s %s %s"}
+	msgExportChoose          = &i18n.Message{ID: "tracks.exportChoose", Other: "Choose the format to export the results in %q for %q:"}
+	msgCardSubtitle          = &i18n.Message{ID: "tracks.cardSubtitle", Other: "%s · %d drivers"}
+	msgCardFooter            = &i18n.Message{ID: "tracks.cardFooter", Other: "Showing the first %d of %d drivers"}
+	msgAPINotFound           = &i18n.Message{ID: "tracks.apiNotFound", Other: "The F1Champs API has no data for this request"}
+	msgAPIServer             = &i18n.Message{ID: "tracks.apiServer", Other: "The F1Champs API is failing right now. Try again later"}
+	msgAPIDecode             = &i18n.Message{ID: "tracks.apiDecode", Other: "The F1Champs API sent an unexpected answer. Try again later"}
+	msgAPIUnavailable        = &i18n.Message{ID: "tracks.apiUnavailable", Other: "The F1Champs API cannot be reached. Try again later"}
+
+	msgKeyboardTimes         = &i18n.Message{ID: "tracks.keyboardTimes", Other: "Times"}
+	msgKeyboardSectors       = &i18n.Message{ID: "tracks.keyboardSectors", Other: "Sectors"}
+	msgKeyboardCompound      = &i18n.Message{ID: "tracks.keyboardCompound", Other: "Tyres"}
+	msgKeyboardLaps          = &i18n.Message{ID: "tracks.keyboardLaps", Other: "Laps"}
+	msgKeyboardTeam          = &i18n.Message{ID: "tracks.keyboardTeam", Other: "Cars"}
+	msgKeyboardDriver        = &i18n.Message{ID: "tracks.keyboardDriver", Other: "Drivers"}
+	msgKeyboardDate          = &i18n.Message{ID: "tracks.keyboardDate", Other: "Date"}
+	msgKeyboardNotifications = &i18n.Message{ID: "tracks.keyboardNotifications", Other: "Alerts"}
+	msgKeyboardGap           = &i18n.Message{ID: "tracks.keyboardGap", Other: "Gap"}
+	msgKeyboardInterval      = &i18n.Message{ID: "tracks.keyboardInterval", Other: "Interval"}
+	msgKeyboardPercentage    = &i18n.Message{ID: "tracks.keyboardPercentage", Other: "107%"}
+	msgKeyboardCompare       = &i18n.Message{ID: "tracks.keyboardCompare", Other: "Compare"}
+	msgKeyboardOptimal       = &i18n.Message{ID: "tracks.keyboardOptimal", Other: "Optimal"}
+	msgKeyboardExport        = &i18n.Message{ID: "tracks.keyboardExport", Other: "Export"}
+	msgKeyboardCard          = &i18n.Message{ID: "tracks.keyboardCard", Other: "Card"}
+
+	msgHeaderDriver   = &i18n.Message{ID: "tracks.headerDriver", Other: "DRI"}
+	msgHeaderBest     = &i18n.Message{ID: "tracks.headerBest", Other: "Best"}
+	msgHeaderOptimal  = &i18n.Message{ID: "tracks.headerOptimal", Other: "Optimal"}
+	msgHeaderPosition = &i18n.Message{ID: "tracks.headerPosition", Other: "POS"}
+	msgHeaderLap      = &i18n.Message{ID: "tracks.headerLap", Other: "LAP"}
+	msgHeaderTyres    = &i18n.Message{ID: "tracks.headerTyres", Other: "TYRES"}
+	msgHeaderFuel     = &i18n.Message{ID: "tracks.headerFuel", Other: "FUEL"}
+	msgHeaderFl       = &i18n.Message{ID: "tracks.headerFl", Other: "P. FL"}
+	msgHeaderFr       = &i18n.Message{ID: "tracks.headerFr", Other: "P. FR"}
+	msgHeaderRl       = &i18n.Message{ID: "tracks.headerRl", Other: "P. RL"}
+	msgHeaderRr       = &i18n.Message{ID: "tracks.headerRr", Other: "P. RR"}
+
+	msgCardHeaderDriver  = &i18n.Message{ID: "tracks.cardHeaderDriver", Other: "DRIVER"}
+	msgCardHeaderClass   = &i18n.Message{ID: "tracks.cardHeaderClass", Other: "CLASS"}
+	msgCardHeaderTime    = &i18n.Message{ID: "tracks.cardHeaderTime", Other: "TIME"}
+	msgCardHeaderGap     = &i18n.Message{ID: "tracks.cardHeaderGap", Other: "GAP"}
+	msgCardHeaderSectors = &i18n.Message{ID: "tracks.cardHeaderSectors", Other: "SECTORS"}
+)
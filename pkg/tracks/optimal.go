@@ -0,0 +1,101 @@
+package tracks
+
+import "sort"
+
+// Sectors holds the best time of each sector.
+type Sectors struct {
+	S1 float64
+	S2 float64
+	S3 float64
+}
+
+// Time returns the lap time built from the sectors or -1 if any is missing.
+func (s Sectors) Time() float64 {
+	if s.S1 > 0.0 && s.S2 > 0.0 && s.S3 > 0.0 {
+		return s.S1 + s.S2 + s.S3
+	}
+	return -1.0
+}
+
+// OptimalLap returns the best sectors of the driver across all their laps.
+func OptimalLap(driver string, ss []Session) Sectors {
+	optimal := Sectors{}
+	for _, s := range ss {
+		if s.Driver != driver {
+			continue
+		}
+		optimal = bestSectors(optimal, s)
+	}
+	return optimal
+}
+
+func bestSectors(sectors Sectors, s Session) Sectors {
+	if s.S1 > 0 && (sectors.S1 <= 0 || s.S1 < sectors.S1) {
+		sectors.S1 = s.S1
+	}
+	if s.S2 > 0 && (sectors.S2 <= 0 || s.S2 < sectors.S2) {
+		sectors.S2 = s.S2
+	}
+	if s.S3 > 0 && (sectors.S3 <= 0 || s.S3 < sectors.S3) {
+		sectors.S3 = s.S3
+	}
+	return sectors
+}
+
+// OptimalEntry compares the best lap of a driver with their optimal lap.
+type OptimalEntry struct {
+	Driver  string
+	Best    float64
+	Optimal Sectors
+}
+
+// Loss returns how much time the driver leaves in their best lap compared
+// with their optimal one. It is meaningless if the optimal lap is unknown.
+func (e OptimalEntry) Loss() float64 {
+	return e.Best - e.Optimal.Time()
+}
+
+// IdealLap is the lap built from the fastest time of each sector in a category.
+type IdealLap struct {
+	Sectors Sectors
+	Drivers [3]string
+}
+
+// OptimalLeaderboard returns the drivers ranked by how close their best lap is
+// to their own optimal lap along with the ideal lap of the category. Sessions
+// must be sorted by time.
+func OptimalLeaderboard(ss []Session) ([]OptimalEntry, IdealLap) {
+	entries := []OptimalEntry{}
+	ideal := IdealLap{}
+	for _, best := range uniqueDrivers(ss) {
+		entries = append(entries, OptimalEntry{
+			Driver:  best.Driver,
+			Best:    best.Time,
+			Optimal: OptimalLap(best.Driver, ss),
+		})
+	}
+	for _, s := range ss {
+		if s.S1 > 0 && (ideal.Sectors.S1 <= 0 || s.S1 < ideal.Sectors.S1) {
+			ideal.Sectors.S1 = s.S1
+			ideal.Drivers[0] = s.Driver
+		}
+		if s.S2 > 0 && (ideal.Sectors.S2 <= 0 || s.S2 < ideal.Sectors.S2) {
+			ideal.Sectors.S2 = s.S2
+			ideal.Drivers[1] = s.Driver
+		}
+		if s.S3 > 0 && (ideal.Sectors.S3 <= 0 || s.S3 < ideal.Sectors.S3) {
+			ideal.Sectors.S3 = s.S3
+			ideal.Drivers[2] = s.Driver
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		knownI, knownJ := entries[i].Optimal.Time() > 0, entries[j].Optimal.Time() > 0
+		if !knownI || !knownJ {
+			// unknown optimal laps go last
+			return knownI && !knownJ
+		}
+		return entries[i].Loss() < entries[j].Loss()
+	})
+	return entries, ideal
+}
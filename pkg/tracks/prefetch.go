@@ -0,0 +1,124 @@
+package tracks
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// prefetchWorkers bounds the tracks whose categories are loaded at once,
+	// so the API is not flooded after a sync
+	prefetchWorkers = 4
+)
+
+// PrefetchStatus is the progress of the load of the categories of every
+// track, which runs after the tracks are synced so the first user does not
+// wait for them.
+type PrefetchStatus struct {
+	Running  bool
+	Canceled bool
+	Done     int
+	Total    int
+	// Errors are the tracks whose categories could not be loaded, by name
+	Errors   map[string]error
+	Started  time.Time
+	Finished time.Time
+}
+
+// PrefetchStatus returns the progress of the last prefetch. It is zero if no
+// prefetch has been started yet.
+func (tm *Manager) PrefetchStatus() PrefetchStatus {
+	tm.prefetchMu.Lock()
+	defer tm.prefetchMu.Unlock()
+	if tm.prefetching == nil {
+		return PrefetchStatus{}
+	}
+	status := *tm.prefetching
+	status.Errors = make(map[string]error, len(tm.prefetching.Errors))
+	for name, err := range tm.prefetching.Errors {
+		status.Errors[name] = err
+	}
+	return status
+}
+
+// prefetch loads the categories of every track in the background with a pool
+// of workers. A prefetch still running is canceled, as its tracks are stale.
+func (tm *Manager) prefetch(ctx context.Context) {
+	ts := tm.snapshot.Load().tracks
+	ctx, cancel := context.WithCancel(ctx)
+	status := &PrefetchStatus{
+		Running: true,
+		Total:   len(ts),
+		Errors:  map[string]error{},
+		Started: time.Now(),
+	}
+
+	tm.prefetchMu.Lock()
+	if tm.prefetchCancel != nil {
+		tm.prefetchCancel()
+	}
+	tm.prefetchCancel = cancel
+	tm.prefetching = status
+	tm.prefetchMu.Unlock()
+
+	go func() {
+		defer cancel()
+
+		jobs := make(chan *Track)
+		wg := sync.WaitGroup{}
+		for i := 0; i < prefetchWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for t := range jobs {
+					_, err := t.GetCategories(ctx, tm.api)
+					tm.prefetchMu.Lock()
+					status.Done++
+					if err != nil {
+						status.Errors[t.Name] = err
+					}
+					tm.prefetchMu.Unlock()
+				}
+			}()
+		}
+	loop:
+		for _, t := range ts {
+			// select picks at random when a worker is free too
+			if ctx.Err() != nil {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				break loop
+			case jobs <- t:
+			}
+		}
+		close(jobs)
+		wg.Wait()
+
+		tm.prefetchMu.Lock()
+		status.Running = false
+		status.Canceled = ctx.Err() != nil
+		status.Finished = time.Now()
+		canceled, done, failed := status.Canceled, status.Done, len(status.Errors)
+		elapsed := status.Finished.Sub(status.Started)
+		tm.prefetchMu.Unlock()
+		if canceled {
+			log.Printf("Prefetch of categories canceled after %d of %d tracks", done, len(ts))
+			return
+		}
+		log.Printf("Prefetched categories of %d tracks in %s, %d failed", done, elapsed.Round(time.Millisecond), failed)
+	}()
+}
+
+// stopPrefetch cancels the prefetch running, if any.
+func (tm *Manager) stopPrefetch() {
+	tm.prefetchMu.Lock()
+	defer tm.prefetchMu.Unlock()
+	if tm.prefetchCancel != nil {
+		tm.prefetchCancel()
+		tm.prefetchCancel = nil
+	}
+}
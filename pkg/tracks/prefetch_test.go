@@ -0,0 +1,141 @@
+package tracks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitPrefetch waits for the prefetch to finish and returns its status.
+func waitPrefetch(t *testing.T, tm *Manager, status *PrefetchStatus) PrefetchStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		tm.prefetchMu.Lock()
+		s := *status
+		tm.prefetchMu.Unlock()
+		if !s.Running {
+			return s
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("the prefetch did not finish")
+	return PrefetchStatus{}
+}
+
+func currentPrefetch(tm *Manager) *PrefetchStatus {
+	tm.prefetchMu.Lock()
+	defer tm.prefetchMu.Unlock()
+	return tm.prefetching
+}
+
+func TestPrefetch(t *testing.T) {
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if r.URL.Query().Get("track") == "Track 2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":"1","driver":"Driver","category":"GT3","time":90}]`))
+	}))
+	defer srv.Close()
+	tm := NewTrackManager(nil, newTestClient(srv.URL), newFakeStore(trackNames(12)...), nil)
+
+	tm.prefetch(context.Background())
+	status := waitPrefetch(t, tm, currentPrefetch(tm))
+	if status.Canceled || status.Done != 12 || status.Total != 12 {
+		t.Errorf("expected the 12 tracks prefetched, got %d of %d, canceled %t", status.Done, status.Total, status.Canceled)
+	}
+	if len(status.Errors) != 1 || !errors.Is(status.Errors["Track 2"], ErrNotFound) {
+		t.Errorf("expected Track 2 not found, got %v", status.Errors)
+	}
+	if maxInFlight > prefetchWorkers {
+		t.Errorf("expected at most %d requests at once, got %d", prefetchWorkers, maxInFlight)
+	}
+	if maxInFlight < 2 {
+		t.Errorf("expected the tracks to be prefetched in parallel, got %d requests at once", maxInFlight)
+	}
+	if status.Finished.Before(status.Started) {
+		t.Errorf("finished at %s, before starting at %s", status.Finished, status.Started)
+	}
+
+	// the copy returned does not change with the prefetch
+	copied := tm.PrefetchStatus()
+	copied.Errors["Track 3"] = fmt.Errorf("error")
+	if len(tm.PrefetchStatus().Errors) != 1 {
+		t.Error("the errors of the status returned are shared with the manager")
+	}
+}
+
+func TestPrefetchCancelsPrevious(t *testing.T) {
+	release := make(chan bool)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":"1","driver":"Driver","category":"GT3","time":90}]`))
+	}))
+	defer srv.Close()
+	tm := NewTrackManager(nil, newTestClient(srv.URL), newFakeStore(trackNames(12)...), nil)
+	ctx := context.Background()
+
+	tm.prefetch(ctx)
+	first := currentPrefetch(tm)
+	// the workers are waiting for the API when the next prefetch starts
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&requests) < prefetchWorkers && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	tm.prefetch(ctx)
+	second := currentPrefetch(tm)
+	if first == second {
+		t.Fatal("the second prefetch did not replace the first one")
+	}
+
+	status := waitPrefetch(t, tm, first)
+	if !status.Canceled || status.Done >= status.Total {
+		t.Errorf("expected the first prefetch canceled, got %d of %d, canceled %t", status.Done, status.Total, status.Canceled)
+	}
+
+	close(release)
+	status = waitPrefetch(t, tm, second)
+	if status.Canceled || status.Done != 12 || len(status.Errors) != 0 {
+		t.Errorf("expected the 12 tracks prefetched, got %d of %d, canceled %t, errors %v", status.Done, status.Total, status.Canceled, status.Errors)
+	}
+	if tm.PrefetchStatus().Started != second.Started {
+		t.Error("the status returned is not the one of the last prefetch")
+	}
+}
+
+func TestStopPrefetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	tm := NewTrackManager(nil, newTestClient(srv.URL), newFakeStore(trackNames(12)...), nil)
+
+	tm.prefetch(context.Background())
+	tm.stopPrefetch()
+	status := waitPrefetch(t, tm, currentPrefetch(tm))
+	if !status.Canceled {
+		t.Errorf("expected the prefetch canceled, got %d of %d", status.Done, status.Total)
+	}
+}
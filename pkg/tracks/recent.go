@@ -0,0 +1,190 @@
+package tracks
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	SubcommandShowRecent = "show_recent"
+
+	// recentLimit is how many tracks and categories are listed in the recent
+	// activity
+	recentLimit    = 30
+	recentPerPage  = 10
+	recentDateTime = "2006-01-02 15:04"
+)
+
+// Activity is the last lap driven in a track and category.
+type Activity struct {
+	Track    *Track
+	Category Category
+	Session  Session
+	// Date is zero when the date of the lap cannot be parsed
+	Date time.Time
+}
+
+// GetRecentActivity returns the tracks and categories with laps, the ones
+// driven most recently first, up to limit. The ones whose laps have no date
+// go last. The activity is computed once for every sync that changes the
+// tracks, and the slice is shared and must not be modified.
+func (tm *Manager) GetRecentActivity(ctx context.Context, limit int) ([]Activity, error) {
+	_, err := tm.GetTracks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := tm.snapshot.Load()
+
+	tm.recentMu.Lock()
+	defer tm.recentMu.Unlock()
+	activities := tm.recent
+	if tm.recentOf != s {
+		var complete bool
+		activities, complete = tm.recentActivity(ctx, s.tracks)
+		// the tracks that failed are tried again on next request
+		if complete {
+			tm.recent, tm.recentOf = activities, s
+		}
+	}
+	if len(activities) > limit {
+		activities = activities[:limit]
+	}
+	return activities, nil
+}
+
+// recentActivity returns the last lap of every track and category, the most
+// recent first. It is false if the categories of any track cannot be read.
+func (tm *Manager) recentActivity(ctx context.Context, ts []*Track) ([]Activity, bool) {
+	complete := true
+	activities := []Activity{}
+	for _, t := range ts {
+		cats, err := t.GetCategories(ctx, tm.api)
+		if err != nil {
+			log.Printf("Error getting categories for %s: %s", t.Name, err.Error())
+			complete = false
+			continue
+		}
+		for _, cat := range cats {
+			if activity, found := lastActivity(t, cat); found {
+				activities = append(activities, activity)
+			}
+		}
+	}
+
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].Date.After(activities[j].Date)
+	})
+	return activities, complete
+}
+
+// lastActivity returns the most recent lap of the category. Without dates,
+// the first lap is returned.
+func lastActivity(t *Track, cat Category) (Activity, bool) {
+	if len(cat.Sessions) == 0 {
+		return Activity{}, false
+	}
+	activity := Activity{Track: t, Category: cat, Session: cat.Sessions[0]}
+	activity.Date, _ = cat.Sessions[0].Date()
+	for _, s := range cat.Sessions[1:] {
+		if date, ok := s.Date(); ok && date.After(activity.Date) {
+			activity.Session = s
+			activity.Date = date
+		}
+	}
+	return activity, true
+}
+
+func SendRecentData(chatId int64, activities []Activity, currentPage, count int, messageId *int, tm *Manager, loc *i18n.Localizer) error {
+	text, keyboard := RecentTextMarkup(activities, currentPage, count, loc)
+
+	var cfg tgbotapi.Chattable
+	if messageId == nil {
+		msg := tgbotapi.NewMessage(chatId, text)
+		msg.ReplyMarkup = keyboard
+		cfg = msg
+	} else {
+		msg := tgbotapi.NewEditMessageText(chatId, *messageId, text)
+		msg.ReplyMarkup = &keyboard
+		cfg = msg
+	}
+
+	_, err := tm.bot.Send(cfg)
+	return err
+}
+
+func RecentTextMarkup(activities []Activity, currentPage, count int, loc *i18n.Localizer) (text string, markup tgbotapi.InlineKeyboardMarkup) {
+	maxPages := pages(len(activities), count)
+	currentPage = max(0, min(currentPage, maxPages-1))
+	from := min(currentPage*count, len(activities))
+	to := min(from+count, len(activities))
+
+	var lines []string
+	for _, activity := range activities[from:to] {
+		date := "-"
+		if !activity.Date.IsZero() {
+			date = activity.Date.Format(recentDateTime)
+		}
+		lines = append(lines, fmt.Sprintf(" ▸ %s (%s)\n     %s %s %s ➡ /%s_%s", activity.Track.Name, activity.Category.Name, date, helper.GetDriverCodeName(activity.Session.Driver), helper.SecondsToMinutes(activity.Session.Time), activity.Track.ID, activity.Category.ID))
+	}
+	text = fmt.Sprintf(locale.Localize(loc, msgRecentActivity)+"\n\n", currentPage+1, maxPages)
+	text += strings.Join(lines, "\n")
+
+	var rows []tgbotapi.InlineKeyboardButton
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolInit, fmt.Sprintf("%s:init:%d:%d", SubcommandShowRecent, currentPage, count)))
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolPrev, fmt.Sprintf("%s:prev:%d:%d", SubcommandShowRecent, currentPage, count)))
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolNext, fmt.Sprintf("%s:next:%d:%d", SubcommandShowRecent, currentPage, count)))
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolEnd, fmt.Sprintf("%s:end:%d:%d", SubcommandShowRecent, currentPage, count)))
+
+	markup = tgbotapi.NewInlineKeyboardMarkup(rows)
+	return
+}
+
+func HandleRecentDataCallbackQuery(ctx context.Context, chatId int64, messageId int, tm *Manager, data ...string) error {
+	if len(data) < 3 {
+		return nil
+	}
+	pagerType := data[0]
+	currentPage, _ := strconv.Atoi(data[1])
+	itemsPerPage, _ := strconv.Atoi(data[2])
+	if itemsPerPage <= 0 {
+		itemsPerPage = recentPerPage
+	}
+	loc := tm.locale.Localizer(ctx)
+
+	activities, err := tm.GetRecentActivity(ctx, recentLimit)
+	if err != nil {
+		return tm.renderAPIError(chatId, loc, err)
+	}
+	maxPages := pages(len(activities), itemsPerPage)
+
+	if pagerType == "next" {
+		nextPage := currentPage + 1
+		if nextPage < maxPages {
+			return SendRecentData(chatId, activities, nextPage, itemsPerPage, &messageId, tm, loc)
+		}
+	}
+	if pagerType == "prev" {
+		previousPage := currentPage - 1
+		if previousPage >= 0 {
+			return SendRecentData(chatId, activities, previousPage, itemsPerPage, &messageId, tm, loc)
+		}
+	}
+	if pagerType == "init" && currentPage != 0 {
+		return SendRecentData(chatId, activities, 0, itemsPerPage, &messageId, tm, loc)
+	}
+	if pagerType == "end" && currentPage != maxPages-1 {
+		return SendRecentData(chatId, activities, maxPages-1, itemsPerPage, &messageId, tm, loc)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package tracks
+
+import (
+	"context"
+	"testing"
+)
+
+func lap(driver, dateTime string) Session {
+	return Session{Driver: driver, Category: "GT3", Time: 90, DateTime: dateTime}
+}
+
+func TestLastActivity(t *testing.T) {
+	tests := []struct {
+		name       string
+		sessions   []Session
+		wantFound  bool
+		wantDriver string
+		wantDated  bool
+	}{
+		{name: "no laps"},
+		{
+			name:       "most recent lap",
+			sessions:   []Session{lap("A", "2024-03-01 10:00:00"), lap("B", "2024-03-02 12:00:00"), lap("C", "2024-03-02 11:00:00")},
+			wantFound:  true,
+			wantDriver: "B",
+			wantDated:  true,
+		},
+		{
+			name:       "fastest lap without date",
+			sessions:   []Session{lap("A", ""), lap("B", "2024-03-01 09:00:00")},
+			wantFound:  true,
+			wantDriver: "B",
+			wantDated:  true,
+		},
+		{
+			name:       "slower lap without date",
+			sessions:   []Session{lap("A", "2024-03-01 09:00:00"), lap("B", "")},
+			wantFound:  true,
+			wantDriver: "A",
+			wantDated:  true,
+		},
+		{
+			name:       "invalid date",
+			sessions:   []Session{lap("A", "2024-03-01 09:00:00"), lap("B", "not a date")},
+			wantFound:  true,
+			wantDriver: "A",
+			wantDated:  true,
+		},
+		{
+			name:       "no dates",
+			sessions:   []Session{lap("A", ""), lap("B", "")},
+			wantFound:  true,
+			wantDriver: "A",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activity, found := lastActivity(NewTrack("Imola"), Category{ID: "gt3", Name: "GT3", Sessions: tt.sessions})
+			if found != tt.wantFound {
+				t.Fatalf("expected found %t, got %t", tt.wantFound, found)
+			}
+			if !found {
+				return
+			}
+			if activity.Session.Driver != tt.wantDriver {
+				t.Errorf("expected the lap of %s, got %s", tt.wantDriver, activity.Session.Driver)
+			}
+			if activity.Date.IsZero() == tt.wantDated {
+				t.Errorf("expected dated %t, got %s", tt.wantDated, activity.Date)
+			}
+		})
+	}
+}
+
+func TestGetRecentActivity(t *testing.T) {
+	fs := newFakeStore("Imola", "Spa", "Monza")
+	imola, spa, monza := NewTrack("Imola").ID, NewTrack("Spa").ID, NewTrack("Monza").ID
+	fs.sessions[imola] = []Session{
+		{Driver: "A", Category: "GT3", DateTime: "2024-01-01 10:00:00"},
+	}
+	fs.sessions[spa] = []Session{
+		{Driver: "B", Category: "GT3", DateTime: ""},
+		{Driver: "C", Category: "LMP2", DateTime: "2024-03-01 10:00:00"},
+	}
+	fs.sessions[monza] = []Session{
+		{Driver: "D", Category: "GT3", DateTime: "2024-02-01 10:00:00"},
+	}
+	tm := NewTrackManager(nil, newTestClient("http://localhost"), fs, nil)
+	ctx := context.Background()
+
+	activities, err := tm.GetRecentActivity(ctx, recentLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"C", "D", "A", "B"}
+	if len(activities) != len(want) {
+		t.Fatalf("expected %d activities, got %d", len(want), len(activities))
+	}
+	for i, driver := range want {
+		if activities[i].Session.Driver != driver {
+			t.Errorf("expected the lap of %s at %d, got %s", driver, i, activities[i].Session.Driver)
+		}
+	}
+
+	activities, err = tm.GetRecentActivity(ctx, 1)
+	if err != nil || len(activities) != 1 || activities[0].Session.Driver != "C" {
+		t.Fatalf("expected the lap of C only, got %v, %v", activities, err)
+	}
+
+	// the activity is kept until the sessions of a track are synced
+	_ = fs.SaveSessions(monza, []Session{{Driver: "E", Category: "GT3", DateTime: "2024-04-01 10:00:00"}})
+	activities, _ = tm.GetRecentActivity(ctx, 1)
+	if activities[0].Session.Driver != "C" {
+		t.Errorf("expected the cached lap of C, got %s", activities[0].Session.Driver)
+	}
+	tm.reset([]string{monza})
+	activities, _ = tm.GetRecentActivity(ctx, 1)
+	if activities[0].Session.Driver != "E" {
+		t.Errorf("expected the lap of E after the sync, got %s", activities[0].Session.Driver)
+	}
+}
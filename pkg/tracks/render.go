@@ -2,48 +2,56 @@ package tracks
 
 import (
 	"context"
+	"errors"
+	"f1champshotlapsbot/pkg/locale"
 	"fmt"
 	"log"
 	"strings"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
 func (tm *Manager) RenderShowTracksCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
 	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
-		tracks, err := tm.GetTracks(ctx)
+		loc := tm.locale.Localizer(ctx)
+		_, err := tm.GetTracks(ctx)
 		if err != nil {
-			log.Printf("An error occured: %s", err.Error())
-			message := "No hay circuitos disponibles"
-			msg := tgbotapi.NewMessage(query.Message.Chat.ID, message)
-			_, err = tm.bot.Send(msg)
-			return err
+			return tm.renderAPIError(query.Message.Chat.ID, loc, err)
 		}
-		maxPages := len(tracks) / tracksPerPage
-		return HandleTrackDataCallbackQuery(query.Message.Chat.ID, query.Message.MessageID, maxPages, tm, data[1:]...)
+		return HandleTrackDataCallbackQuery(query.Message.Chat.ID, query.Message.MessageID, tm, loc, data[1:]...)
 	}
 }
 
 func (tm *Manager) RenderSessionsCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
 	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
-		return HandleSessionDataCallbackQuery(query.Message.Chat.ID, &query.Message.MessageID, tm, data[1:]...)
+		loc := tm.locale.Localizer(ctx)
+		return HandleSessionDataCallbackQuery(query.Message.Chat.ID, &query.Message.MessageID, tm, loc, data[1:]...)
+	}
+}
+
+func (tm *Manager) RenderSubscribeCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+		loc := tm.locale.Localizer(ctx)
+		return HandleSubscribeCallbackQuery(query.Message.Chat.ID, &query.Message.MessageID, tm, loc, data[1:]...)
 	}
 }
 
 func (tm *Manager) RenderTracks() func(ctx context.Context, chatId int64) error {
 	return func(ctx context.Context, chatId int64) error {
+		loc := tm.locale.Localizer(ctx)
 		tracks, err := tm.GetTracks(ctx)
 		if err != nil {
-			return err
+			return tm.renderAPIError(chatId, loc, err)
 		}
 
 		if len(tracks) > 0 {
-			err := SendTracksData(chatId, 0, tracksPerPage, len(tracks)/tracksPerPage, nil, tm)
+			err := SendTracksData(chatId, 0, tracksPerPage, nil, tm, loc)
 			if err != nil {
 				return err
 			}
 		} else {
-			message := "No hay circuitos disponibles"
+			message := locale.Localize(loc, msgNoTracks)
 			msg := tgbotapi.NewMessage(chatId, message)
 			_, err = tm.bot.Send(msg)
 			return err
@@ -54,16 +62,17 @@ func (tm *Manager) RenderTracks() func(ctx context.Context, chatId int64) error
 
 func (tm *Manager) RenderCategoriesForTrackId(trackId int) func(ctx context.Context, chatId int64) error {
 	return func(ctx context.Context, chatId int64) error {
+		loc := tm.locale.Localizer(ctx)
 		track, found := tm.GetTrackByID(fmt.Sprint(trackId))
 		if !found {
-			return tm.RenderTrackNotFound(chatId)
+			return tm.RenderTrackNotFound(chatId, loc)
 		}
-		cats, err := track.GetCategories(ctx, tm.apiDomain)
+		cats, err := track.GetCategories(ctx, tm.api)
 		if err != nil {
-			return err
+			return tm.renderAPIError(chatId, loc, err)
 		}
 
-		message := fmt.Sprintf("Elige categoría para %s:\n\n", track.Name)
+		message := fmt.Sprintf(locale.Localize(loc, msgChooseCategory)+"\n\n", track.Name)
 		if len(cats) > 0 {
 			categoriesStrings := make([]string, len(cats))
 			for i, cat := range cats {
@@ -72,7 +81,7 @@ func (tm *Manager) RenderCategoriesForTrackId(trackId int) func(ctx context.Cont
 
 			message += strings.Join(categoriesStrings, "\n")
 		} else {
-			message = "No hay categorías para este circuito"
+			message = locale.Localize(loc, msgNoCategories)
 		}
 		msg := tgbotapi.NewMessage(chatId, message)
 		_, err = tm.bot.Send(msg)
@@ -83,13 +92,17 @@ func (tm *Manager) RenderCategoriesForTrackId(trackId int) func(ctx context.Cont
 
 func (tm *Manager) RenderSessionForCategoryAndTrack(trackId string, categoryId string) func(ctx context.Context, chatId int64) error {
 	return func(ctx context.Context, chatId int64) error {
+		loc := tm.locale.Localizer(ctx)
 		t, found := tm.GetTrackByID(trackId)
 		if !found {
-			return tm.RenderTrackNotFound(chatId)
+			return tm.RenderTrackNotFound(chatId, loc)
+		}
+		_, err := t.GetCategories(ctx, tm.api)
+		if err != nil {
+			return tm.renderAPIError(chatId, loc, err)
 		}
-		_, _ = t.GetCategories(ctx, tm.apiDomain)
 
-		err := SendSessionData(chatId, nil, trackId, categoryId, inlineKeyboardTimes, tm)
+		err = SendSessionData(chatId, nil, trackId, categoryId, inlineKeyboardTimes, tm, loc)
 		if err != nil {
 			log.Printf("An error occured: %s", err.Error())
 		}
@@ -97,62 +110,124 @@ func (tm *Manager) RenderSessionForCategoryAndTrack(trackId string, categoryId s
 	}
 }
 
-func (tm *Manager) RenderTrackNotFound(chatId int64) error {
-	message := fmt.Sprintf("El circuito seleccionado no se ha encontrado. Vuelve a  y prueba otra vez")
+func (tm *Manager) RenderTrackNotFound(chatId int64, loc *i18n.Localizer) error {
+	message := locale.Localize(loc, msgTrackNotFound)
 	msg := tgbotapi.NewMessage(chatId, message)
 	_, err := tm.bot.Send(msg)
 	return err
 }
 
+// RenderCurrentSession shows the leaderboard of the track and category with
+// the most recent lap.
 func (tm *Manager) RenderCurrentSession() func(ctx context.Context, chatId int64) error {
 	return func(ctx context.Context, chatId int64) error {
-		tracks, err := tm.GetTracks(ctx)
+		loc := tm.locale.Localizer(ctx)
+		activities, err := tm.GetRecentActivity(ctx, 1)
 		if err != nil {
+			return tm.renderAPIError(chatId, loc, err)
+		}
+		if len(activities) == 0 {
+			message := locale.Localize(loc, msgNoSessions)
+			msg := tgbotapi.NewMessage(chatId, message)
+			_, err = tm.bot.Send(msg)
 			return err
 		}
 
-		if len(tracks) > 0 {
-			track := tracks[0]
-			cats, err := track.GetCategories(ctx, tm.apiDomain)
-			if err != nil {
-				return err
-			}
+		current := activities[0]
+		return tm.RenderSessionForCategoryAndTrack(current.Track.ID, current.Category.ID)(ctx, chatId)
+	}
+}
 
-			if len(cats) > 0 {
-				selectedCat := Category{}
-				for _, cat := range cats {
-					if len(cat.Sessions) > 0 {
-						selectedCat = cat
-						break
-					}
-				}
-				if len(selectedCat.Sessions) == 0 {
-					message := "No hay sesiones disponibles"
-					msg := tgbotapi.NewMessage(chatId, message)
-					_, err = tm.bot.Send(msg)
-					return err
-				}
-
-				for _, cat := range cats {
-					if len(cat.Sessions) > 0 {
-						if selectedCat.Sessions[0].Time < cat.Sessions[0].Time {
-							selectedCat = cat
-						}
-					}
-				}
-				return tm.RenderSessionForCategoryAndTrack(track.ID, selectedCat.ID)(ctx, chatId)
-			} else {
-				message := "No hay sesiones disponibles"
-				msg := tgbotapi.NewMessage(chatId, message)
-				_, err = tm.bot.Send(msg)
-				return err
-			}
+// RenderRecentActivity lists the tracks and categories driven most recently.
+func (tm *Manager) RenderRecentActivity() func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := tm.locale.Localizer(ctx)
+		activities, err := tm.GetRecentActivity(ctx, recentLimit)
+		if err != nil {
+			return tm.renderAPIError(chatId, loc, err)
+		}
+		if len(activities) == 0 {
+			message := locale.Localize(loc, msgNoSessions)
+			msg := tgbotapi.NewMessage(chatId, message)
+			_, err = tm.bot.Send(msg)
+			return err
+		}
+		return SendRecentData(chatId, activities, 0, recentPerPage, nil, tm, loc)
+	}
+}
 
-		} else {
-			message := "No hay circuitos disponibles"
+func (tm *Manager) RenderShowRecentCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+		return HandleRecentDataCallbackQuery(ctx, query.Message.Chat.ID, query.Message.MessageID, tm, data[1:]...)
+	}
+}
+
+func (tm *Manager) RenderDriver(name string) func(ctx context.Context, chatId int64) error {
+	return func(ctx context.Context, chatId int64) error {
+		loc := tm.locale.Localizer(ctx)
+		if strings.TrimSpace(name) == "" {
+			message := locale.Localize(loc, msgDriverUsage)
+			msg := tgbotapi.NewMessage(chatId, message)
+			_, err := tm.bot.Send(msg)
+			return err
+		}
+
+		drivers, err := tm.FindDrivers(ctx, name)
+		if err != nil {
+			return tm.renderAPIError(chatId, loc, err)
+		}
+		if len(drivers) == 0 {
+			return tm.renderDriverNotFound(chatId, loc)
+		}
+		if len(drivers) > 1 {
+			message := locale.Localize(loc, msgDriverAmbiguous) + "\n\n"
+			for _, driver := range drivers {
+				message += fmt.Sprintf(" ▸ /driver %s\n", driver)
+			}
 			msg := tgbotapi.NewMessage(chatId, message)
 			_, err = tm.bot.Send(msg)
 			return err
 		}
+
+		bests, err := tm.GetDriverBests(ctx, drivers[0])
+		if err != nil {
+			return tm.renderAPIError(chatId, loc, err)
+		}
+		return SendDriverData(chatId, drivers[0], bests, 0, bestsPerPage, nil, tm, loc)
+	}
+}
+
+func (tm *Manager) RenderShowDriverCallback(data []string) func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	return func(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+		return HandleDriverDataCallbackQuery(ctx, query.Message.Chat.ID, query.Message.MessageID, tm, data[1:]...)
+	}
+}
+
+func (tm *Manager) renderDriverNotFound(chatId int64, loc *i18n.Localizer) error {
+	message := locale.Localize(loc, msgDriverNotFound)
+	msg := tgbotapi.NewMessage(chatId, message)
+	_, err := tm.bot.Send(msg)
+	return err
+}
+
+// renderAPIError tells the user why the data could not be fetched from the
+// F1Champs API. The errors that do not come from the API are returned.
+func (tm *Manager) renderAPIError(chatId int64, loc *i18n.Localizer, err error) error {
+	var message *i18n.Message
+	switch {
+	case errors.Is(err, ErrNotFound):
+		message = msgAPINotFound
+	case errors.Is(err, ErrServer):
+		message = msgAPIServer
+	case errors.Is(err, ErrDecode):
+		message = msgAPIDecode
+	case errors.Is(err, ErrUnavailable):
+		message = msgAPIUnavailable
+	default:
+		return err
 	}
+	log.Printf("Error requesting the F1Champs API: %s", err.Error())
+	msg := tgbotapi.NewMessage(chatId, locale.Localize(loc, message))
+	_, err = tm.bot.Send(msg)
+	return err
 }
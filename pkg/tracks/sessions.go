@@ -1,16 +1,20 @@
 package tracks
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"sort"
-
+	"log"
+	"strings"
+	"sync"
+	"time"
 )
 
+// dateTimeLayout is the format of Session.DateTime in the F1Champs API, the
+// local time of the server when the lap was driven.
+const dateTimeLayout = "2006-01-02 15:04:05"
+
+// invalidDateTimes holds the values of Session.DateTime that could not be
+// parsed, so every one is logged once.
+var invalidDateTimes sync.Map
+
 type Session struct {
 	Driver           string  `json:"driver"`
 	TrackCourse      string  `json:"TrackCourse"`
@@ -34,39 +38,19 @@ type Session struct {
 	Lapcountcomplete int     `json:"lapcountcomplete"`
 }
 
-func getSessions(ctx context.Context, track string, domain string) ([]Session, error) {
-	// Make a get request
-	url := fmt.Sprintf("%s/v3/laps?track=%s", domain, url.QueryEscape(track))
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// Do the request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	// Close the response body on function return
-	defer resp.Body.Close()
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// Date returns when the lap was driven. It is false if DateTime is empty or
+// not in the format of the API, which is logged.
+func (s Session) Date() (time.Time, bool) {
+	dateTime := strings.TrimSpace(s.DateTime)
+	if dateTime == "" {
+		return time.Time{}, false
 	}
-
-	// Unmarshal the response body into a TrackResponse struct
-	var trackSessions []Session
-	err = json.Unmarshal(body, &trackSessions)
+	t, err := time.ParseInLocation(dateTimeLayout, dateTime, time.Local)
 	if err != nil {
-		return nil, err
+		if _, logged := invalidDateTimes.LoadOrStore(dateTime, true); !logged {
+			log.Printf("Invalid date of the lap of %s: %s", s.Driver, err.Error())
+		}
+		return time.Time{}, false
 	}
-
-	sort.Slice(trackSessions, func(i, j int) bool {
-		return trackSessions[i].Time < trackSessions[j].Time
-	})
-
-	return trackSessions, nil
+	return t, true
 }
@@ -0,0 +1,32 @@
+package tracks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionDate(t *testing.T) {
+	tests := []struct {
+		dateTime string
+		want     time.Time
+		ok       bool
+	}{
+		{dateTime: "2024-01-02 15:04:05", want: time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local), ok: true},
+		{dateTime: " 2024-01-02 15:04:05 ", want: time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local), ok: true},
+		{dateTime: ""},
+		{dateTime: "   "},
+		{dateTime: "2024-01-02T15:04:05Z"},
+		{dateTime: "2024-01-02 15:04"},
+		{dateTime: "02/01/2024 15:04:05"},
+		{dateTime: "1704207845"},
+		{dateTime: "yesterday"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dateTime, func(t *testing.T) {
+			got, ok := Session{DateTime: tt.dateTime}.Date()
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("got %s, %t, expected %s, %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
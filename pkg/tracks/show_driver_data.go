@@ -0,0 +1,118 @@
+package tracks
+
+import (
+	"context"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	SubcommandShowDriver = "show_driver"
+
+	bestsPerPage = 10
+)
+
+func SendDriverData(chatId int64, driver string, bests []DriverBest, currentPage, count int, messageId *int, tm *Manager, loc *i18n.Localizer) error {
+	text, keyboard := DriverTextMarkup(driver, bests, currentPage, count, loc)
+
+	var cfg tgbotapi.Chattable
+	if messageId == nil {
+		msg := tgbotapi.NewMessage(chatId, text)
+		msg.ReplyMarkup = keyboard
+		cfg = msg
+	} else {
+		msg := tgbotapi.NewEditMessageText(chatId, *messageId, text)
+		msg.ReplyMarkup = &keyboard
+		cfg = msg
+	}
+
+	_, err := tm.bot.Send(cfg)
+	return err
+}
+
+func DriverTextMarkup(driver string, bests []DriverBest, currentPage, count int, loc *i18n.Localizer) (text string, markup tgbotapi.InlineKeyboardMarkup) {
+	maxPages := pages(len(bests), count)
+	currentPage = max(0, min(currentPage, maxPages-1))
+	from := min(currentPage*count, len(bests))
+	to := min(from+count, len(bests))
+
+	var lines []string
+	for _, best := range bests[from:to] {
+		gap := "P1"
+		if best.Position > 1 {
+			gap = fmt.Sprintf("P%d +%.3fs", best.Position, best.Gap)
+		}
+		lines = append(lines, fmt.Sprintf(" ▸ %s (%s)\n     %s %s ➡ /%s_%s", best.Track.Name, best.Category.Name, helper.SecondsToMinutes(best.Session.Time), gap, best.Track.ID, best.Category.ID))
+	}
+	text = fmt.Sprintf(locale.Localize(loc, msgDriverBests)+"\n\n", driver, currentPage+1, maxPages)
+	text += strings.Join(lines, "\n")
+
+	driverId := helper.ToID(driver)
+	var rows []tgbotapi.InlineKeyboardButton
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolInit, fmt.Sprintf("%s:init:%d:%d:%s", SubcommandShowDriver, currentPage, count, driverId)))
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolPrev, fmt.Sprintf("%s:prev:%d:%d:%s", SubcommandShowDriver, currentPage, count, driverId)))
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolNext, fmt.Sprintf("%s:next:%d:%d:%s", SubcommandShowDriver, currentPage, count, driverId)))
+	rows = append(rows, tgbotapi.NewInlineKeyboardButtonData(symbolEnd, fmt.Sprintf("%s:end:%d:%d:%s", SubcommandShowDriver, currentPage, count, driverId)))
+
+	markup = tgbotapi.NewInlineKeyboardMarkup(rows)
+	return
+}
+
+func HandleDriverDataCallbackQuery(ctx context.Context, chatId int64, messageId int, tm *Manager, data ...string) error {
+	if len(data) < 4 {
+		return nil
+	}
+	pagerType := data[0]
+	currentPage, _ := strconv.Atoi(data[1])
+	itemsPerPage, _ := strconv.Atoi(data[2])
+	if itemsPerPage <= 0 {
+		itemsPerPage = bestsPerPage
+	}
+	driverId := data[3]
+	loc := tm.locale.Localizer(ctx)
+
+	driver, found := tm.FindDriverByID(ctx, driverId)
+	if !found {
+		return tm.renderDriverNotFound(chatId, loc)
+	}
+	bests, err := tm.GetDriverBests(ctx, driver)
+	if err != nil {
+		return tm.renderAPIError(chatId, loc, err)
+	}
+	maxPages := pages(len(bests), itemsPerPage)
+
+	if pagerType == "next" {
+		nextPage := currentPage + 1
+		if nextPage < maxPages {
+			return SendDriverData(chatId, driver, bests, nextPage, itemsPerPage, &messageId, tm, loc)
+		}
+	}
+	if pagerType == "prev" {
+		previousPage := currentPage - 1
+		if previousPage >= 0 {
+			return SendDriverData(chatId, driver, bests, previousPage, itemsPerPage, &messageId, tm, loc)
+		}
+	}
+	if pagerType == "init" && currentPage != 0 {
+		return SendDriverData(chatId, driver, bests, 0, itemsPerPage, &messageId, tm, loc)
+	}
+	if pagerType == "end" && currentPage != maxPages-1 {
+		return SendDriverData(chatId, driver, bests, maxPages-1, itemsPerPage, &messageId, tm, loc)
+	}
+	return nil
+}
+
+// pages returns the number of pages needed to show count items, being at least one.
+func pages(items, count int) int {
+	if items == 0 || count <= 0 {
+		return 1
+	}
+	return (items + count - 1) / count
+}
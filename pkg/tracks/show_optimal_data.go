@@ -0,0 +1,89 @@
+package tracks
+
+import (
+	"bytes"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"log"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	inlineKeyboardOptimal = "optimal"
+	symbolOptimal         = "🚀"
+)
+
+// SendOptimalData ranks the drivers of a category by the time they leave on
+// the table compared with their optimal lap and shows the ideal lap built
+// from the fastest sectors.
+func SendOptimalData(chatId int64, messageId *int, trackId, categoryId string, tm *Manager, loc *i18n.Localizer) error {
+	track, found := tm.GetTrackByID(trackId)
+	if !found {
+		return tm.RenderTrackNotFound(chatId, loc)
+	}
+	category, found := track.GetCategoryById(categoryId)
+	if !found || len(category.Sessions) == 0 {
+		message := locale.Localize(loc, msgSessionsNotFound)
+		msg := tgbotapi.NewMessage(chatId, message)
+		_, err := tm.bot.Send(msg)
+		return err
+	}
+
+	history := tm.categoryLaps(trackId, category)
+	entries, ideal := OptimalLeaderboard(history)
+
+	var b bytes.Buffer
+	t := table.NewWriter()
+	t.SetOutputMirror(&b)
+	style := table.StyleRounded
+	style.Options.DrawBorder = false
+	t.SetStyle(style)
+	t.AppendSeparator()
+
+	t.AppendHeader(table.Row{locale.Localize(loc, msgHeaderDriver), locale.Localize(loc, msgHeaderBest), locale.Localize(loc, msgHeaderOptimal), "Δ"})
+	for _, entry := range entries {
+		loss := "-"
+		if entry.Optimal.Time() > 0 {
+			loss = fmt.Sprintf("%.3f", entry.Loss())
+		}
+		t.AppendRow([]interface{}{
+			helper.GetDriverCodeName(entry.Driver),
+			helper.SecondsToMinutes(entry.Best),
+			helper.SecondsToMinutes(entry.Optimal.Time()),
+			loss,
+		})
+	}
+	t.Render()
+
+	idealText := fmt.Sprintf(locale.Localize(loc, msgIdealLap)+"\n", helper.SecondsToMinutes(ideal.Sectors.Time()))
+	idealText += fmt.Sprintf("S1 %s %s\nS2 %s %s\nS3 %s %s\n",
+		helper.ToSectorTime(ideal.Sectors.S1), helper.GetDriverCodeName(ideal.Drivers[0]),
+		helper.ToSectorTime(ideal.Sectors.S2), helper.GetDriverCodeName(ideal.Drivers[1]),
+		helper.ToSectorTime(ideal.Sectors.S3), helper.GetDriverCodeName(ideal.Drivers[2]))
+
+	subscribed, err := tm.store.IsSubscribed(chatId, track.ID, categoryId)
+	if err != nil {
+		log.Printf("Error reading subscription: %s", err.Error())
+	}
+	keyboard := getInlineKeyboardForCategory(track.ID, categoryId, inlineKeyboardOptimal, subscribed, loc)
+	text := fmt.Sprintf("```\n"+locale.Localize(loc, msgOptimalTitle)+"\n\n%s\n%s```", track.Name, category.Name, b.String(), idealText)
+	return tm.sendOrEdit(chatId, messageId, text, tgbotapi.ModeMarkdownV2, keyboard)
+}
+
+// categoryLaps returns all the stored laps of the category, falling back to
+// the current leaderboard if the history cannot be read.
+func (tm *Manager) categoryLaps(trackId string, category Category) []Session {
+	history, err := tm.GetCategoryHistory(trackId, category.ID)
+	if err != nil || len(history) == 0 {
+		if err != nil {
+			log.Printf("Error reading laps history: %s", err.Error())
+		}
+		return category.Sessions
+	}
+	return history
+}
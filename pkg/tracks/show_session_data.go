@@ -2,56 +2,95 @@ package tracks
 
 import (
 	"bytes"
+	"f1champshotlapsbot/pkg/locale"
 	"fmt"
-	"strings"
+	"log"
 
 	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
 const (
-	inlineKeyboardTimes    = "Tiempos"
-	inlineKeyboardSectors  = "Sectores"
-	inlineKeyboardCompound = "Gomas"
-	inlineKeyboardLaps     = "Vueltas"
-	inlineKeyboardTeam     = "Coches"
-	inlineKeyboardDriver   = "Pilotos"
-	inlineKeyboardDate     = "Fecha"
-
-	symbolTimes    = "⏱"
-	symbolSectors  = "🔂"
-	symbolCompound = "🛞"
-	symbolLaps     = "🏁"
-	symbolTeam     = "🏎️"
-	symbolDriver   = "👐"
-	symbolDate     = "⌚️"
+	inlineKeyboardTimes      = "times"
+	inlineKeyboardSectors    = "sectors"
+	inlineKeyboardCompound   = "tyres"
+	inlineKeyboardLaps       = "laps"
+	inlineKeyboardTeam       = "cars"
+	inlineKeyboardDriver     = "drivers"
+	inlineKeyboardDate       = "date"
+	inlineKeyboardGap        = "gap"
+	inlineKeyboardInterval   = "interval"
+	inlineKeyboardPercentage = "107"
+
+	symbolTimes            = "⏱"
+	symbolSectors          = "🔂"
+	symbolCompound         = "🛞"
+	symbolLaps             = "🏁"
+	symbolTeam             = "🏎️"
+	symbolDriver           = "👐"
+	symbolDate             = "⌚️"
+	symbolNotificationsOn  = "🔔"
+	symbolNotificationsOff = "🔕"
+	symbolGap              = "⏳"
+	symbolInterval         = "↕️"
+	symbolPercentage       = "🚦"
+	symbolOutOfCutOff      = "*"
 
 	SubcommandShowTracks      = "show_tracks"
 	SubcommandShowSessionData = "show_session_data"
+	SubcommandSubscribe       = "subscribe_hotlaps"
+
+	// qualifyingCutOff is the percentage of the leader's time a lap must be
+	// within to be eligible (107% rule).
+	qualifyingCutOff = 107.0
+)
 
-	tableDriver = "PIL"
+var (
+	inlineKeyboardLabels = map[string]*i18n.Message{
+		inlineKeyboardTimes:      msgKeyboardTimes,
+		inlineKeyboardSectors:    msgKeyboardSectors,
+		inlineKeyboardCompound:   msgKeyboardCompound,
+		inlineKeyboardLaps:       msgKeyboardLaps,
+		inlineKeyboardTeam:       msgKeyboardTeam,
+		inlineKeyboardDriver:     msgKeyboardDriver,
+		inlineKeyboardDate:       msgKeyboardDate,
+		inlineKeyboardGap:        msgKeyboardGap,
+		inlineKeyboardInterval:   msgKeyboardInterval,
+		inlineKeyboardPercentage: msgKeyboardPercentage,
+		inlineKeyboardCompare:    msgKeyboardCompare,
+		inlineKeyboardOptimal:    msgKeyboardOptimal,
+		inlineKeyboardExport:     msgKeyboardExport,
+		inlineKeyboardCard:       msgKeyboardCard,
+	}
 )
 
-func HandleSessionDataCallbackQuery(chatId int64, messageId *int, tm *Manager, data ...string) error {
+func HandleSessionDataCallbackQuery(chatId int64, messageId *int, tm *Manager, loc *i18n.Localizer, data ...string) error {
 	infoType := data[0]
 	trackId := data[1]
 	categoryId := data[2]
-	return SendSessionData(chatId, messageId, trackId, categoryId, infoType, tm)
+	if infoType == inlineKeyboardCompare {
+		return SendCompareData(chatId, messageId, trackId, categoryId, tm, loc, data[3:]...)
+	} else if infoType == inlineKeyboardOptimal {
+		return SendOptimalData(chatId, messageId, trackId, categoryId, tm, loc)
+	} else if infoType == inlineKeyboardExport {
+		return SendExportData(chatId, messageId, trackId, categoryId, tm, loc, data[3:]...)
+	} else if infoType == inlineKeyboardCard {
+		return SendCardData(chatId, messageId, trackId, categoryId, tm, loc)
+	}
+	return SendSessionData(chatId, messageId, trackId, categoryId, infoType, tm, loc)
 }
 
-func SendSessionData(chatId int64, messageId *int, trackId, categoryId, infoType string, tm *Manager) error {
+func SendSessionData(chatId int64, messageId *int, trackId, categoryId, infoType string, tm *Manager, loc *i18n.Localizer) error {
 	track, found := tm.GetTrackByID(trackId)
 	if !found {
-		message := "El circuito seleccionado no se ha encontrado. Vuelve atrás y prueba otra vez"
-		msg := tgbotapi.NewMessage(chatId, message)
-		_, err := tm.bot.Send(msg)
-		return err
+		return tm.RenderTrackNotFound(chatId, loc)
 	}
 	category, found := track.GetCategoryById(categoryId)
 	if !found {
-		message := "No se han encontrado la sesiones para el circuito. Vuelve atrás y prueba otra vez"
+		message := locale.Localize(loc, msgSessionsNotFound)
 		msg := tgbotapi.NewMessage(chatId, message)
 		_, err := tm.bot.Send(msg)
 		return err
@@ -71,8 +110,12 @@ func SendSessionData(chatId int64, messageId *int, trackId, categoryId, infoType
 		t.SetStyle(style)
 		t.AppendSeparator()
 
-		t.AppendHeader(table.Row{tableDriver, infoType})
-		for _, session := range sessionsForCategory {
+		leaderTime := sessionsForCategory[0].Time
+		cutOffTime := leaderTime * qualifyingCutOff / 100
+		outOfCutOff := false
+
+		t.AppendHeader(table.Row{locale.Localize(loc, msgHeaderDriver), inlineKeyboardLabel(loc, infoType)})
+		for idx, session := range sessionsForCategory {
 			switch infoType {
 			case inlineKeyboardTimes:
 				t.AppendRow([]interface{}{
@@ -85,14 +128,9 @@ func SendSessionData(chatId int64, messageId *int, trackId, categoryId, infoType
 					fmt.Sprintf("%s %s %s", helper.ToSectorTime(session.S1), helper.ToSectorTime(session.S2), helper.ToSectorTime(session.S3)),
 				})
 			case inlineKeyboardCompound:
-				tyreSlice := strings.Split(session.Fcompound, ",")
-				tyre := "(desconocido)"
-				if len(tyreSlice) > 0 {
-					tyre = tyreSlice[len(tyreSlice)-1]
-				}
 				t.AppendRow([]interface{}{
 					helper.GetDriverCodeName(session.Driver),
-					tyre,
+					compound(session, loc),
 				})
 			case inlineKeyboardLaps:
 				t.AppendRow([]interface{}{
@@ -114,49 +152,133 @@ func SendSessionData(chatId int64, messageId *int, trackId, categoryId, infoType
 					helper.GetDriverCodeName(session.Driver),
 					session.DateTime,
 				})
+			case inlineKeyboardGap:
+				t.AppendRow([]interface{}{
+					helper.GetDriverCodeName(session.Driver),
+					helper.SecondsToDiff(session.Time - leaderTime),
+				})
+			case inlineKeyboardInterval:
+				interval := 0.0
+				if idx > 0 {
+					interval = session.Time - sessionsForCategory[idx-1].Time
+				}
+				t.AppendRow([]interface{}{
+					helper.GetDriverCodeName(session.Driver),
+					helper.SecondsToDiff(interval),
+				})
+			case inlineKeyboardPercentage:
+				percentage := fmt.Sprintf("%.2f%%", session.Time*100/leaderTime)
+				if session.Time > cutOffTime {
+					if !outOfCutOff {
+						// mark where the cut-off is
+						t.AppendSeparator()
+						outOfCutOff = true
+					}
+					percentage += symbolOutOfCutOff
+				}
+				t.AppendRow([]interface{}{
+					helper.GetDriverCodeName(session.Driver),
+					percentage,
+				})
 			}
 		}
 		t.Render()
+		if infoType == inlineKeyboardPercentage {
+			b.WriteString(fmt.Sprintf("\n%s "+locale.Localize(loc, msgOutOfCutOff)+"\n", symbolOutOfCutOff, qualifyingCutOff, helper.SecondsToMinutes(cutOffTime)))
+		}
 
-		keyboard := getInlineKeyboardForCategory(track.ID, categoryId)
-		var cfg tgbotapi.Chattable
-		if messageId == nil {
-			msg := tgbotapi.NewMessage(chatId, fmt.Sprintf("```\nResultados en %q para %q\n\n%s```", track.Name, categoryName, b.String()))
-			msg.ParseMode = tgbotapi.ModeMarkdownV2
-			msg.ReplyMarkup = keyboard
-			cfg = msg
-		} else {
-			msg := tgbotapi.NewEditMessageText(chatId, *messageId, fmt.Sprintf("```\nResultados en %q para %q\n\n%s```", track.Name, categoryName, b.String()))
-			msg.ParseMode = tgbotapi.ModeMarkdownV2
-			msg.ReplyMarkup = &keyboard
-			cfg = msg
+		subscribed, err := tm.store.IsSubscribed(chatId, track.ID, categoryId)
+		if err != nil {
+			log.Printf("Error reading subscription: %s", err.Error())
 		}
-		_, err := tm.bot.Send(cfg)
-		return err
+		keyboard := getInlineKeyboardForCategory(track.ID, categoryId, infoType, subscribed, loc)
+		text := fmt.Sprintf("```\n"+locale.Localize(loc, msgResults)+"\n\n%s```", track.Name, categoryName, b.String())
+		return tm.sendOrEdit(chatId, messageId, text, tgbotapi.ModeMarkdownV2, keyboard)
 	} else {
-		message := "No hay sesiones registradas"
+		message := locale.Localize(loc, msgNoSessionsRecorded)
 		msg := tgbotapi.NewMessage(chatId, message)
 		_, err := tm.bot.Send(msg)
 		return err
 	}
 }
 
-func getInlineKeyboardForCategory(trackId, categoryId string) tgbotapi.InlineKeyboardMarkup {
+// sendOrEdit sends a new message or edits the given one with the text and keyboard.
+func (tm *Manager) sendOrEdit(chatId int64, messageId *int, text, parseMode string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	var cfg tgbotapi.Chattable
+	if messageId == nil {
+		msg := tgbotapi.NewMessage(chatId, text)
+		msg.ParseMode = parseMode
+		msg.ReplyMarkup = keyboard
+		cfg = msg
+	} else {
+		msg := tgbotapi.NewEditMessageText(chatId, *messageId, text)
+		msg.ParseMode = parseMode
+		msg.ReplyMarkup = &keyboard
+		cfg = msg
+	}
+	_, err := tm.bot.Send(cfg)
+	return err
+}
+
+func HandleSubscribeCallbackQuery(chatId int64, messageId *int, tm *Manager, loc *i18n.Localizer, data ...string) error {
+	infoType := data[0]
+	trackId := data[1]
+	categoryId := data[2]
+	_, err := tm.store.ToggleSubscription(chatId, trackId, categoryId)
+	if err != nil {
+		message := locale.Localize(loc, msgCouldNotSubscribe)
+		msg := tgbotapi.NewMessage(chatId, message)
+		_, _ = tm.bot.Send(msg)
+		return err
+	}
+	return SendSessionData(chatId, messageId, trackId, categoryId, infoType, tm, loc)
+}
+
+func inlineKeyboardLabel(loc *i18n.Localizer, infoType string) string {
+	if msg, found := inlineKeyboardLabels[infoType]; found {
+		return locale.Localize(loc, msg)
+	}
+	return infoType
+}
+
+func inlineKeyboardButton(loc *i18n.Localizer, infoType, symbol, trackId, categoryId string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(inlineKeyboardLabel(loc, infoType)+" "+symbol, fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, infoType, trackId, categoryId))
+}
+
+func getInlineKeyboardForCategory(trackId, categoryId, infoType string, subscribed bool, loc *i18n.Localizer) tgbotapi.InlineKeyboardMarkup {
+	symbolNotifications := symbolNotificationsOff
+	if subscribed {
+		symbolNotifications = symbolNotificationsOn
+	}
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(inlineKeyboardTimes+" "+symbolTimes, fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardTimes, trackId, categoryId)),
+			inlineKeyboardButton(loc, inlineKeyboardTimes, symbolTimes, trackId, categoryId),
+			inlineKeyboardButton(loc, inlineKeyboardOptimal, symbolOptimal, trackId, categoryId),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			inlineKeyboardButton(loc, inlineKeyboardGap, symbolGap, trackId, categoryId),
+			inlineKeyboardButton(loc, inlineKeyboardInterval, symbolInterval, trackId, categoryId),
+			inlineKeyboardButton(loc, inlineKeyboardPercentage, symbolPercentage, trackId, categoryId),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			inlineKeyboardButton(loc, inlineKeyboardSectors, symbolSectors, trackId, categoryId),
+			inlineKeyboardButton(loc, inlineKeyboardCompound, symbolTimes, trackId, categoryId),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			inlineKeyboardButton(loc, inlineKeyboardLaps, symbolLaps, trackId, categoryId),
+			inlineKeyboardButton(loc, inlineKeyboardTeam, symbolTeam, trackId, categoryId),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(inlineKeyboardSectors+" "+symbolSectors, fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardSectors, trackId, categoryId)),
-			tgbotapi.NewInlineKeyboardButtonData(inlineKeyboardCompound+" "+symbolTimes, fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardCompound, trackId, categoryId)),
+			inlineKeyboardButton(loc, inlineKeyboardDriver, symbolDriver, trackId, categoryId),
+			inlineKeyboardButton(loc, inlineKeyboardDate, symbolDate, trackId, categoryId),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(inlineKeyboardLaps+" "+symbolLaps, fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardLaps, trackId, categoryId)),
-			tgbotapi.NewInlineKeyboardButtonData(inlineKeyboardTeam+" "+symbolTeam, fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardTeam, trackId, categoryId)),
+			inlineKeyboardButton(loc, inlineKeyboardCompare, symbolCompare, trackId, categoryId),
+			tgbotapi.NewInlineKeyboardButtonData(locale.Localize(loc, msgKeyboardNotifications)+" "+symbolNotifications, fmt.Sprintf("%s:%s:%s:%s", SubcommandSubscribe, infoType, trackId, categoryId)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(inlineKeyboardDriver+" "+symbolDriver, fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardDriver, trackId, categoryId)),
-			tgbotapi.NewInlineKeyboardButtonData(inlineKeyboardDate+" "+symbolDate, fmt.Sprintf("%s:%s:%s:%s", SubcommandShowSessionData, inlineKeyboardDate, trackId, categoryId)),
+			inlineKeyboardButton(loc, inlineKeyboardExport, symbolExport, trackId, categoryId),
+			inlineKeyboardButton(loc, inlineKeyboardCard, symbolCard, trackId, categoryId),
 		),
 	)
 }
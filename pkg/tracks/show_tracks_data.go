@@ -1,11 +1,13 @@
 package tracks
 
 import (
+	"f1champshotlapsbot/pkg/locale"
 	"fmt"
 	"strconv"
 	"strings"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
 )
 
 const (
@@ -15,8 +17,8 @@ const (
 	symbolEnd  = "⏭"
 )
 
-func SendTracksData(chatId int64, currentPage, count, maxPages int, messageId *int, tm *Manager) error {
-	text, keyboard := TracksTextMarkup(currentPage, count, maxPages, tm)
+func SendTracksData(chatId int64, currentPage, count int, messageId *int, tm *Manager, loc *i18n.Localizer) error {
+	text, keyboard := TracksTextMarkup(currentPage, count, tm, loc)
 
 	var cfg tgbotapi.Chattable
 	if messageId == nil {
@@ -33,13 +35,13 @@ func SendTracksData(chatId int64, currentPage, count, maxPages int, messageId *i
 	return err
 }
 
-func TracksTextMarkup(currentPage, count, maxPages int, tm *Manager) (text string, markup tgbotapi.InlineKeyboardMarkup) {
-	ts := tm.GetRange(currentPage*count, currentPage*count+count)
+func TracksTextMarkup(currentPage, count int, tm *Manager, loc *i18n.Localizer) (text string, markup tgbotapi.InlineKeyboardMarkup) {
+	ts, currentPage, maxPages := tm.GetPage(currentPage, count)
 	var trackNames []string
 	for _, track := range ts {
 		trackNames = append(trackNames, track.CommandString())
 	}
-	text = fmt.Sprintf("Elige el circuito de la lista (%d/%d):\n\n", currentPage+1, maxPages)
+	text = fmt.Sprintf(locale.Localize(loc, msgChooseTrack)+"\n\n", currentPage+1, maxPages)
 	text += strings.Join(trackNames, "\n")
 
 	var rows []tgbotapi.InlineKeyboardButton
@@ -52,28 +54,35 @@ func TracksTextMarkup(currentPage, count, maxPages int, tm *Manager) (text strin
 	return
 }
 
-func HandleTrackDataCallbackQuery(chatId int64, messageId, maxPages int, tm *Manager, data ...string) error {
+func HandleTrackDataCallbackQuery(chatId int64, messageId int, tm *Manager, loc *i18n.Localizer, data ...string) error {
+	if len(data) < 3 {
+		return nil
+	}
 	pagerType := data[0]
 	currentPage, _ := strconv.Atoi(data[1])
 	itemsPerPage, _ := strconv.Atoi(data[2])
+	if itemsPerPage <= 0 {
+		itemsPerPage = tracksPerPage
+	}
+	_, _, maxPages := tm.GetPage(0, itemsPerPage)
 
 	if pagerType == "next" {
 		nextPage := currentPage + 1
 		if nextPage < maxPages {
-			return SendTracksData(chatId, nextPage, itemsPerPage, maxPages, &messageId, tm)
+			return SendTracksData(chatId, nextPage, itemsPerPage, &messageId, tm, loc)
 		}
 	}
 	if pagerType == "prev" {
 		previousPage := currentPage - 1
 		if previousPage >= 0 {
-			return SendTracksData(chatId, previousPage, itemsPerPage, maxPages, &messageId, tm)
+			return SendTracksData(chatId, previousPage, itemsPerPage, &messageId, tm, loc)
 		}
 	}
 	if pagerType == "init" {
-		return SendTracksData(chatId, 0, itemsPerPage, maxPages, &messageId, tm)
+		return SendTracksData(chatId, 0, itemsPerPage, &messageId, tm, loc)
 	}
 	if pagerType == "end" {
-		return SendTracksData(chatId, maxPages-1, itemsPerPage, maxPages, &messageId, tm)
+		return SendTracksData(chatId, maxPages-1, itemsPerPage, &messageId, tm, loc)
 	}
 	return nil
 }
@@ -6,6 +6,8 @@ import (
 	"sort"
 	"strings"
 	"sync"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
 )
 
 type Category struct {
@@ -24,24 +26,57 @@ type Track struct {
 	Name       string
 	Categories []Category
 	mu         sync.Mutex
+	store      Storer
 }
 
-func (t *Track) GetCategories(ctx context.Context, domain string) ([]Category, error) {
+func NewTrack(name string) *Track {
+	return &Track{
+		Command: "/" + helper.ToID(name),
+		ID:      helper.ToID(name),
+		Name:    name,
+		mu:      sync.Mutex{},
+	}
+}
+
+func (t *Track) GetCategories(ctx context.Context, api *Client) ([]Category, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	if len(t.Categories) == 0 && t.store != nil {
+		// read them from the store if the track was already synced
+		ss, err := t.store.ListSessions(t.ID)
+		if err != nil {
+			return nil, err
+		}
+		t.Categories = getCategories(ss)
+	}
 	if len(t.Categories) == 0 {
 		// if there is no categories, fetch them
-		ss, err := getSessions(ctx, t.Name, domain)
+		ss, _, err := api.GetSessions(ctx, t.Name)
 		if err != nil {
 			return nil, err
 		}
+		if t.store != nil {
+			err = t.store.SaveSessions(t.ID, ss)
+			if err != nil {
+				return nil, err
+			}
+		}
 		t.Categories = getCategories(ss)
 	}
 
 	return t.Categories, nil
 }
 
+// resetCategories drops the categories so they are read again from the store.
+func (t *Track) resetCategories() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Categories = nil
+}
+
 func (t *Track) GetCategoryById(cId string) (Category, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	for _, c := range t.Categories {
 		if c.ID == cId {
 			return c, true
@@ -0,0 +1,197 @@
+package tracks
+
+import (
+	"errors"
+	"f1champshotlapsbot/pkg/locale"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/oscar-martin/rfactor2telegrambot/pkg/helper"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+const (
+	ImprovementP1           = "p1"
+	ImprovementPersonalBest = "pb"
+	ImprovementNewEntry     = "new"
+
+	symbolP1           = "🏆"
+	symbolPersonalBest = "🟢"
+	symbolNewEntry     = "🆕"
+
+	// notifyInterval spaces the notifications so they stay below the limit
+	// of Telegram of about 30 messages per second
+	notifyInterval = time.Second / 25
+	// maxRetryAfter bounds the wait asked by Telegram before a notification
+	// is sent again
+	maxRetryAfter = time.Minute
+)
+
+// Improvement is a change in a category leaderboard between two syncs.
+// Previous is the lap the new one is compared against: the previous P1 for
+// new P1s, the previous personal best for personal bests and the current P1
+// for new entries.
+type Improvement struct {
+	Kind         string
+	TrackID      string
+	TrackName    string
+	CategoryID   string
+	CategoryName string
+	Previous     Session
+	Current      Session
+	Position     int
+}
+
+// diffSessions compares two snapshots of the sessions of a track and returns
+// the improvements found. Categories without previous sessions are skipped
+// so the first sync of a track does not flood the subscribers.
+func diffSessions(t *Track, old, new []Session) []Improvement {
+	oldCats := map[string]Category{}
+	for _, c := range getCategories(old) {
+		oldCats[c.ID] = c
+	}
+
+	improvements := []Improvement{}
+	for _, newCat := range getCategories(new) {
+		oldCat, found := oldCats[newCat.ID]
+		if !found || len(oldCat.Sessions) == 0 || len(newCat.Sessions) == 0 {
+			continue
+		}
+		oldBests := bestPerDriver(oldCat.Sessions)
+		oldLeader := oldCat.Sessions[0]
+		newLeader := newCat.Sessions[0]
+
+		for idx, current := range uniqueDrivers(newCat.Sessions) {
+			imp := Improvement{
+				TrackID:      t.ID,
+				TrackName:    t.Name,
+				CategoryID:   newCat.ID,
+				CategoryName: newCat.Name,
+				Current:      current,
+				Position:     idx + 1,
+			}
+			previous, existed := oldBests[current.Driver]
+			switch {
+			case current == newLeader && current.Time < oldLeader.Time:
+				imp.Kind = ImprovementP1
+				imp.Previous = oldLeader
+			case !existed:
+				imp.Kind = ImprovementNewEntry
+				imp.Previous = newLeader
+			case current.Time < previous.Time:
+				imp.Kind = ImprovementPersonalBest
+				imp.Previous = previous
+			default:
+				continue
+			}
+			improvements = append(improvements, imp)
+		}
+	}
+	return improvements
+}
+
+// bestPerDriver relies on sessions being sorted by time.
+func bestPerDriver(ss []Session) map[string]Session {
+	bests := map[string]Session{}
+	for _, s := range ss {
+		if _, found := bests[s.Driver]; !found {
+			bests[s.Driver] = s
+		}
+	}
+	return bests
+}
+
+// uniqueDrivers returns the best session of every driver keeping the order.
+func uniqueDrivers(ss []Session) []Session {
+	seen := map[string]bool{}
+	unique := []Session{}
+	for _, s := range ss {
+		if !seen[s.Driver] {
+			seen[s.Driver] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// notifyImprovements sends the improvements to the chats subscribed to their
+// category, one every notifyInterval.
+func (tm *Manager) notifyImprovements(improvements []Improvement) {
+	tm.notifyMu.Lock()
+	defer tm.notifyMu.Unlock()
+
+	ticker := time.NewTicker(notifyInterval)
+	defer ticker.Stop()
+	first := true
+	for _, imp := range improvements {
+		chatIds, err := tm.store.ListSubscribedChats(imp.TrackID, imp.CategoryID)
+		if err != nil {
+			log.Printf("Error listing subscribed chats: %s", err.Error())
+			continue
+		}
+		if len(chatIds) == 0 {
+			continue
+		}
+		log.Printf("Sending hotlap improvement for %s (%s) to %d chats\n", imp.TrackName, imp.CategoryName, len(chatIds))
+		for _, chatId := range chatIds {
+			if !first {
+				<-ticker.C
+			}
+			first = false
+			err = tm.notify(chatId, imp)
+			if err != nil {
+				log.Printf("Error notifying chat %d: %s", chatId, err.Error())
+			}
+		}
+	}
+}
+
+// notify sends the improvement to the chat. It is sent again once if Telegram
+// asks to wait because of too many messages.
+func (tm *Manager) notify(chatId int64, imp Improvement) error {
+	msg := tgbotapi.NewMessage(chatId, imp.Message(tm.locale.ForChat(chatId)))
+	_, err := tm.bot.Send(msg)
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+		wait := min(time.Duration(tgErr.RetryAfter)*time.Second, maxRetryAfter)
+		log.Printf("Too many notifications, retrying chat %d in %s", chatId, wait)
+		time.Sleep(wait)
+		_, err = tm.bot.Send(msg)
+	}
+	return err
+}
+
+// Message returns the notification text for the improvement.
+func (imp Improvement) Message(loc *i18n.Localizer) string {
+	var title, previous string
+	switch imp.Kind {
+	case ImprovementP1:
+		title = fmt.Sprintf("%s "+locale.Localize(loc, msgImprovementP1), symbolP1, imp.TrackName, imp.CategoryName)
+		previous = fmt.Sprintf(locale.Localize(loc, msgImprovementPreviousP1), helper.SecondsToMinutes(imp.Previous.Time), imp.Previous.Driver)
+	case ImprovementPersonalBest:
+		title = fmt.Sprintf("%s "+locale.Localize(loc, msgImprovementPB), symbolPersonalBest, imp.TrackName, imp.CategoryName)
+		previous = fmt.Sprintf(locale.Localize(loc, msgImprovementPrevious), helper.SecondsToMinutes(imp.Previous.Time))
+	default:
+		title = fmt.Sprintf("%s "+locale.Localize(loc, msgImprovementNewEntry), symbolNewEntry, imp.TrackName, imp.CategoryName)
+		previous = fmt.Sprintf(locale.Localize(loc, msgImprovementLeader), helper.SecondsToMinutes(imp.Previous.Time), imp.Previous.Driver)
+	}
+
+	message := fmt.Sprintf("%s\n\n%s: %s (P%d)\n%s\n", title, imp.Current.Driver, helper.SecondsToMinutes(imp.Current.Time), imp.Position, previous)
+	message += fmt.Sprintf(locale.Localize(loc, msgImprovementGap)+"\n", signedDiff(imp.Current.Time, imp.Previous.Time))
+	message += fmt.Sprintf(locale.Localize(loc, msgImprovementSectors),
+		signedDiff(imp.Current.S1, imp.Previous.S1),
+		signedDiff(imp.Current.S2, imp.Previous.S2),
+		signedDiff(imp.Current.S3, imp.Previous.S3))
+	return message
+}
+
+// signedDiff returns the delta between two times, or "-" if any is unknown.
+func signedDiff(current, previous float64) string {
+	if current <= 0 || previous <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.3fs", current-previous)
+}
@@ -0,0 +1,93 @@
+package tracks
+
+import (
+	"testing"
+)
+
+func hotlap(driver, category string, time float64) Session {
+	return Session{Driver: driver, Category: category, Time: time}
+}
+
+func TestDiffSessions(t *testing.T) {
+	type want struct {
+		kind     string
+		driver   string
+		previous string
+		position int
+	}
+	tests := []struct {
+		name string
+		old  []Session
+		new  []Session
+		want []want
+	}{
+		{
+			name: "new P1",
+			old:  []Session{hotlap("A", "GT3", 90), hotlap("B", "GT3", 91)},
+			new:  []Session{hotlap("C", "GT3", 89), hotlap("A", "GT3", 90), hotlap("B", "GT3", 91)},
+			want: []want{{ImprovementP1, "C", "A", 1}},
+		},
+		{
+			name: "P1 of a driver already in the leaderboard",
+			old:  []Session{hotlap("A", "GT3", 90), hotlap("B", "GT3", 91)},
+			new:  []Session{hotlap("B", "GT3", 89.5), hotlap("A", "GT3", 90), hotlap("B", "GT3", 91)},
+			want: []want{{ImprovementP1, "B", "A", 1}},
+		},
+		{
+			name: "personal best",
+			old:  []Session{hotlap("A", "GT3", 90), hotlap("B", "GT3", 92)},
+			new:  []Session{hotlap("A", "GT3", 90), hotlap("B", "GT3", 91), hotlap("B", "GT3", 92)},
+			want: []want{{ImprovementPersonalBest, "B", "B", 2}},
+		},
+		{
+			name: "new entry",
+			old:  []Session{hotlap("A", "GT3", 90)},
+			new:  []Session{hotlap("A", "GT3", 90), hotlap("D", "GT3", 95)},
+			want: []want{{ImprovementNewEntry, "D", "A", 2}},
+		},
+		{
+			name: "slower lap",
+			old:  []Session{hotlap("A", "GT3", 90), hotlap("B", "GT3", 91)},
+			new:  []Session{hotlap("A", "GT3", 90), hotlap("B", "GT3", 91), hotlap("A", "GT3", 93)},
+		},
+		{
+			name: "no changes",
+			old:  []Session{hotlap("A", "GT3", 90)},
+			new:  []Session{hotlap("A", "GT3", 90)},
+		},
+		{
+			name: "category missing from the old sessions",
+			old:  []Session{hotlap("A", "GT3", 90)},
+			new:  []Session{hotlap("A", "GT3", 90), hotlap("X", "LMP2", 80), hotlap("Y", "LMP2", 81)},
+		},
+		{
+			name: "first sync",
+			new:  []Session{hotlap("A", "GT3", 90), hotlap("B", "GT3", 91)},
+		},
+		{
+			name: "improvements in several categories",
+			old:  []Session{hotlap("X", "LMP2", 80), hotlap("A", "GT3", 90)},
+			new:  []Session{hotlap("Y", "LMP2", 79), hotlap("X", "LMP2", 80), hotlap("A", "GT3", 89)},
+			want: []want{{ImprovementP1, "A", "A", 1}, {ImprovementP1, "Y", "X", 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track := NewTrack("Imola")
+			got := diffSessions(track, tt.old, tt.new)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d improvements, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, w := range tt.want {
+				imp := got[i]
+				if imp.Kind != w.kind || imp.Current.Driver != w.driver || imp.Previous.Driver != w.previous || imp.Position != w.position {
+					t.Errorf("expected %s of %s over %s at P%d, got %s of %s over %s at P%d",
+						w.kind, w.driver, w.previous, w.position, imp.Kind, imp.Current.Driver, imp.Previous.Driver, imp.Position)
+				}
+				if imp.TrackID != track.ID || imp.TrackName != track.Name {
+					t.Errorf("expected the improvement in %s, got %s", track.Name, imp.TrackName)
+				}
+			}
+		})
+	}
+}